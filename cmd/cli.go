@@ -1,11 +1,15 @@
 package cmd
 
 import (
+	"bkc/core"
 	"bkc/utils"
+	"encoding/hex"
 	"flag"
 	"fmt"
 	"log"
+	"math"
 	"os"
+	"strings"
 )
 
 // 对 blockchain 的命令行操作进行管理
@@ -17,12 +21,21 @@ type CLI struct {
 // PrintUsage 用法展示
 func PrintUsage()  {
 	fmt.Println("Usage:")
+	// 全局参数
+	fmt.Printf("-network NETWORK -- 全局参数，可以放在任意命令之前或之后，选择网络：mainnet（默认）、testnet 或 regtest\n")
+	fmt.Printf("\t不同网络使用不同的网络魔数、地址前缀、种子节点、难度与创世区块，数据库与钱包文件也相互独立\n")
+	fmt.Printf("所有地址参数都可以使用 Base58 或者 Bech32 形式（accounts 同时输出两种形式）\n")
 	// 初始化区块链
 	fmt.Printf("createblockchain -address address -- 创建区块链\n")
 	fmt.Printf("\t参数说明\n")
-	fmt.Printf("\t\t-address ADDRESS -- 账户地址\n")
+	fmt.Printf("\t\t-address ADDRESS -- 账户地址，省略时创建当前网络预设的创世区块\n")
+	fmt.Printf("\t\t-consensus CONSENSUS -- 共识算法：pow（默认）或 poa\n")
+	fmt.Printf("\t\t-signers SIGNERS -- poa 签名者的本地钱包地址或者十六进制公钥列表，按轮流出块的顺序排列\n")
+	fmt.Printf("\t\t-spec FILE -- 链规格文件（JSON），指定创世区块与链参数，使用时忽略其他参数\n")
 	// 打印完整的区块信息
 	fmt.Printf("printchain -- 输出区块信息\n")
+	fmt.Printf("\t参数说明\n")
+	fmt.Printf("\t\t-headers -- 只输出区块头摘要\n")
 
 	// 通过命令转账
 	fmt.Printf("send -from FROM -to TO -amount AMOUNT -- 发起转账\n")
@@ -31,6 +44,10 @@ func PrintUsage()  {
 	fmt.Printf("\t\t-from FROM -- 转账源地址\n")
 	fmt.Printf("\t\t-to TO -- 转账目标地址\n")
 	fmt.Printf("\t\t-amount AMOUNT -- 转账金额\n")
+	fmt.Printf("\t\t-fee FEE -- 每笔交易的手续费，由打包交易的矿工获得（默认为 0）\n")
+	fmt.Printf("\t\t-locktime LOCKTIME -- 锁定时间，小于 500000000 时为区块高度，否则为时间戳，交易在此之后才能被打包（默认为 0）\n")
+	fmt.Printf("\t\t-data HEX -- 十六进制数据，每笔交易添加一个携带该数据的输出（不能被花费，最多 %d 字节）\n",
+		core.ActiveParams.MaxDataCarrierSize)
 	// 查询余额
 	fmt.Printf("getbalance -address FROM -- 查询指定地址的余额\n")
 	fmt.Println("\t参数说明")
@@ -43,9 +60,93 @@ func PrintUsage()  {
 	fmt.Printf("\tMETHOD -- 方法名\n")
 	fmt.Printf("\t\treset -- 重置UTXOtable\n")
 	fmt.Printf("\t\tbalance - 查找所有UTXO\n")
+	fmt.Printf("merkleproof -block BLOCK -tx TX -- 输出交易存在于区块中的 Merkle 证明\n")
+	fmt.Printf("\t参数说明\n")
+	fmt.Printf("\t\t-block BLOCK -- 区块哈希\n")
+	fmt.Printf("\t\t-tx TX -- 交易哈希\n")
+	fmt.Printf("mine -address ADDRESS -- 把交易池中的交易打包进一个新区块\n")
+	fmt.Printf("\t参数说明\n")
+	fmt.Printf("\t\t-address ADDRESS -- 接收区块奖励与手续费的矿工地址\n")
+	fmt.Printf("\t\t-workers N -- 挖矿的并行线程数，默认使用全部 CPU 核心\n")
+	fmt.Printf("generate -n N -address ADDRESS -- 连续挖出 N 个区块\n")
+	fmt.Printf("\t参数说明\n")
+	fmt.Printf("\t\t-n N -- 区块数量\n")
+	fmt.Printf("\t\t-address ADDRESS -- 接收区块奖励与手续费的矿工地址\n")
+	fmt.Printf("\t\t-workers N -- 挖矿的并行线程数，默认使用全部 CPU 核心\n")
+	fmt.Printf("getrawmempool -- 输出交易池中所有交易的哈希\n")
+	fmt.Printf("getmempoolentry -txid TXID -- 输出交易池中指定交易的信息\n")
+	fmt.Printf("\t参数说明\n")
+	fmt.Printf("\t\t-txid TXID -- 交易哈希\n")
+	fmt.Printf("createmultisig -m M -keys KEYS -- 创建 M-of-N 多签地址\n")
+	fmt.Printf("\t参数说明\n")
+	fmt.Printf("\t\t-m M -- 花费时需要的签名数量\n")
+	fmt.Printf("\t\t-keys KEYS -- 本地钱包地址或者十六进制公钥列表\n")
+	fmt.Printf("spendmultisig -from FROM -to TO -amount AMOUNT -- 发起花费多签地址的转账，使用本地私钥签名\n")
+	fmt.Printf("\t参数说明\n")
+	fmt.Printf("\t\t-from FROM -- 多签地址\n")
+	fmt.Printf("\t\t-to TO -- 转账目标地址\n")
+	fmt.Printf("\t\t-amount AMOUNT -- 转账金额\n")
+	fmt.Printf("\t\t-fee FEE -- 手续费（默认为 0）\n")
+	fmt.Printf("signmultisig -tx TX -- 为部分签名交易添加本地私钥的签名，签名数量足够时提交到交易池\n")
+	fmt.Printf("\t参数说明\n")
+	fmt.Printf("\t\t-tx TX -- 十六进制的部分签名交易\n")
+	fmt.Printf("createrawtransaction -inputs INPUTS -outputs OUTPUTS -- 生成未签名的原始交易\n")
+	fmt.Printf("\t参数说明\n")
+	fmt.Printf("\t\t-inputs INPUTS -- 输入列表，例如 [{\"txid\":\"...\",\"vout\":0,\"blocks\":10}]\n")
+	fmt.Printf("\t\t\tblocks/seconds -- 相对锁定的区块数/秒数（以 512 秒为单位），也可以直接指定 sequence\n")
+	fmt.Printf("\t\t-outputs OUTPUTS -- 输出列表，例如 [{\"address\":\"...\",\"amount\":5},{\"data\":\"...\"}]\n")
+	fmt.Printf("\t\t-locktime LOCKTIME -- 锁定时间，小于 500000000 时为区块高度，否则为时间戳（默认为 0）\n")
+	fmt.Printf("signrawtransaction -tx TX -- 使用本地私钥为原始交易签名\n")
+	fmt.Printf("\t参数说明\n")
+	fmt.Printf("\t\t-tx TX -- 十六进制的原始交易\n")
+	fmt.Printf("sendrawtransaction -tx TX -- 把已签名的原始交易提交到交易池\n")
+	fmt.Printf("\t参数说明\n")
+	fmt.Printf("\t\t-tx TX -- 十六进制的原始交易\n")
+	fmt.Printf("migrate -- 把旧版本（gob 编码）的数据库迁移为规范编码\n")
 	fmt.Printf("set_id -port PORT -- 设置节点号\n")
 	fmt.Printf("\tport -- 访问的节点号\n")
 	fmt.Printf("start -- 启动节点服务\n")
+	fmt.Printf("\t参数说明\n")
+	fmt.Printf("\t\t-mine -- 同时持续挖矿\n")
+	fmt.Printf("\t\t-mineraddress ADDRESS -- 接收区块奖励与手续费的矿工地址\n")
+	fmt.Printf("\t\t-workers N -- 挖矿的并行线程数，默认使用全部 CPU 核心\n")
+}
+
+// SelectNetworkArgs 从命令行参数中取出全局参数 -network NETWORK（或 -network=NETWORK）并选择网络
+func SelectNetworkArgs() {
+	network := core.MainNet
+	args := []string{os.Args[0]}
+	for i := 1; i < len(os.Args); i++ {
+		switch arg := os.Args[i]; {
+		case arg == "-network" || arg == "--network":
+			if i+1 >= len(os.Args) {
+				PrintUsage()
+				os.Exit(1)
+			}
+			i++
+			network = os.Args[i]
+		case strings.HasPrefix(arg, "-network=") || strings.HasPrefix(arg, "--network="):
+			network = arg[strings.Index(arg, "=")+1:]
+		default:
+			args = append(args, arg)
+		}
+	}
+	if err := core.SelectNetwork(network); nil != err {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	os.Args = args
+}
+
+// CheckAddresses 检查地址，任何一个地址无效（格式、校验和错误或者属于其他网络）时输出原因并退出，
+// 在打开区块链之前调用
+func CheckAddresses(addresses ...string) {
+	for _, address := range addresses {
+		if _, _, err := core.DecodeAddress(address); nil != err {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+	}
 }
 
 func IsValidArgs() {
@@ -59,6 +160,8 @@ func IsValidArgs() {
 // Run 命令行运行函数
 func (cli *CLI) Run() {
 	nodeId := utils.GetEnvNodeId()
+	// 选择网络
+	SelectNetworkArgs()
 	// 检测参数数量
 	IsValidArgs()
 	// 新建相关命令
@@ -81,19 +184,76 @@ func (cli *CLI) Run() {
 	setNodeIdCmd := flag.NewFlagSet("set_id", flag.ExitOnError)
 	// 节点服务启动命令
 	startNodeCmd := flag.NewFlagSet("start", flag.ExitOnError)
+	// Merkle 证明命令
+	merkleProofCmd := flag.NewFlagSet("merkleproof", flag.ExitOnError)
+	// 挖矿命令
+	mineCmd := flag.NewFlagSet("mine", flag.ExitOnError)
+	generateCmd := flag.NewFlagSet("generate", flag.ExitOnError)
+	// 交易池查询命令
+	getRawMempoolCmd := flag.NewFlagSet("getrawmempool", flag.ExitOnError)
+	getMempoolEntryCmd := flag.NewFlagSet("getmempoolentry", flag.ExitOnError)
+	// 多重签名命令
+	createMultiSigCmd := flag.NewFlagSet("createmultisig", flag.ExitOnError)
+	spendMultiSigCmd := flag.NewFlagSet("spendmultisig", flag.ExitOnError)
+	signMultiSigCmd := flag.NewFlagSet("signmultisig", flag.ExitOnError)
+	// 原始交易命令
+	createRawTransactionCmd := flag.NewFlagSet("createrawtransaction", flag.ExitOnError)
+	signRawTransactionCmd := flag.NewFlagSet("signrawtransaction", flag.ExitOnError)
+	sendRawTransactionCmd := flag.NewFlagSet("sendrawtransaction", flag.ExitOnError)
+	// 数据库迁移命令
+	migrateCmd := flag.NewFlagSet("migrate", flag.ExitOnError)
 
 	// 数据参数处理
 	// 创建区块时指定的矿工地址
-	flagCreateBlockchainArg := createBLCWithGenesisBlockCmd.String("address", "troytan",
+	flagCreateBlockchainArg := createBLCWithGenesisBlockCmd.String("address", "",
 		"指定接收系统奖励的矿工地址")
+	flagCreateBlockchainConsensusArg := createBLCWithGenesisBlockCmd.String("consensus", core.ConsensusPoW,
+		"共识算法：pow 或 poa")
+	flagCreateBlockchainSignersArg := createBLCWithGenesisBlockCmd.String("signers", "",
+		"poa 签名者的本地钱包地址或者十六进制公钥列表")
+	flagCreateBlockchainSpecArg := createBLCWithGenesisBlockCmd.String("spec", "", "链规格文件")
 	// 发起交易参数
 	flagSendFromArg := sendCmd.String("from", "", "转账源地址")
 	flagSendToArg := sendCmd.String("to", "", "转账目标地址")
 	flagSendAmountArg := sendCmd.String("amount", "", "转账金额")
+	flagSendFeeArg := sendCmd.Int("fee", 0, "每笔交易的手续费")
+	flagSendLockTimeArg := sendCmd.Uint("locktime", 0, "锁定时间（区块高度或者时间戳）")
+	flagSendDataArg := sendCmd.String("data", "", "十六进制数据")
 	// 查询余额命令行参数
 	flagGetBalanceArg := getbalanceCmd.String("address", "", "要查询的地址")
 	// UTXO 测试命令行参数
 	flagUTXOArg := UTXOTestCmd.String("method", "", "UTXO Table 相关操作")
+	// 只输出区块头参数
+	flagPrintHeadersArg := printchainCmd.Bool("headers", false, "只输出区块头摘要")
+	// Merkle 证明参数
+	flagProofBlockArg := merkleProofCmd.String("block", "", "区块哈希")
+	flagProofTxArg := merkleProofCmd.String("tx", "", "交易哈希")
+	// 挖矿参数
+	flagMineAddressArg := mineCmd.String("address", "", "矿工地址")
+	flagGenerateNArg := generateCmd.Int("n", 1, "区块数量")
+	flagGenerateAddressArg := generateCmd.String("address", "", "矿工地址")
+	flagMineWorkersArg := mineCmd.Int("workers", 0, "挖矿的并行线程数")
+	flagGenerateWorkersArg := generateCmd.Int("workers", 0, "挖矿的并行线程数")
+	// 节点挖矿参数
+	flagStartMineArg := startNodeCmd.Bool("mine", false, "同时持续挖矿")
+	flagStartMinerAddressArg := startNodeCmd.String("mineraddress", "", "矿工地址")
+	flagStartWorkersArg := startNodeCmd.Int("workers", 0, "挖矿的并行线程数")
+	// 交易池查询参数
+	flagMempoolTxArg := getMempoolEntryCmd.String("txid", "", "交易哈希")
+	// 多重签名参数
+	flagMultiSigMArg := createMultiSigCmd.Int("m", 1, "花费时需要的签名数量")
+	flagMultiSigKeysArg := createMultiSigCmd.String("keys", "", "本地钱包地址或者十六进制公钥列表")
+	flagSpendMultiSigFromArg := spendMultiSigCmd.String("from", "", "多签地址")
+	flagSpendMultiSigToArg := spendMultiSigCmd.String("to", "", "转账目标地址")
+	flagSpendMultiSigAmountArg := spendMultiSigCmd.Int("amount", 0, "转账金额")
+	flagSpendMultiSigFeeArg := spendMultiSigCmd.Int("fee", 0, "手续费")
+	flagSignMultiSigTxArg := signMultiSigCmd.String("tx", "", "十六进制的部分签名交易")
+	// 原始交易参数
+	flagRawInputsArg := createRawTransactionCmd.String("inputs", "", "输入列表")
+	flagRawOutputsArg := createRawTransactionCmd.String("outputs", "", "输出列表")
+	flagRawLockTimeArg := createRawTransactionCmd.Uint("locktime", 0, "锁定时间（区块高度或者时间戳）")
+	flagSignRawTxArg := signRawTransactionCmd.String("tx", "", "十六进制的原始交易")
+	flagSendRawTxArg := sendRawTransactionCmd.String("tx", "", "十六进制的原始交易")
 	// 端口号参数
 	flagPortArg := setNodeIdCmd.String("port", "", "设置节点 ID")
 
@@ -131,6 +291,54 @@ func (cli *CLI) Run() {
 		if err := sendCmd.Parse(os.Args[2:]); nil != err {
 			log.Panicf("parse send failed! %v\n", err)
 		}
+	case "merkleproof" :
+		if err := merkleProofCmd.Parse(os.Args[2:]); nil != err {
+			log.Panicf("parse cmd merkle proof failed! %v\n", err)
+		}
+	case "mine" :
+		if err := mineCmd.Parse(os.Args[2:]); nil != err {
+			log.Panicf("parse cmd mine failed! %v\n", err)
+		}
+	case "generate" :
+		if err := generateCmd.Parse(os.Args[2:]); nil != err {
+			log.Panicf("parse cmd generate failed! %v\n", err)
+		}
+	case "getrawmempool" :
+		if err := getRawMempoolCmd.Parse(os.Args[2:]); nil != err {
+			log.Panicf("parse cmd get raw mempool failed! %v\n", err)
+		}
+	case "getmempoolentry" :
+		if err := getMempoolEntryCmd.Parse(os.Args[2:]); nil != err {
+			log.Panicf("parse cmd get mempool entry failed! %v\n", err)
+		}
+	case "createmultisig" :
+		if err := createMultiSigCmd.Parse(os.Args[2:]); nil != err {
+			log.Panicf("parse cmd create multisig failed! %v\n", err)
+		}
+	case "spendmultisig" :
+		if err := spendMultiSigCmd.Parse(os.Args[2:]); nil != err {
+			log.Panicf("parse cmd spend multisig failed! %v\n", err)
+		}
+	case "signmultisig" :
+		if err := signMultiSigCmd.Parse(os.Args[2:]); nil != err {
+			log.Panicf("parse cmd sign multisig failed! %v\n", err)
+		}
+	case "createrawtransaction" :
+		if err := createRawTransactionCmd.Parse(os.Args[2:]); nil != err {
+			log.Panicf("parse cmd create raw transaction failed! %v\n", err)
+		}
+	case "signrawtransaction" :
+		if err := signRawTransactionCmd.Parse(os.Args[2:]); nil != err {
+			log.Panicf("parse cmd sign raw transaction failed! %v\n", err)
+		}
+	case "sendrawtransaction" :
+		if err := sendRawTransactionCmd.Parse(os.Args[2:]); nil != err {
+			log.Panicf("parse cmd send raw transaction failed! %v\n", err)
+		}
+	case "migrate" :
+		if err := migrateCmd.Parse(os.Args[2:]); nil != err {
+			log.Panicf("parse cmd migrate failed! %v\n", err)
+		}
 	case "printchain" :
 		if err := printchainCmd.Parse(os.Args[2:]); nil != err {
 			log.Panicf("parse createBLCWithGenesisBlockCmd failed! %v\n", err)
@@ -153,16 +361,27 @@ func (cli *CLI) Run() {
 
 	// 创建区块链
 	if createBLCWithGenesisBlockCmd.Parsed() {
-		if *flagCreateBlockchainArg	== "" {
-			PrintUsage()
-			os.Exit(1)
+		if *flagCreateBlockchainSpecArg != "" {
+			cli.createBlockchainFromSpec(*flagCreateBlockchainSpecArg, nodeId)
+		} else if *flagCreateBlockchainArg == "" {
+			cli.createNetworkBlockchain(nodeId)
+		} else {
+			var signers []string
+			if *flagCreateBlockchainSignersArg != "" {
+				signers = utils.JSONToSlice(*flagCreateBlockchainSignersArg)
+			}
+			cli.createBlockchain(*flagCreateBlockchainArg, *flagCreateBlockchainConsensusArg, signers, nodeId)
 		}
-		cli.createBlockchain(*flagCreateBlockchainArg, nodeId)
 	}
 
+
 	// 节点启动服务
 	if startNodeCmd.Parsed() {
-		cli.startNode(nodeId)
+		if *flagStartMineArg && *flagStartMinerAddressArg == "" {
+			fmt.Println("挖矿需要指定矿工地址...")
+			os.Exit(1)
+		}
+		cli.startNode(nodeId, *flagStartMineArg, *flagStartMinerAddressArg, *flagStartWorkersArg)
 	}
 
 	// 节点 ID 设置
@@ -213,11 +432,114 @@ func (cli *CLI) Run() {
 		fmt.Printf("\tFROM:[%s]\n", utils.JSONToSlice(*flagSendFromArg))
 		fmt.Printf("\tTO:[%s]\n", utils.JSONToSlice(*flagSendToArg))
 		fmt.Printf("\tAMOUNT:[%s]\n", utils.JSONToSlice(*flagSendAmountArg))
-		cli.send(utils.JSONToSlice(*flagSendFromArg), utils.JSONToSlice(*flagSendToArg), utils.JSONToSlice(*flagSendAmountArg), nodeId)
+		if *flagSendLockTimeArg > math.MaxUint32 {
+			fmt.Println("锁定时间超出范围...")
+			os.Exit(1)
+		}
+		data, err := hex.DecodeString(*flagSendDataArg)
+		if nil != err {
+			fmt.Printf("数据必须是十六进制格式：%v\n", err)
+			os.Exit(1)
+		}
+		cli.send(utils.JSONToSlice(*flagSendFromArg), utils.JSONToSlice(*flagSendToArg), utils.JSONToSlice(*flagSendAmountArg), *flagSendFeeArg,
+			uint32(*flagSendLockTimeArg), data, nodeId)
+	}
+
+	// 挖矿
+	if mineCmd.Parsed() {
+		if *flagMineAddressArg == "" {
+			fmt.Println("矿工地址不能为空...")
+			os.Exit(1)
+		}
+		cli.mine(*flagMineAddressArg, *flagMineWorkersArg, nodeId)
+	}
+	if generateCmd.Parsed() {
+		if *flagGenerateAddressArg == "" || *flagGenerateNArg <= 0 {
+			fmt.Println("矿工地址不能为空，区块数量必须大于 0...")
+			os.Exit(1)
+		}
+		cli.generate(*flagGenerateNArg, *flagGenerateAddressArg, *flagGenerateWorkersArg, nodeId)
+	}
+
+	// 交易池查询
+	if getRawMempoolCmd.Parsed() {
+		cli.getRawMempool(nodeId)
+	}
+	if getMempoolEntryCmd.Parsed() {
+		if *flagMempoolTxArg == "" {
+			fmt.Println("交易哈希不能为空...")
+			os.Exit(1)
+		}
+		cli.getMempoolEntry(*flagMempoolTxArg, nodeId)
+	}
+
+	// 多重签名
+	if createMultiSigCmd.Parsed() {
+		if *flagMultiSigKeysArg == "" {
+			fmt.Println("公钥列表不能为空...")
+			os.Exit(1)
+		}
+		cli.createMultiSig(*flagMultiSigMArg, utils.JSONToSlice(*flagMultiSigKeysArg), nodeId)
+	}
+	if spendMultiSigCmd.Parsed() {
+		if *flagSpendMultiSigFromArg == "" || *flagSpendMultiSigToArg == "" {
+			fmt.Println("源地址与目标地址不能为空...")
+			os.Exit(1)
+		}
+		cli.spendMultiSig(*flagSpendMultiSigFromArg, *flagSpendMultiSigToArg, *flagSpendMultiSigAmountArg,
+			*flagSpendMultiSigFeeArg, nodeId)
+	}
+	if signMultiSigCmd.Parsed() {
+		if *flagSignMultiSigTxArg == "" {
+			fmt.Println("交易不能为空...")
+			os.Exit(1)
+		}
+		cli.signMultiSig(*flagSignMultiSigTxArg, nodeId)
+	}
+
+	// 原始交易
+	if createRawTransactionCmd.Parsed() {
+		if *flagRawInputsArg == "" || *flagRawOutputsArg == "" {
+			fmt.Println("输入与输出不能为空...")
+			os.Exit(1)
+		}
+		if *flagRawLockTimeArg > math.MaxUint32 {
+			fmt.Println("锁定时间超出范围...")
+			os.Exit(1)
+		}
+		cli.createRawTransaction(*flagRawInputsArg, *flagRawOutputsArg, uint32(*flagRawLockTimeArg))
+	}
+	if signRawTransactionCmd.Parsed() {
+		if *flagSignRawTxArg == "" {
+			fmt.Println("交易不能为空...")
+			os.Exit(1)
+		}
+		cli.signRawTransaction(*flagSignRawTxArg, nodeId)
+	}
+	if sendRawTransactionCmd.Parsed() {
+		if *flagSendRawTxArg == "" {
+			fmt.Println("交易不能为空...")
+			os.Exit(1)
+		}
+		cli.sendRawTransaction(*flagSendRawTxArg, nodeId)
+	}
+
+	// 数据库迁移
+	if migrateCmd.Parsed() {
+		cli.migrate(nodeId)
+	}
+
+	// 输出 Merkle 证明
+	if merkleProofCmd.Parsed() {
+		if *flagProofBlockArg == "" || *flagProofTxArg == "" {
+			fmt.Println("区块哈希与交易哈希不能为空...")
+			os.Exit(1)
+		}
+		cli.merkleProof(*flagProofBlockArg, *flagProofTxArg, nodeId)
 	}
 
 	// 输出区块链
 	if printchainCmd.Parsed() {
-		cli.printChain(nodeId)
+		cli.printChain(nodeId, *flagPrintHeadersArg)
 	}
 }
\ No newline at end of file
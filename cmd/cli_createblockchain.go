@@ -2,14 +2,62 @@ package cmd
 
 import (
 	"bkc/core"
+	"fmt"
+	"os"
 )
 
-// createBlockchain 初始化区块链
-func (cli *CLI) createBlockchain(address string, nodeId string) {
+// createBlockchain 初始化区块链，consensus 为 poa 时 signers 为签名者的本地钱包地址或者十六进制公钥
+func (cli *CLI) createBlockchain(address string, consensus string, signers []string, nodeId string) {
+	CheckAddresses(address)
+	signerKeys := pubKeysFromKeys(signers, core.NewWallets(nodeId))
+	if err := core.CheckConsensusParams(consensus, signerKeys); nil != err {
+		fmt.Printf("共识参数无效：%v\n", err)
+		os.Exit(1)
+	}
+	params := *core.ActiveParams
+	params.Consensus, params.Signers = consensus, signerKeys
+	core.ActiveParams = &params
 	bc := core.CreateBlockChain(address, nodeId)
 	defer bc.DB.Close()
 
 	// 设置 utxo 重置操作
-	utxoSet := &core.UTXOSet{bc}
+	utxoSet := &core.UTXOSet{Blockchain: bc}
 	utxoSet.ResetUTXOSet()
-}
\ No newline at end of file
+	fmt.Printf("创世区块哈希：%x\n", bc.TipHash())
+}
+
+// createBlockchainFromSpec 按照链规格文件初始化区块链，输出创世区块哈希，方便与其他节点核对
+func (cli *CLI) createBlockchainFromSpec(specFile string, nodeId string) {
+	spec, err := core.LoadChainSpec(specFile)
+	if nil != err {
+		fmt.Printf("读取链规格文件失败：%v\n", err)
+		os.Exit(1)
+	}
+	bc, err := core.CreateBlockChainFromSpec(spec, nodeId)
+	if nil != err {
+		fmt.Printf("创建区块链失败：%v\n", err)
+		os.Exit(1)
+	}
+	defer bc.DB.Close()
+
+	// 设置 utxo 重置操作
+	utxoSet := &core.UTXOSet{Blockchain: bc}
+	utxoSet.ResetUTXOSet()
+	fmt.Printf("创世区块哈希：%x\n", bc.TipHash())
+}
+
+// createNetworkBlockchain 使用当前网络预设的创世区块初始化区块链，同一网络的所有节点得到相同的创世区块
+func (cli *CLI) createNetworkBlockchain(nodeId string) {
+	params := *core.NetworkParams
+	bc, err := core.CreateBlockChainWithParams(&params, nodeId)
+	if nil != err {
+		fmt.Printf("创建区块链失败：%v\n", err)
+		os.Exit(1)
+	}
+	defer bc.DB.Close()
+
+	// 设置 utxo 重置操作
+	utxoSet := &core.UTXOSet{Blockchain: bc}
+	utxoSet.ResetUTXOSet()
+	fmt.Printf("创世区块哈希：%x\n", bc.TipHash())
+}
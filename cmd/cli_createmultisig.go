@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"bkc/core"
+	"encoding/hex"
+	"fmt"
+	"os"
+)
+
+// createMultiSig 创建 m-of-n 多签地址，keys 为本地钱包地址或者十六进制公钥，赎回脚本保存到钱包文件中
+func (cli *CLI) createMultiSig(m int, keys []string, nodeId string) {
+	wallets := core.NewWallets(nodeId)
+	pubKeys := pubKeysFromKeys(keys, wallets)
+	redeemScript, err := core.MultiSigScript(m, pubKeys)
+	if nil != err {
+		fmt.Printf("创建多签地址失败：%v\n", err)
+		os.Exit(1)
+	}
+	address := wallets.AddScript(redeemScript, nodeId)
+	fmt.Printf("多签地址：%s\n", address)
+	fmt.Printf("赎回脚本：%x\n", redeemScript)
+	fmt.Printf("\t%s\n", core.DisasmScript(redeemScript))
+}
+
+// pubKeysFromKeys 把本地钱包地址或者十六进制公钥转换为公钥列表
+func pubKeysFromKeys(keys []string, wallets *core.Wallets) [][]byte {
+	var pubKeys [][]byte
+	for _, key := range keys {
+		if wallet := wallets.WalletForAddress(key); nil != wallet {
+			pubKeys = append(pubKeys, wallet.PublicKey)
+			continue
+		}
+		pubKey, err := hex.DecodeString(key)
+		if nil != err {
+			fmt.Printf("[%s] 既不是本地钱包地址，也不是十六进制公钥\n", key)
+			os.Exit(1)
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+	return pubKeys
+}
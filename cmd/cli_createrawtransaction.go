@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"bkc/core"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// rawInput 原始交易的输入参数，sequence、blocks、seconds 最多指定一个
+type rawInput struct {
+	TxId     string  `json:"txid"`     // 引用的交易哈希
+	Vout     int     `json:"vout"`     // 引用的输出索引
+	Sequence *uint32 `json:"sequence"` // 序列号
+	Blocks   int64   `json:"blocks"`   // 相对锁定的区块数
+	Seconds  int64   `json:"seconds"`  // 相对锁定的秒数
+}
+
+// rawOutput 原始交易的输出参数，指定 data 时为携带数据的输出
+type rawOutput struct {
+	Address string `json:"address"` // 接收地址
+	Amount  int    `json:"amount"`  // 金额
+	Data    string `json:"data"`    // 十六进制数据
+}
+
+// createRawTransaction 生成未签名的原始交易
+// inputs：[{"txid":"...","vout":0,"blocks":10}]，outputs：[{"address":"...","amount":5},{"data":"..."}]
+func (cli *CLI) createRawTransaction(inputs string, outputs string, lockTime uint32) {
+	var rawInputs []rawInput
+	var rawOutputs []rawOutput
+	if err := json.Unmarshal([]byte(inputs), &rawInputs); nil != err {
+		fmt.Printf("输入格式错误：%v\n", err)
+		os.Exit(1)
+	}
+	if err := json.Unmarshal([]byte(outputs), &rawOutputs); nil != err {
+		fmt.Printf("输出格式错误：%v\n", err)
+		os.Exit(1)
+	}
+	if len(rawInputs) == 0 || len(rawOutputs) == 0 {
+		fmt.Println("输入与输出不能为空...")
+		os.Exit(1)
+	}
+	var vins []*core.TxInput
+	for i, in := range rawInputs {
+		txHash, err := hex.DecodeString(in.TxId)
+		if nil != err || len(txHash) == 0 {
+			fmt.Printf("输入 %d 的交易哈希格式错误\n", i)
+			os.Exit(1)
+		}
+		sequence, err := rawInputSequence(in, lockTime)
+		if nil != err {
+			fmt.Printf("输入 %d 的序列号错误：%v\n", i, err)
+			os.Exit(1)
+		}
+		vins = append(vins, &core.TxInput{TxHash: txHash, Vout: in.Vout, Sequence: sequence})
+	}
+	var vouts []*core.TxOutput
+	for i, out := range rawOutputs {
+		if out.Data != "" {
+			data, err := hex.DecodeString(out.Data)
+			if nil != err {
+				fmt.Printf("输出 %d 的数据必须是十六进制格式：%v\n", i, err)
+				os.Exit(1)
+			}
+			vout, err := core.NewDataTxOutput(data)
+			if nil != err {
+				fmt.Printf("输出 %d 的数据错误：%v\n", i, err)
+				os.Exit(1)
+			}
+			vouts = append(vouts, vout)
+			continue
+		}
+		if out.Amount <= 0 {
+			fmt.Printf("输出 %d 的金额必须大于 0\n", i)
+			os.Exit(1)
+		}
+		CheckAddresses(out.Address)
+		vouts = append(vouts, core.NewTxOutput(out.Amount, out.Address))
+	}
+	tx := core.NewRawTransaction(vins, vouts, lockTime)
+	fmt.Printf("未签名交易 [%x]，请使用 signrawtransaction -tx 签名：\n", tx.TxHash)
+	fmt.Printf("%x\n", tx.Serialize())
+}
+
+// rawInputSequence 计算输入的序列号：没有指定时为 MaxSequence，有锁定时间时为 MaxSequence - 1（使锁定时间生效）
+func rawInputSequence(in rawInput, lockTime uint32) (uint32, error) {
+	switch {
+	case nil != in.Sequence && (in.Blocks != 0 || in.Seconds != 0) || in.Blocks != 0 && in.Seconds != 0:
+		return 0, fmt.Errorf("only one of sequence, blocks and seconds can be set")
+	case nil != in.Sequence:
+		return *in.Sequence, nil
+	case in.Blocks != 0:
+		return core.RelativeLockBlocks(in.Blocks)
+	case in.Seconds != 0:
+		return core.RelativeLockSeconds(in.Seconds)
+	case lockTime != 0:
+		return core.MaxSequence - 1, nil
+	}
+	return core.MaxSequence, nil
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"bkc/core"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+)
+
+// generate 连续挖出 n 个区块，区块奖励与手续费支付给 address（用于脚本测试）
+func (cli *CLI) generate(n int, address string, workers int, nodeId string) {
+	if !core.DBExits(nodeId) {
+		fmt.Println("数据库不存在...")
+		os.Exit(1)
+	}
+	CheckAddresses(address)
+	blockchain := core.BlockchainObject(nodeId)
+	defer blockchain.DB.Close()
+	// Ctrl+C 中断挖矿
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	for i := 0; i < n; i++ {
+		block, err := blockchain.MineBlock(ctx, address, workers, func(progress *core.MiningProgress) {
+			fmt.Printf("碰撞次数：%d，耗时 %v，算力 %.0f 次/秒\n", progress.Hashes, progress.Elapsed, progress.HashRate)
+		})
+		if nil != ctx.Err() {
+			fmt.Println("挖矿已中断")
+			return
+		}
+		if errors.Is(err, core.ErrNotInTurn) {
+			fmt.Printf("没有轮到本节点出块：%v\n", err)
+			os.Exit(1)
+		}
+		if nil != err {
+			fmt.Printf("区块添加失败：%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("挖出新区块 [%x]，高度 %d，交易 %d 笔\n", block.Hash, block.Height, len(block.Txs))
+	}
+}
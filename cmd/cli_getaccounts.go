@@ -9,7 +9,19 @@ import (
 func (cli *CLI) GetAccounts(nodeId string) {
 	wallets := core.NewWallets(nodeId)
 	fmt.Println("账号列表")
-	for address := range wallets.Wallets {
+	for address, wallet := range wallets.Wallets {
 		fmt.Printf("\t[%s]\n", address)
+		fmt.Printf("\t\tbech32：%s\n", wallet.GetBech32Address())
+		fmt.Printf("\t\t公钥：%x\n", wallet.PublicKey)
+	}
+	if len(wallets.Scripts) == 0 {
+		return
+	}
+	fmt.Println("多签地址列表")
+	for address, script := range wallets.Scripts {
+		fmt.Printf("\t[%s]\n", address)
+		bech32Address, _ := core.Bech32Address(address)
+		fmt.Printf("\t\tbech32：%s\n", bech32Address)
+		fmt.Printf("\t\t赎回脚本：%s\n", core.DisasmScript(script))
 	}
 }
\ No newline at end of file
@@ -7,6 +7,7 @@ import (
 
 // getBalance 查询余额
 func (cli *CLI) getBalance(from string, nodeId string) {
+	CheckAddresses(from)
 	// 查找该地址 UTXO
 	// 获取区块链对象
 	blockchain := core.BlockchainObject(nodeId)
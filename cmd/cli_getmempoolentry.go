@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"bkc/core"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+)
+
+// getMempoolEntry 输出交易池中指定交易的详细信息
+func (cli *CLI) getMempoolEntry(txHash string, nodeId string) {
+	if !core.DBExits(nodeId) {
+		fmt.Println("数据库不存在...")
+		os.Exit(1)
+	}
+	txHashBytes, err := hex.DecodeString(txHash)
+	if nil != err {
+		fmt.Printf("交易哈希格式错误：%v\n", err)
+		os.Exit(1)
+	}
+	blockchain := core.BlockchainObject(nodeId)
+	defer blockchain.DB.Close()
+	mempool := &core.Mempool{Blockchain: blockchain}
+	entry := mempool.GetEntry(txHashBytes)
+	if nil == entry {
+		fmt.Printf("交易 [%s] 不在交易池中\n", txHash)
+		os.Exit(1)
+	}
+	fmt.Printf("交易：%x\n", entry.Tx.TxHash)
+	fmt.Printf("大小：%d\n", len(entry.Tx.Serialize()))
+	fmt.Printf("进入时间：%s\n", time.Unix(entry.Time, 0).Format("2006-01-02 15:04:05"))
+	fmt.Printf("手续费：%d\n", entry.Fee)
+	fmt.Println("依赖交易：")
+	for _, hash := range entry.Depends {
+		fmt.Printf("\t%x\n", hash)
+	}
+	fmt.Println("被依赖交易：")
+	for _, hash := range entry.SpentBy {
+		fmt.Printf("\t%x\n", hash)
+	}
+}
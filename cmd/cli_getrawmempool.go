@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"bkc/core"
+	"fmt"
+	"os"
+)
+
+// getRawMempool 输出交易池中所有交易的哈希
+func (cli *CLI) getRawMempool(nodeId string) {
+	if !core.DBExits(nodeId) {
+		fmt.Println("数据库不存在...")
+		os.Exit(1)
+	}
+	blockchain := core.BlockchainObject(nodeId)
+	defer blockchain.DB.Close()
+	mempool := &core.Mempool{Blockchain: blockchain}
+	hashes := mempool.GetRawMempool()
+	fmt.Printf("交易池中共有 %d 笔交易\n", len(hashes))
+	for _, hash := range hashes {
+		fmt.Printf("\t%x\n", hash)
+	}
+}
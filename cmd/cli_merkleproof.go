@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"bkc/core"
+	"encoding/hex"
+	"fmt"
+	"os"
+)
+
+// merkleProof 输出指定交易存在于指定区块中的证明，只持有区块头的用户可以凭此验证交易
+func (cli *CLI) merkleProof(blockHash, txHash string, nodeId string) {
+	if !core.DBExits(nodeId) {
+		fmt.Println("数据库不存在...")
+		os.Exit(1)
+	}
+	blockHashBytes, err := hex.DecodeString(blockHash)
+	if nil != err {
+		fmt.Printf("区块哈希格式错误：%v\n", err)
+		os.Exit(1)
+	}
+	txHashBytes, err := hex.DecodeString(txHash)
+	if nil != err {
+		fmt.Printf("交易哈希格式错误：%v\n", err)
+		os.Exit(1)
+	}
+	blockchain := core.BlockchainObject(nodeId)
+	defer blockchain.DB.Close()
+	blockBytes := blockchain.GetBlock(blockHashBytes)
+	if nil == blockBytes {
+		fmt.Printf("区块 [%s] 不存在\n", blockHash)
+		os.Exit(1)
+	}
+	block := core.Deserialize(blockBytes)
+	proof, err := block.BuildMerkleTree().Proof(txHashBytes)
+	if nil != err {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("区块：%x\n", block.Hash)
+	fmt.Printf("高度：%d\n", block.Height)
+	fmt.Printf("Merkle 根：%x\n", block.MerkleRoot)
+	fmt.Printf("交易：%x\n", txHashBytes)
+	fmt.Printf("位置：%d\n", proof.Index)
+	fmt.Println("证明路径：")
+	for _, hash := range proof.Hashes {
+		fmt.Printf("\t%x\n", hash)
+	}
+	fmt.Printf("验证结果：%v\n", core.VerifyProof(block.MerkleRoot, txHashBytes, proof))
+}
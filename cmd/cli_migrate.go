@@ -0,0 +1,16 @@
+package cmd
+
+import (
+	"bkc/core"
+	"fmt"
+	"os"
+)
+
+// migrate 把旧版本（gob 编码）的数据库迁移为规范编码
+func (cli *CLI) migrate(nodeId string) {
+	if err := core.MigrateBlockChain(nodeId); nil != err {
+		fmt.Printf("迁移失败：%v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("迁移完成，旧数据库已保存为 .legacy 文件")
+}
@@ -0,0 +1,6 @@
+package cmd
+
+// mine 把交易池中的交易打包进一个新区块，区块奖励与手续费支付给 address，workers 为挖矿的并行线程数
+func (cli *CLI) mine(address string, workers int, nodeId string) {
+	cli.generate(1, address, workers, nodeId)
+}
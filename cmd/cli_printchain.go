@@ -6,12 +6,17 @@ import (
 	"os"
 )
 
-// printChain 打印完整区块链信息
-func (cli *CLI) printChain(nodeId string) {
+// printChain 打印完整区块链信息，headersOnly：只输出区块头摘要
+func (cli *CLI) printChain(nodeId string, headersOnly bool) {
 	if !core.DBExits(nodeId) {
 		fmt.Println("数据库不存在...")
 		os.Exit(1)
 	}
 	blockchain := core.BlockchainObject(nodeId)
+	defer blockchain.DB.Close()
+	if headersOnly {
+		blockchain.PrintHeaders()
+		return
+	}
 	blockchain.PrintChain()
 }
\ No newline at end of file
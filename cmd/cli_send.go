@@ -6,22 +6,43 @@ import (
 	"os"
 )
 
-// send 发起交易
-func (cli *CLI) send(from, to, amount []string, nodeId string)  {
+// send 发起交易，每笔交易支付 fee 作为手续费，lockTime 不为 0 时交易在锁定时间（区块高度或者时间戳）之后才能被打包，
+// data 不为空时每笔交易都携带一个数据输出
+func (cli *CLI) send(from, to, amount []string, fee int, lockTime uint32, data []byte, nodeId string)  {
 	if !core.DBExits(nodeId) {
 		fmt.Println("数据库不存在...")
 		os.Exit(1)
 	}
+	if len(from) != len(to) || len(from) != len(amount) {
+		fmt.Println("交易参数输入有误，请检查一致性...")
+		os.Exit(1)
+	}
+	CheckAddresses(from...)
+	CheckAddresses(to...)
+	if fee < 0 {
+		fmt.Println("手续费不能为负数...")
+		os.Exit(1)
+	}
 	// 获取区块链对象
 	blockchain := core.BlockchainObject(nodeId)
 	defer blockchain.DB.Close()
-	if len(from) != len(to) && len(from) != len(amount) {
-		fmt.Println("交易参数输入有误，请检查一致性...")
+	// 发起交易，只提交到交易池，不会挖矿
+	txs, err := blockchain.SendTransactions(from, to, amount, fee, lockTime, data, nodeId)
+	// 尚未到达锁定时间的交易不会进入交易池
+	var locked *core.Transaction
+	if core.IsRejectReason(err, core.RejectNonFinal) {
+		locked, txs, err = txs[len(txs)-1], txs[:len(txs)-1], nil
+	}
+	for _, tx := range txs {
+		fmt.Printf("交易 [%x] 已提交到交易池\n", tx.TxHash)
+	}
+	if nil != locked {
+		fmt.Printf("交易 [%x] 尚未到达锁定时间 %d，请在之后使用 sendrawtransaction -tx 提交：\n", locked.TxHash, lockTime)
+		fmt.Printf("%x\n", locked.Serialize())
+	}
+	if nil != err {
+		fmt.Printf("交易被拒绝：%v\n", err)
 		os.Exit(1)
 	}
-	// 发起交易，生成新的区块
-	blockchain.MineNewBlock(from, to, amount, nodeId)
-	// 调用 utxo table 的函数，更新 utxo table
-	utxoSet := &core.UTXOSet{Blockchain: blockchain}
-	utxoSet.Update()
-}
\ No newline at end of file
+	// 交易等待矿工打包（mine、generate 或者 start -mine），节点启动之后会把交易池中的交易广播给其他节点
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"bkc/core"
+	"encoding/hex"
+	"fmt"
+	"os"
+)
+
+// sendRawTransaction 把已签名的原始交易提交到交易池
+func (cli *CLI) sendRawTransaction(txHex string, nodeId string) {
+	if !core.DBExits(nodeId) {
+		fmt.Println("数据库不存在...")
+		os.Exit(1)
+	}
+	tx := decodeTransactionHex(txHex)
+	blockchain := core.BlockchainObject(nodeId)
+	defer blockchain.DB.Close()
+	mempool := &core.Mempool{Blockchain: blockchain}
+	if err := mempool.AcceptTransaction(tx); nil != err {
+		fmt.Printf("交易被拒绝：%v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("交易 [%x] 已提交到交易池\n", tx.TxHash)
+}
+
+// decodeTransactionHex 解码十六进制的交易，格式错误时退出
+func decodeTransactionHex(txHex string) *core.Transaction {
+	txBytes, err := hex.DecodeString(txHex)
+	if nil != err {
+		fmt.Printf("交易格式错误：%v\n", err)
+		os.Exit(1)
+	}
+	tx, err := core.DecodeTransaction(txBytes)
+	if nil != err {
+		fmt.Printf("交易格式错误：%v\n", err)
+		os.Exit(1)
+	}
+	return tx
+}
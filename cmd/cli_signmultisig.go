@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"bkc/core"
+	"fmt"
+	"os"
+)
+
+// signMultiSig 使用本地钱包中的私钥为部分签名交易添加签名，签名数量达到要求后提交到交易池
+func (cli *CLI) signMultiSig(txHex string, nodeId string) {
+	if !core.DBExits(nodeId) {
+		fmt.Println("数据库不存在...")
+		os.Exit(1)
+	}
+	tx := decodeTransactionHex(txHex)
+	blockchain := core.BlockchainObject(nodeId)
+	defer blockchain.DB.Close()
+	cli.signAndSubmitMultiSig(tx, core.NewWallets(nodeId), &core.Mempool{Blockchain: blockchain})
+}
+
+// signAndSubmitMultiSig 添加本地签名，签名数量足够时提交到交易池，否则输出部分签名交易
+func (cli *CLI) signAndSubmitMultiSig(tx *core.Transaction, wallets *core.Wallets, mempool *core.Mempool) {
+	added, err := tx.SignMultiSig(wallets)
+	if nil != err {
+		fmt.Printf("签名失败：%v\n", err)
+		os.Exit(1)
+	}
+	have, need, err := tx.MultiSigProgress()
+	if nil != err {
+		fmt.Printf("签名失败：%v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("新增签名 %d 个，当前签名 %d/%d\n", added, have, need)
+	if have < need {
+		fmt.Println("签名数量不足，请把部分签名交易交给其他私钥持有者执行 signmultisig -tx：")
+		fmt.Printf("%x\n", tx.Serialize())
+		return
+	}
+	if err := tx.FinalizeMultiSig(); nil != err {
+		fmt.Printf("签名失败：%v\n", err)
+		os.Exit(1)
+	}
+	if err := mempool.AcceptTransaction(tx); nil != err {
+		fmt.Printf("交易被拒绝：%v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("交易 [%x] 已提交到交易池\n", tx.TxHash)
+}
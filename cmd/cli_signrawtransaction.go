@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"bkc/core"
+	"fmt"
+	"os"
+)
+
+// signRawTransaction 使用本地钱包中的私钥为原始交易签名，输出签名之后的交易
+func (cli *CLI) signRawTransaction(txHex string, nodeId string) {
+	if !core.DBExits(nodeId) {
+		fmt.Println("数据库不存在...")
+		os.Exit(1)
+	}
+	tx := decodeTransactionHex(txHex)
+	blockchain := core.BlockchainObject(nodeId)
+	defer blockchain.DB.Close()
+	signed, err := blockchain.SignRawTransaction(tx, core.NewWallets(nodeId))
+	if nil != err {
+		fmt.Printf("签名失败：%v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("已签名输入 %d/%d，交易 [%x]：\n", signed, len(tx.Vins), tx.TxHash)
+	fmt.Printf("%x\n", tx.Serialize())
+}
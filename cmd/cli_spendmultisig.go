@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"bkc/core"
+	"fmt"
+	"os"
+)
+
+// spendMultiSig 发起花费多签地址的转账，使用本地钱包中的私钥签名，签名数量不足时输出部分签名交易
+func (cli *CLI) spendMultiSig(from, to string, amount, fee int, nodeId string) {
+	if !core.DBExits(nodeId) {
+		fmt.Println("数据库不存在...")
+		os.Exit(1)
+	}
+	CheckAddresses(from, to)
+	if amount <= 0 || fee < 0 {
+		fmt.Println("转账金额必须大于 0，手续费不能为负数...")
+		os.Exit(1)
+	}
+	wallets := core.NewWallets(nodeId)
+	redeemScript, ok := wallets.ScriptForAddress(from)
+	if !ok {
+		fmt.Printf("钱包中没有多签地址 [%s] 的赎回脚本，请先执行 createmultisig\n", from)
+		os.Exit(1)
+	}
+	blockchain := core.BlockchainObject(nodeId)
+	defer blockchain.DB.Close()
+	mempool := &core.Mempool{Blockchain: blockchain}
+	tx, err := core.NewMultiSigTransaction(from, to, amount, fee, redeemScript, blockchain, mempool.Transactions())
+	if nil != err {
+		fmt.Printf("生成交易失败：%v\n", err)
+		os.Exit(1)
+	}
+	cli.signAndSubmitMultiSig(tx, wallets, mempool)
+}
@@ -1,8 +1,15 @@
 package cmd
 
-import "bkc/network"
+import (
+	"bkc/network"
+)
 
-// startNode 节点启动服务
-func (cli *CLI) startNode(nodeId string) {
-	network.StartServer(nodeId)
-}
\ No newline at end of file
+// startNode 节点启动服务，mine 为 true 时同时使用 workers 个线程持续挖矿，奖励支付给 minerAddress
+func (cli *CLI) startNode(nodeId string, mine bool, minerAddress string, workers int) {
+	if mine {
+		CheckAddresses(minerAddress)
+	} else {
+		minerAddress = ""
+	}
+	network.StartServer(nodeId, minerAddress, workers)
+}
@@ -0,0 +1,147 @@
+package core
+
+import (
+	"bkc/utils"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// 地址管理文件
+
+// 同一个 hash160 有两种地址形式，两种形式可以互换使用：
+//   Base58Check：base58(版本前缀 + hash160 + 校验和)，
+//     版本前缀区分网络与地址类型（公钥哈希地址或者脚本哈希地址），校验和为两次 sha256 的前 4 个字节
+//   Bech32：可读前缀 + "1" + 地址类型 + hash160 + 校验和，
+//     可读前缀区分网络，地址类型占一个字符（0 为公钥哈希地址，1 为脚本哈希地址），全部小写
+
+// bech32 地址类型
+const (
+	bech32PubKeyHash = 0
+	bech32ScriptHash = 1
+)
+
+// 地址解码错误，编码错误见 utils.ErrInvalidBase58、utils.ErrInvalidFormat、utils.ErrChecksum、
+// utils.ErrInvalidBech32、utils.ErrBech32Checksum
+var (
+	// ErrAddressLength 地址解码得到的 hash160 不是 20 字节
+	ErrAddressLength = errors.New("invalid address length")
+	// ErrAddressNetwork 地址的版本前缀（或者可读前缀）不属于当前网络
+	ErrAddressNetwork = errors.New("the address belongs to another network")
+	// ErrAddressType 未知的 bech32 地址类型
+	ErrAddressType = errors.New("unknown address type")
+)
+
+// AddressError 地址解码错误，使用 errors.Is 判断具体原因
+type AddressError struct {
+	Address string
+	Err     error
+}
+
+// Error 错误信息
+func (e *AddressError) Error() string {
+	return fmt.Sprintf("invalid address [%s]: %v", e.Address, e.Err)
+}
+
+// Unwrap 具体原因
+func (e *AddressError) Unwrap() error {
+	return e.Err
+}
+
+// encodeAddress 地址编码（Base58Check）
+func encodeAddress(version byte, hash160 []byte) string {
+	return utils.Base58CheckEncode(version, hash160)
+}
+
+// encodeBech32Address 地址编码（Bech32），version 为 Base58Check 的版本前缀
+func encodeBech32Address(version byte, hash160 []byte) string {
+	addressType := byte(bech32PubKeyHash)
+	if version == ActiveParams.ScriptHashAddrID {
+		addressType = bech32ScriptHash
+	}
+	data, err := utils.ConvertBits(hash160, 8, 5, true)
+	if nil != err {
+		log.Panicf("convert the hash160 [%x] failed %v\n", hash160, err)
+	}
+	address, err := utils.Bech32Encode(ActiveParams.Bech32HRP, append([]byte{addressType}, data...))
+	if nil != err {
+		log.Panicf("encode the bech32 address failed %v\n", err)
+	}
+	return address
+}
+
+// decodeBech32Address 地址解码（Bech32），返回对应的 Base58Check 版本前缀与 hash160
+func decodeBech32Address(address string) (byte, []byte, error) {
+	hrp, data, err := utils.Bech32Decode(address)
+	if nil != err {
+		return 0, nil, err
+	}
+	if hrp != ActiveParams.Bech32HRP {
+		return 0, nil, ErrAddressNetwork
+	}
+	if len(data) == 0 {
+		return 0, nil, ErrAddressLength
+	}
+	var version byte
+	switch data[0] {
+	case bech32PubKeyHash:
+		version = ActiveParams.PubKeyHashAddrID
+	case bech32ScriptHash:
+		version = ActiveParams.ScriptHashAddrID
+	default:
+		return 0, nil, ErrAddressType
+	}
+	hash160, err := utils.ConvertBits(data[1:], 5, 8, false)
+	if nil != err {
+		return 0, nil, err
+	}
+	return version, hash160, nil
+}
+
+// isBech32Address 判断地址是否为 bech32 形式：可读前缀与分隔符之后的部分校验和一致，
+// 或者以当前网络的可读前缀开头（此时返回 bech32 的解码错误）
+func isBech32Address(address string) bool {
+	if _, _, err := utils.Bech32Decode(address); nil == err {
+		return true
+	}
+	return strings.HasPrefix(strings.ToLower(address), ActiveParams.Bech32HRP+"1")
+}
+
+// DecodeAddress 地址解码，接受 Base58Check 与 Bech32 两种形式，返回 Base58Check 的版本前缀与 hash160，
+// 地址无效或者不属于当前网络时返回 *AddressError
+func DecodeAddress(address string) (byte, []byte, error) {
+	var version byte
+	var hash160 []byte
+	var err error
+	if isBech32Address(address) {
+		version, hash160, err = decodeBech32Address(address)
+	} else {
+		version, hash160, err = utils.Base58CheckDecode(address)
+	}
+	if nil == err && len(hash160) != 20 {
+		err = ErrAddressLength
+	}
+	if nil == err && version != ActiveParams.PubKeyHashAddrID && version != ActiveParams.ScriptHashAddrID {
+		err = ErrAddressNetwork
+	}
+	if nil != err {
+		return 0, nil, &AddressError{Address: address, Err: err}
+	}
+	return version, hash160, nil
+}
+
+// Bech32Address 把地址转换为 bech32 形式
+func Bech32Address(address string) (string, error) {
+	version, hash160, err := DecodeAddress(address)
+	if nil != err {
+		return "", err
+	}
+	return encodeBech32Address(version, hash160), nil
+}
+
+// IsValidForAddress 判断地址有效性：校验和一致，并且是当前网络的公钥哈希地址或者脚本哈希地址
+func IsValidForAddress(addressBytes []byte) bool {
+	_, _, err := DecodeAddress(string(addressBytes))
+	return nil == err
+}
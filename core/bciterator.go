@@ -20,7 +20,7 @@ type BlockChainIterator struct {
 func (blc *BlockChain) Iterator() *BlockChainIterator {
 	return &BlockChainIterator{
 		DB: blc.DB,
-		CurrentHash: blc.Tip,
+		CurrentHash: blc.TipHash(),
 	}
 }
 
@@ -1,79 +1,131 @@
 package core
 
 import (
-	"bytes"
-	"encoding/gob"
+	"context"
+	"crypto/sha256"
 	"log"
 	"time"
 )
 
 // 区块基本结构与功能管理文件
 
-// Block 实现一个最基本的区块结构
-type Block struct {
-	TimeStamp     	int64            // 时间戳
-	Hash          	[]byte            // 当前区块哈希
+// 区块版本号
+const blockVersion = 1
+
+// BlockHeader 区块头，区块哈希只由区块头计算得出，区块体中的交易通过 Merkle 根与区块头关联
+type BlockHeader struct {
+	Version			int32            // 区块版本号
 	PrevBlockHash 	[]byte           // 前区块哈希
-	Height       	int64               // 区块高度
-	Txs				[]*Transaction // 交易数据（交易列表）
+	MerkleRoot		[]byte           // 交易列表的 Merkle 根
+	TimeStamp     	int64            // 时间戳
+	Bits			uint32           // 区块难度（目标值的压缩形式）
+	Signature		[]byte           // 封装签名，poa 共识中由轮到出块的签名者签名，工作量证明区块为空
 	Nonce			int64            // 在运行 pow 时生成的哈希值，也代表 pow 运行时动态修改的数据
+	Height       	int64            // 区块高度
+}
+
+// Block 实现一个最基本的区块结构
+type Block struct {
+	BlockHeader                      // 区块头
+	Hash          	[]byte           // 当前区块哈希（区块头的哈希）
+	Txs				[]*Transaction   // 交易数据（交易列表）
 }
 
-// NewBlock 新建区块
-func NewBlock(height int64, prevBlockHash []byte, txs []*Transaction) *Block {
+// NewBlock 新建区块，使用当前链参数选择的共识算法封装区块
+// @bits：区块难度，由 ConsensusEngine.NextBits 计算得出
+func NewBlock(height int64, prevBlockHash []byte, bits uint32, txs []*Transaction) *Block {
 	block := Block{
-		TimeStamp:     	time.Now().Unix(),
+		BlockHeader: BlockHeader{
+			Version:       blockVersion,
+			PrevBlockHash: prevBlockHash,
+			TimeStamp:     time.Now().Unix(),
+			Bits:          bits,
+			Height:        height,
+		},
 		Hash:          	nil,
-		PrevBlockHash: 	prevBlockHash,
-		Height:        	height,
 		Txs:          	txs,
 	}
-	pow := NewProofOfWork(&block)
-	// 执行工作量证明算法
-	hash, nonce := pow.Run()
-	// 生成当前区块哈希
-	block.Hash = hash
-	block.Nonce = nonce
+	// 在区块头中记录交易列表的 Merkle 根
+	block.MerkleRoot = block.HashTransaction()
+	// 执行共识算法，生成当前区块哈希
+	err := NewConsensusEngine(ActiveParams).Seal(context.Background(), nil, &block, &SealOptions{})
+	if nil != err {
+		log.Panicf("seal the block failed %v\n", err)
+	}
 	return &block
 }
 
 // CreateGenesisBlock 生成创世块
 func CreateGenesisBlock(txs []*Transaction) *Block {
-	return NewBlock(1, nil, txs)
+	// 创世区块的难度只由链参数决定
+	bits, err := NewConsensusEngine(ActiveParams).NextBits(nil, nil)
+	if nil != err {
+		log.Panicf("calculate the genesis bits failed %v\n", err)
+	}
+	return NewBlock(1, nil, bits, txs)
 }
 
-// Serialize 区块结构序列化
+// Serialize 区块结构序列化（规范编码）
 func (block *Block) Serialize() []byte {
-	var buffer bytes.Buffer
-	// 新建编码对象
-	encoder := gob.NewEncoder(&buffer)
-	// 编码（序列化）
-	err := encoder.Encode(block)
-	if  nil != err {
+	blockBytes, err := EncodeBlock(block)
+	if nil != err {
 		log.Panicf("serialize the block to []byte failed %v\n", err)
 	}
-	return buffer.Bytes()
+	return blockBytes
 }
 
-// Deserialize 区块结构反序列化
+// Deserialize 区块结构反序列化，旧版本（gob 编码）的数据库需要先执行 migrate 命令
 func Deserialize(blockBytes []byte) *Block {
-	var block Block
-	// 新建 decoder 对象
-	decoder := gob.NewDecoder(bytes.NewReader(blockBytes))
-	if err := decoder.Decode(&block); nil != err {
-		log.Panicf("deserialize the []byte to block failed %v\n", err)
+	block, err := DecodeBlock(blockBytes)
+	if nil != err {
+		log.Panicf("deserialize the []byte to block failed (run migrate for legacy databases) %v\n", err)
 	}
-	return &block
+	return block
 }
 
 // HashTransaction 把指定区块中所有交易结构都序列化
 func (block *Block) HashTransaction() []byte {
+	// 将交易数据存入 Merkle 树中，然后生成 Merkle 根节点
+	return block.BuildMerkleTree().RootNode.Data
+}
+
+// BuildMerkleTree 使用区块中所有交易的哈希生成 Merkle 树
+func (block *Block) BuildMerkleTree() *MerkleTree {
 	var txHashes [][]byte
 	// 将指定区块中所有交易哈希进行拼接
 	for _, tx := range block.Txs {
 		txHashes = append(txHashes, tx.TxHash)
 	}
-	// 将交易数据存入 Merkle 树中，然后生成 Merkle 根节点
-	mTree := NewMerkleTree(txHashes)
-	return mTree.RootNode.Data
-}
\ No newline at end of file
+	return NewMerkleTree(txHashes)
+}
+
+// Serialize 区块头序列化（规范编码）
+func (header *BlockHeader) Serialize() []byte {
+	headerBytes, err := EncodeHeader(header)
+	if nil != err {
+		log.Panicf("serialize the block header failed %v\n", err)
+	}
+	return headerBytes
+}
+
+// Hash 计算区块头的哈希，即区块哈希
+func (header *BlockHeader) Hash() []byte {
+	hash := sha256.Sum256(header.Serialize())
+	return hash[:]
+}
+
+// SealHash 计算封装签名的哈希：不包含封装签名的区块头哈希
+func (header *BlockHeader) SealHash() []byte {
+	headerCopy := *header
+	headerCopy.Signature = nil
+	return headerCopy.Hash()
+}
+
+// DeserializeHeader 区块头反序列化
+func DeserializeHeader(headerBytes []byte) *BlockHeader {
+	header, err := DecodeHeader(headerBytes)
+	if nil != err {
+		log.Panicf("deserialize the block header failed %v\n", err)
+	}
+	return header
+}
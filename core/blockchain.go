@@ -2,6 +2,7 @@ package core
 
 import (
 	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"encoding/hex"
 	"fmt"
@@ -9,6 +10,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"sync"
 )
 
 // 区块链管理工具
@@ -17,61 +19,67 @@ import (
 var DBName = "block_%s.db"
 // BlockTableName 表名称
 var BlockTableName = "blocks"
+// HeaderTableName 区块头表名称，读取区块头时不需要反序列化区块中的交易
+var HeaderTableName = "headers"
 
 // BlockChain 区块链的基本结构
 type BlockChain struct {
 	DB		*bolt.DB	// 数据库对象
-	Tip		[]byte		// 保存最新区块的哈希值
+	tip		[]byte		// 保存最新区块的哈希值，通过 TipHash 读取
+	mutex	sync.Mutex	// 保证区块的验证与写入不会并发执行
+	tipMutex	sync.Mutex	// 保护 tip 与 tipChanged
+	tipChanged	chan struct{}	// 最新区块变化时关闭，通知正在挖矿的 goroutine
+	TimeSource	*MedianTimeSource	// 网络调整时间，为空时使用本地时间
+	nodeId		string		// 节点 ID，poa 共识从节点的钱包集合中获取签名者私钥
 }
 
-// CreateBlockChain 初始化区块链
+// CreateBlockChain 初始化区块链，创世区块把区块奖励支付给 address
 func CreateBlockChain(address string, nodeId string) *BlockChain {
+	// 生成一个 coinbase 交易
+	txCoinbase := NewCoinbaseTransaction(address, 1, 0, 0)
+	// 创建一个创世块
+	return createBlockChain(CreateGenesisBlock([]*Transaction{txCoinbase}), nodeId)
+}
+
+// CreateBlockChainFromSpec 按照链规格初始化区块链，使用同一个链规格的节点得到相同的创世区块
+func CreateBlockChainFromSpec(spec *ChainSpec, nodeId string) (*BlockChain, error) {
+	params, err := spec.Params()
+	if nil != err {
+		return nil, err
+	}
+	return CreateBlockChainWithParams(params, nodeId)
+}
+
+// CreateBlockChainWithParams 按照链参数中的创世区块规格初始化区块链，例如网络预设的创世区块
+func CreateBlockChainWithParams(params *ChainParams, nodeId string) (*BlockChain, error) {
+	genesisBlock, err := GenesisBlock(params)
+	if nil != err {
+		return nil, err
+	}
+	ActiveParams = params
+	return createBlockChain(genesisBlock, nodeId), nil
+}
+
+// createBlockChain 创建数据库，保存当前链参数与创世区块
+func createBlockChain(genesisBlock *Block, nodeId string) *BlockChain {
 	if DBExits(nodeId) {
 		// 文件已存在，说明创世区块已存在
 		fmt.Println("数据库已经存在，无需创建")
 		os.Exit(1)
 	}
 	dbFile := fmt.Sprintf(DBName, nodeId)
-	// 保存最新区块的哈希值
-	var blockHash []byte
 	// 创建或打开一个数据库
 	db, err := bolt.Open(dbFile, 0600, nil)
 	if nil != err {
 		log.Panicf("open db [%s] failed %v \n", dbFile, err)
 	}
-	// 创建桶（表）,把创世区块存入数据库
-	db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(BlockTableName))
-		if nil == b {
-			// 没找到桶
-			b, err := tx.CreateBucket([]byte(BlockTableName))
-			if nil != err {
-				log.Panicf("create bucket [%s] failed %v\n", BlockTableName, err)
-			}
-			// 生成一个 coinbase 交易
-			txCoinbase := NewCoinbaseTransaction(address)
-			// 创建一个创世块
-			genesisBlock := CreateGenesisBlock([]*Transaction{txCoinbase})
-			// 存储
-			err = b.Put(genesisBlock.Hash, genesisBlock.Serialize())
-			if nil != err {
-				log.Panicf("insert the genesis block failed %v\n", err)
-			}
-			blockHash = genesisBlock.Hash
-			// 存钱最新区块的哈希
-			err = b.Put([]byte("1"), genesisBlock.Hash)
-			if nil != err {
-				log.Panicf("saave the hash of genesis block failed %v\n", err)
-			}
-		} else {
-			blockHash = b.Get([]byte("1"))
-		}
-		return nil
-	})
-	return &BlockChain{
-		DB: db,
-		Tip: blockHash,
-	}
+	bc := &BlockChain{DB: db, nodeId: nodeId}
+	// 保存链参数，之后打开区块链时使用相同的共识规则
+	bc.putChainParams(ActiveParams)
+	// 存储创世区块及其工作量，并保存最新区块的哈希
+	bc.putBlock(genesisBlock)
+	bc.setTip(genesisBlock.Hash)
+	return bc
 }
 
 // BlockchainObject 获取一个 blockchain 对象
@@ -87,16 +95,20 @@ func BlockchainObject(nodeId string) *BlockChain {
 	err = db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(BlockTableName))
 		if nil != b {
-			tip = b.Get([]byte("1"))
+			// b.Get 返回的数据只在事务内有效，需要拷贝
+			tip = append([]byte{}, b.Get([]byte("1"))...)
 		}
 		return nil
 	})
 	if nil != err {
 		log.Panicf("get the blockchain object failed ! %v\n", err)
 	}
+	loadChainParams(db)
 	return &BlockChain{
 		DB: db,
-		Tip: tip,
+		tip: tip,
+		TimeSource: NewMedianTimeSource(),
+		nodeId: nodeId,
 	}
 }
 
@@ -110,24 +122,35 @@ func (bc *BlockChain) PrintChain() {
 		fmt.Println("-------------------------------")
 		curBlock, pre := bcit.PreBlock()
 		fmt.Printf("Hash:%x\n", curBlock.Hash)
+		fmt.Printf("Version:%d\n", curBlock.Version)
 		fmt.Printf("PrevBlockHash:%x\n", curBlock.PrevBlockHash)
+		fmt.Printf("MerkleRoot:%x\n", curBlock.MerkleRoot)
 		fmt.Printf("TimeStamp:%v\n", curBlock.TimeStamp)
 		fmt.Printf("Height:%d\n", curBlock.Height)
+		fmt.Printf("Bits:%08x\n", curBlock.Bits)
+		fmt.Printf("Difficulty:%f\n", Difficulty(curBlock.Bits))
 		fmt.Printf("Nonce:%d\n", curBlock.Nonce)
+		if len(curBlock.Signature) != 0 {
+			fmt.Printf("Signature:%x\n", curBlock.Signature)
+		}
 		fmt.Printf("Txs:%v\n", curBlock.Txs)
 		for _, tx := range curBlock.Txs {
 			fmt.Printf("\ttx-hash: %x\n", tx.TxHash)
+			fmt.Printf("\ttx-locktime: %d\n", tx.LockTime)
 			fmt.Printf("\t输入...\n")
 			for _, vin := range tx.Vins {
 				fmt.Printf("\t\ttvin-txHash: %x\n", vin.TxHash)
 				fmt.Printf("\t\ttvin-vout: %x\n", vin.Vout)
-				fmt.Printf("\t\ttvin-PublicKey: %x\n", vin.PublicKey)
-				fmt.Printf("\t\ttvin-Signature: %x\n", vin.Signature)
+				fmt.Printf("\t\ttvin-ScriptSig: %s\n", DisasmScript(vin.ScriptSig))
+				fmt.Printf("\t\ttvin-Sequence: %08x\n", vin.Sequence)
 			}
 			fmt.Printf("\t输出...\n")
 			for _, vout := range tx.Vouts {
 				fmt.Printf("\t\tvout-value:%d\n", vout.Value)
-				fmt.Printf("\t\tvout-Ripemd160Hash:%x\n", vout.Ripemd160Hash)
+				fmt.Printf("\t\tvout-ScriptPubKey:%s\n", DisasmScript(vout.ScriptPubKey))
+				if data, ok := ExtractNullData(vout.ScriptPubKey); ok {
+					fmt.Printf("\t\tvout-Data:%x\n", data)
+				}
 			}
 		}
 		if !pre {
@@ -136,63 +159,107 @@ func (bc *BlockChain) PrintChain() {
 	}
 }
 
-// MineNewBlock 实现挖矿功能：通过接收交易，生成区块
-func (bc *BlockChain) MineNewBlock(from, to, amount []string, nodeId string) {
-	var block *Block
-	// 搁置交易生成步骤
+// SendTransactions 生成转账交易并提交到交易池，每笔交易支付 fee 作为手续费
+// 交易可以花费交易池中尚未上链的输出（例如之前转账的找零）
+// lockTime 不为 0 时交易在锁定时间之后才能被打包，尚未到达锁定时间的交易不会进入交易池，
+// 它会作为返回列表中的最后一笔交易与 RejectNonFinal 错误一起返回，可以保存之后再提交
+// data 不为空时每笔交易都会携带一个数据输出
+func (bc *BlockChain) SendTransactions(from, to, amount []string, fee int, lockTime uint32, data []byte,
+	nodeId string) ([]*Transaction, error) {
+	if len(data) > ActiveParams.MaxDataCarrierSize {
+		return nil, fmt.Errorf("the data size %d is larger than %d", len(data), ActiveParams.MaxDataCarrierSize)
+	}
+	mempool := &Mempool{Blockchain: bc}
+	// 交易池中的交易以及本次生成的交易，都作为缓存交易参与 UTXO 查找与签名
+	cached := mempool.Transactions()
 	var txs []*Transaction
 	// 遍历交易参与者
 	for index, address := range from {
 		value, _ := strconv.Atoi(amount[index])
 		// 生成新的交易
-		tx := NewSimpleTransaction(address, to[index], value, bc, txs, nodeId)
-		// 追加到 txs 链表中
+		tx := NewTransferTransaction(address, to[index], value, fee, lockTime, data, bc, cached, nodeId)
+		if err := mempool.AcceptTransaction(tx); nil != err {
+			if IsRejectReason(err, RejectNonFinal) {
+				txs = append(txs, tx)
+			}
+			return txs, err
+		}
+		cached = append(cached, tx)
 		txs = append(txs, tx)
-		// 给与交易发起者（矿工）一定的奖励
-		tx = NewCoinbaseTransaction(address)
-		txs = append(txs,tx)
 	}
+	return txs, nil
+}
 
-	// 从数据库中获取最新一个区块
-	bc.DB.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(BlockTableName))
-		if nil != b {
-			// 获取最新区块的哈希值
-			hash := b.Get([]byte("1"))
-			// 获取最新区块
-			blockBytes := b.Get(hash)
-			// 反序列化
-			block = Deserialize(blockBytes)
-		}
-		return nil
-	})
-	// 在此处进行交易签名的验证，对 txs 中的每一笔交易都进行验证
-	for _, tx := range txs {
-		// 验证签名，只要有一笔交易的签名验证失败，panic
-		if !bc.VerityTransaction(tx) {
-			log.Panicf("ERROR: tx [%x] verity failed!\n", tx)
-		}
+// NewBlockTemplate 生成待挖矿的区块模板：连接在最新区块之后，包含交易池中的交易，
+// coinbase 把区块奖励与手续费支付给 minerAddress。计算难度需要的区块头缺失时返回错误
+func (bc *BlockChain) NewBlockTemplate(minerAddress string) (*Block, error) {
+	// 获取最新区块的区块头
+	tipHash := bc.TipHash()
+	tip := bc.GetHeader(tipHash)
+	bits, err := bc.Engine().NextBits(bc, tip)
+	if nil != err {
+		return nil, err
+	}
+	mempoolTxs, fees := (&Mempool{Blockchain: bc}).SelectTransactions()
+	// 每个区块只有一笔位于首位的 coinbase 交易
+	txs := []*Transaction{NewCoinbaseTransaction(minerAddress, tip.Height+1, 0, fees)}
+	txs = append(txs, mempoolTxs...)
+	block := &Block{
+		BlockHeader: BlockHeader{
+			Version:       blockVersion,
+			PrevBlockHash: tipHash,
+			TimeStamp:     bc.nextBlockTime(tip),
+			Bits:          bits,
+			Height:        tip.Height + 1,
+		},
+		Txs: txs,
 	}
+	block.MerkleRoot = block.HashTransaction()
+	return block, nil
+}
 
-	// 通过已拿到的区块生成新的区块
-	block = NewBlock(block.Height + 1, block.Hash, txs)
-	// 持久化新生成的区块到数据库中
-	bc.DB.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(BlockTableName))
-		if nil != b {
-			err := b.Put(block.Hash, block.Serialize())
-			if nil != err {
-				log.Panicf("update the new block to db failed %v\n", err)
-			}
-			// 更新最新区块的哈希值
-			err = b.Put([]byte("1"), block.Hash)
-			if nil != err {
-				log.Panicf("update the latest block hash to db failed %v\n", err)
-			}
-			bc.Tip = block.Hash
+// MineBlock 根据交易池生成新区块并使用共识引擎封装（执行工作量证明或者由签名者签名），封装的区块经过验证之后添加到区块链中
+// ctx 被取消或者最新区块在挖矿期间发生变化时放弃当前区块，返回 ctx 的错误；
+// poa 共识中本节点没有轮到出块的签名者的私钥时返回 ErrNotInTurn；
+// workers 为并行计算的 goroutine 数量（小于 1 时使用全部 CPU 核心），progress 用于报告挖矿进度，可以为空
+func (bc *BlockChain) MineBlock(ctx context.Context, minerAddress string, workers int, progress ProgressFunc) (*Block, error) {
+	// 先获取通知通道，再生成区块模板，保证不会错过模板生成之后的最新区块变化
+	tipChanged := bc.TipChanged()
+	block, err := bc.NewBlockTemplate(minerAddress)
+	if nil != err {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-tipChanged:
+			cancel()
+		case <-ctx.Done():
 		}
-		return nil
-	})
+	}()
+	opts := &SealOptions{Workers: workers, Progress: progress, Wallets: NewWallets(bc.nodeId)}
+	if err := bc.Engine().Seal(ctx, bc.GetHeader(block.PrevBlockHash), block, opts); nil != err {
+		return nil, err
+	}
+	return block, bc.AddBlock(block)
+}
+
+// TipHash 获取最新区块的哈希值，可以在处理网络请求、挖矿的 goroutine 中并发调用
+func (bc *BlockChain) TipHash() []byte {
+	bc.tipMutex.Lock()
+	defer bc.tipMutex.Unlock()
+	return bc.tip
+}
+
+// TipChanged 返回一个在最新区块下一次变化时关闭的通道
+func (bc *BlockChain) TipChanged() <-chan struct{} {
+	bc.tipMutex.Lock()
+	defer bc.tipMutex.Unlock()
+	if nil == bc.tipChanged {
+		bc.tipChanged = make(chan struct{})
+	}
+	return bc.tipChanged
 }
 
 // UnUTXOs 查找指定地址的 UTXO
@@ -250,18 +317,18 @@ func (bc *BlockChain) UnUTXOs(address string, txs []*Transaction) []*UTXO {
 								}
 							}
 							if isSpentUTXO == false {
-								utxo := &UTXO{tx.TxHash, index, vout}
+								utxo := &UTXO{TxHash: tx.TxHash, Index: index, Output: vout}
 								unUTXOS = append(unUTXOS, utxo)
 							}
 						}
 					}
 					if isUtxoTx == false {
 						// 说明当前交易中所有 address 相关的 outputs 都是 UTXO
-						utxo := &UTXO{tx.TxHash, index, vout}
+						utxo := &UTXO{TxHash: tx.TxHash, Index: index, Output: vout}
 						unUTXOS = append(unUTXOS, utxo)
 					}
 				} else {
-					utxo := &UTXO{tx.TxHash, index, vout}
+					utxo := &UTXO{TxHash: tx.TxHash, Index: index, Output: vout}
 					unUTXOS = append(unUTXOS, utxo)
 				}
 			}
@@ -293,12 +360,12 @@ func (bc *BlockChain) UnUTXOs(address string, txs []*Transaction) []*UTXO {
 							}
 						}
 						if isSpentOutput == false {
-							utxo := &UTXO{tx.TxHash, index, vout}
+							utxo := &UTXO{tx.TxHash, index, vout, block.Height, tx.IsCoinbaseTransaction()}
 							unUTXOS = append(unUTXOS, utxo)
 						}
 					} else {
 						// 将当前地址所有输出都添加到未花费输出中
-						utxo := &UTXO{tx.TxHash, index, vout}
+						utxo := &UTXO{tx.TxHash, index, vout, block.Height, tx.IsCoinbaseTransaction()}
 						unUTXOS = append(unUTXOS, utxo)
 					}
 				}
@@ -354,8 +421,14 @@ func (bc *BlockChain) FindSpendableUTXO(from string, amount int, txs []*Transact
 	spendableUTXO := make(map[string][]int)
 	var value int
 	utxos := bc.UnUTXOs(from, txs)
+	// 交易将被打包进下一个区块
+	spendHeight := bc.GetHeight() + 1
 	// 遍历 UTXO
 	for _, utxo := range utxos {
+		// 尚未成熟的 coinbase 输出不能花费
+		if !utxo.IsMature(spendHeight) {
+			continue
+		}
 		value += utxo.Output.Value
 		// 计算交易哈希
 		hash := hex.EncodeToString(utxo.TxHash)
@@ -372,8 +445,8 @@ func (bc *BlockChain) FindSpendableUTXO(from string, amount int, txs []*Transact
 	return value, spendableUTXO
 }
 
-// SignTransaction 交易签名
-func (bc *BlockChain) SignTransaction(tx *Transaction, privateKey ecdsa.PrivateKey) {
+// SignTransaction 交易签名，txs：缓存中的交易列表（尚未上链，但可以被 tx 引用的交易）
+func (bc *BlockChain) SignTransaction(tx *Transaction, privateKey ecdsa.PrivateKey, txs []*Transaction) {
 	// coinbase 交易不需要签名
 	if tx.IsCoinbaseTransaction() {
 		return
@@ -381,14 +454,27 @@ func (bc *BlockChain) SignTransaction(tx *Transaction, privateKey ecdsa.PrivateK
 	// 处理交易的 input，查找 tx 所引用的 vout 所属交易(查找发送者)
 	// 对我们所花费的每一笔 UTXO 进行签名
 	// 存储引用的交易
+	prevTxs := bc.findPrevTransactions(tx, txs)
+	// 签名
+	tx.Sign(privateKey, prevTxs)
+}
+
+// findPrevTransactions 查找交易的输入所引用的交易，优先查找缓存中的交易
+func (bc *BlockChain) findPrevTransactions(tx *Transaction, txs []*Transaction) map[string]Transaction {
 	prevTxs := make(map[string]Transaction)
+	WorkInputLoop:
 	for _, vin := range tx.Vins {
+		for _, cacheTx := range txs {
+			if bytes.Compare(vin.TxHash, cacheTx.TxHash) == 0 {
+				prevTxs[hex.EncodeToString(cacheTx.TxHash)] = *cacheTx
+				continue WorkInputLoop
+			}
+		}
 		// 查找当前交易所引用的交易
-		tx := bc.FindTransaction(vin.TxHash)
-		prevTxs[hex.EncodeToString(tx.TxHash)] = tx
+		prevTx := bc.FindTransaction(vin.TxHash)
+		prevTxs[hex.EncodeToString(prevTx.TxHash)] = prevTx
 	}
-	// 签名
-	tx.Sign(privateKey, prevTxs)
+	return prevTxs
 }
 
 // FindTransaction 通过指定的交易哈希查找交易
@@ -410,18 +496,19 @@ func (bc *BlockChain) FindTransaction(id []byte) Transaction {
 	return Transaction{}
 }
 
-// VerityTransaction 验证签名
-func (bc *BlockChain) VerityTransaction(tx *Transaction) bool {
+// VerityTransaction 验证签名，txs：缓存中的交易列表（尚未上链，但可以被 tx 引用的交易）
+func (bc *BlockChain) VerityTransaction(tx *Transaction, txs []*Transaction) bool {
+	return nil == bc.VerifyTransactionScripts(tx, txs)
+}
+
+// VerifyTransactionScripts 执行交易所有输入的脚本，返回第一个验证失败的原因
+func (bc *BlockChain) VerifyTransactionScripts(tx *Transaction, txs []*Transaction) error {
 	if tx.IsCoinbaseTransaction() {
-		return true
+		return nil
 	}
-	prevTxs := make(map[string]Transaction)
 	// 查找输入引用的交易
-	for _, vin := range tx.Vins {
-		tx := bc.FindTransaction(vin.TxHash)
-		prevTxs[hex.EncodeToString(tx.TxHash)] = tx
-	}
-	return tx.Verity(prevTxs)
+	prevTxs := bc.findPrevTransactions(tx, txs)
+	return tx.VerifyScripts(prevTxs)
 }
 
 // FindUTXOMap 查找整条区块链中所有地址的 UTXO
@@ -435,36 +522,39 @@ func (bc *BlockChain) FindUTXOMap() map[string]*TXOutputs {
 	for {
 		block, pre := bcit.PreBlock()
 		for _, tx := range block.Txs {
-			txOutputs := &TXOutputs{[]*TxOutput{}}
+			txOutputs := &TXOutputs{[]*UTXO{}}
 			txHash := hex.EncodeToString(tx.TxHash)
 			// 获取每笔交易的 vouts
 			WorkOutLoop:
 			for index, vout := range tx.Vouts {
+				// 不能被花费的数据输出不是 UTXO
+				if IsUnspendable(vout.ScriptPubKey) {
+					continue
+				}
 				// 获取治党交易的输入
 				txInputs := spentTxOutputs[txHash]
 				if len(txInputs) > 0 {
 					isSpent := false
 					for _, in := range txInputs {
-						// 查找指定输出的所有者
-						outPubKey := vout.Ripemd160Hash
-						inPubKey := in.PublicKey
-						if bytes.Compare(outPubKey, Ripemd160Hash(inPubKey)) == 0 {
-							if index == in.Vout {
-								isSpent = true
-								continue WorkOutLoop
-							}
+						// 输出被某个输入引用，说明已经被花费
+						if index == in.Vout {
+							isSpent = true
+							continue WorkOutLoop
 						}
 					}
 					if !isSpent {
 						// 当前输出没有被包含到 txInputs 中
-						txOutputs.TXOutputs = append(txOutputs.TXOutputs, vout)
+						txOutputs.UTXOS = append(txOutputs.UTXOS, &UTXO{tx.TxHash, index, vout, block.Height, tx.IsCoinbaseTransaction()})
 					}
 				} else {
 					// 没有 input 引用该交易的输出，则代表当前交易中所有的输出都是 UTXO
-					txOutputs.TXOutputs = append(txOutputs.TXOutputs, vout)
+					txOutputs.UTXOS = append(txOutputs.UTXOS, &UTXO{tx.TxHash, index, vout, block.Height, tx.IsCoinbaseTransaction()})
 				}
 			}
-			utxoMaps[txHash] = txOutputs
+			// 输出已经全部被花费的交易不需要保存
+			if len(txOutputs.UTXOS) > 0 {
+				utxoMaps[txHash] = txOutputs
+			}
 		}
 		if !pre {
 			break
@@ -521,41 +611,99 @@ func (bc *BlockChain) GetBlock(hash []byte) []byte {
 	bc.DB.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(BlockTableName))
 		if nil != b {
-			blockByte = b.Get(hash)
+			if data := b.Get(hash); nil != data {
+				// b.Get 返回的数据只在事务内有效，需要拷贝
+				blockByte = append([]byte{}, data...)
+			}
 		}
 		return nil
 	})
 	return blockByte
 }
 
-// AddBlock 添加区块
-func (bc *BlockChain) AddBlock(block *Block) {
-	err := bc.DB.Update(func(tx *bolt.Tx) error {
-		// 1. 获取数据表
-		b := tx.Bucket([]byte(BlockTableName))
+// GetHeader 获取指定哈希的区块头，不存在时返回 nil
+func (bc *BlockChain) GetHeader(hash []byte) *BlockHeader {
+	var headerBytes []byte
+	bc.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(HeaderTableName))
 		if nil != b {
-			// 判断需要传入的区块是否已经存在
-			if b.Get(block.Hash) != nil {
-				// 已经存在，不需要添加
-				return nil
-			}
-			// 不存在，添加到数据库中
-			err := b.Put(block.Hash, block.Serialize())
-			if nil != err {
-				log.Panicf("sync the block failed! %v\n", err)
-			}
-			blockHash := b.Get([]byte("1"))
-			latestBlock := b.Get(blockHash)
-			rawBlock := Deserialize(latestBlock)
-			if rawBlock.Height < block.Height {
-				b.Put([]byte("1"), block.Hash)
-				bc.Tip = block.Hash
+			if data := b.Get(hash); nil != data {
+				headerBytes = append([]byte{}, data...)
 			}
 		}
 		return nil
 	})
-	if nil != err {
-		log.Panicf("update the db when insert the new block failed! %v\n", err)
+	if nil != headerBytes {
+		return DeserializeHeader(headerBytes)
+	}
+	// 旧版本数据库中没有区块头表，从区块中读取
+	if block := bc.getBlock(hash); nil != block {
+		return &block.BlockHeader
+	}
+	return nil
+}
+
+// PrintHeaders 只读取区块头，输出主链上所有区块的摘要信息
+func (bc *BlockChain) PrintHeaders() {
+	fmt.Println("区块头信息...")
+	for hash := bc.TipHash(); len(hash) > 0; {
+		header := bc.GetHeader(hash)
+		if nil == header {
+			break
+		}
+		fmt.Printf("%d\t%x\tbits:%08x\ttime:%d\tmerkle:%x\n",
+			header.Height, hash, header.Bits, header.TimeStamp, header.MerkleRoot)
+		hash = header.PrevBlockHash
+	}
+}
+
+// getBlock 获取指定哈希的区块，不存在时返回 nil
+func (bc *BlockChain) getBlock(hash []byte) *Block {
+	blockBytes := bc.GetBlock(hash)
+	if nil == blockBytes {
+		return nil
 	}
-	fmt.Println("the new block is added!")
-}
\ No newline at end of file
+	return Deserialize(blockBytes)
+}
+
+// AddBlock 验证并添加区块，验证失败的区块不会写入数据库
+// 所有通过验证的区块（包括侧链区块）都会被保存，最新区块始终指向累计工作量最大的分支：
+// 1. 区块连接在最新区块之后，直接更新最新区块哈希与 UTXO 集合
+// 2. 区块位于侧链，并且侧链的累计工作量超过主链，进行链重组
+func (bc *BlockChain) AddBlock(block *Block) error {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	// 判断需要传入的区块是否已经存在
+	if nil != bc.GetBlock(block.Hash) {
+		return rejectBlock(block, RejectDuplicate, "the block already exists")
+	}
+	// 区块验证
+	if err := bc.CheckBlock(block); nil != err {
+		return err
+	}
+	tip := bc.TipHash()
+	isEmpty := len(tip) == 0
+	// 保存区块
+	bc.putBlock(block)
+	utxoSet := &UTXOSet{Blockchain: bc}
+	if bytes.Equal(block.PrevBlockHash, tip) {
+		bc.setTip(block.Hash)
+		if isEmpty {
+			utxoSet.ResetUTXOSet()
+		} else {
+			utxoSet.Update()
+		}
+		fmt.Println("the new block is added!")
+		return nil
+	}
+	// 侧链区块，比较累计工作量
+	if bc.GetChainWork(block.Hash).Cmp(bc.GetChainWork(tip)) > 0 {
+		if err := bc.reorganize(block); nil != err {
+			return err
+		}
+		fmt.Println("the chain is reorganized!")
+		return nil
+	}
+	fmt.Println("the new block is added to a side chain!")
+	return nil
+}
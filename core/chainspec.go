@@ -0,0 +1,257 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+)
+
+// 链规格文件管理文件
+
+// 链规格文件（JSON）描述创世区块与链参数，使用同一个链规格文件创建区块链的节点得到完全相同的创世区块：
+//   {
+//     "name": "devnet",
+//     "consensus": "pow",                  共识算法，poa 时需要 signers（十六进制公钥列表）
+//     "magic": "0b110907",                 网络魔数（4 字节十六进制）
+//     "port": 3000,                        节点的默认端口
+//     "seeds": ["localhost:3000"],         种子节点地址
+//     "powLimitBits": "207fffff",          目标值上限（压缩形式）
+//     "initialSubsidy": 10,                其他链参数与 ChainParams 中的字段同名（首字母小写）
+//     "genesis": {
+//       "timestamp": 1700000000,           创世区块时间戳
+//       "bits": "1f010000",                创世区块难度（压缩形式）
+//       "nonce": 12345,                    可选，省略时从 0 开始顺序查找第一个满足难度的 nonce
+//       "allocations": [{"address": "...", "amount": 100}],
+//       "message": "...",                  可选，写入 coinbase 数据输出的消息
+//       "hash": "..."                      可选，生成的创世区块哈希必须与它一致
+//     }
+//   }
+// 省略或者为 0 的链参数使用当前网络（-network）的参数，地址版本前缀始终由网络决定
+
+// ChainSpec 链规格
+type ChainSpec struct {
+	Name      string   `json:"name"`
+	Consensus string   `json:"consensus,omitempty"`
+	Signers   []string `json:"signers,omitempty"`
+	Magic     string   `json:"magic,omitempty"`
+	Port      int      `json:"port,omitempty"`
+	Seeds     []string `json:"seeds,omitempty"`
+
+	PowLimitBits           string `json:"powLimitBits,omitempty"`
+	TargetBlockTime        int64  `json:"targetBlockTime,omitempty"`
+	RetargetInterval       int64  `json:"retargetInterval,omitempty"`
+	MedianTimeSpan         int64  `json:"medianTimeSpan,omitempty"`
+	MaxFutureBlockTime     int64  `json:"maxFutureBlockTime,omitempty"`
+	InitialSubsidy         int    `json:"initialSubsidy,omitempty"`
+	SubsidyHalvingInterval int64  `json:"subsidyHalvingInterval,omitempty"`
+	MaxSupply              int    `json:"maxSupply,omitempty"`
+	CoinbaseMaturity       int64  `json:"coinbaseMaturity,omitempty"`
+	MaxDataCarrierSize     int    `json:"maxDataCarrierSize,omitempty"`
+
+	Genesis GenesisSpec `json:"genesis"`
+}
+
+// GenesisSpec 创世区块规格
+type GenesisSpec struct {
+	Timestamp   int64        `json:"timestamp"`
+	Bits        string       `json:"bits,omitempty"`
+	Nonce       *int64       `json:"nonce,omitempty"`
+	Allocations []Allocation `json:"allocations,omitempty"`
+	Message     string       `json:"message,omitempty"`
+	Hash        string       `json:"hash,omitempty"`
+}
+
+// Allocation 创世区块中分配给地址的金额
+type Allocation struct {
+	Address string `json:"address"`
+	Amount  int    `json:"amount"`
+}
+
+// LoadChainSpec 读取链规格文件
+func LoadChainSpec(file string) (*ChainSpec, error) {
+	content, err := ioutil.ReadFile(file)
+	if nil != err {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	var spec ChainSpec
+	if err := decoder.Decode(&spec); nil != err {
+		return nil, fmt.Errorf("parse the chain spec [%s] failed: %v", file, err)
+	}
+	return &spec, nil
+}
+
+// Params 链规格对应的链参数：在当前网络的链参数的基础上替换链规格中给出的参数
+func (spec *ChainSpec) Params() (*ChainParams, error) {
+	params := *NetworkParams
+	if "" != spec.Name {
+		params.Name = spec.Name
+	}
+	if "" != spec.Consensus {
+		params.Consensus = spec.Consensus
+	}
+	params.Signers = nil
+	for _, signer := range spec.Signers {
+		pubKey, err := hex.DecodeString(signer)
+		if nil != err {
+			return nil, fmt.Errorf("the signer [%s] is not a hex pubkey", signer)
+		}
+		params.Signers = append(params.Signers, pubKey)
+	}
+	if err := CheckConsensusParams(params.Consensus, params.Signers); nil != err {
+		return nil, err
+	}
+	if "" != spec.Magic {
+		magic, err := hex.DecodeString(spec.Magic)
+		if nil != err || len(magic) != len(params.Magic) {
+			return nil, fmt.Errorf("the magic [%s] is not %d hex bytes", spec.Magic, len(params.Magic))
+		}
+		copy(params.Magic[:], magic)
+	}
+	if spec.Port < 0 || spec.Port > 65535 {
+		return nil, fmt.Errorf("the port %d is out of range", spec.Port)
+	}
+	if spec.Port != 0 {
+		params.DefaultPort = spec.Port
+	}
+	if len(spec.Seeds) != 0 {
+		params.Seeds = spec.Seeds
+	}
+	if "" != spec.PowLimitBits {
+		bits, err := parseBits(spec.PowLimitBits)
+		if nil != err {
+			return nil, err
+		}
+		params.PowLimitBits, params.PowLimit = bits, CompactToBig(bits)
+	}
+	params.Genesis = spec.Genesis
+	if "" != spec.Genesis.Bits {
+		bits, err := parseBits(spec.Genesis.Bits)
+		if nil != err {
+			return nil, err
+		}
+		params.GenesisBits = bits
+	}
+	if CompactToBig(params.GenesisBits).Cmp(params.PowLimit) > 0 {
+		return nil, fmt.Errorf("the genesis bits %08x is easier than the pow limit %08x", params.GenesisBits, params.PowLimitBits)
+	}
+	for _, field := range []struct {
+		value int64
+		param *int64
+	}{
+		{spec.TargetBlockTime, &params.TargetBlockTime},
+		{spec.RetargetInterval, &params.RetargetInterval},
+		{spec.MedianTimeSpan, &params.MedianTimeSpan},
+		{spec.MaxFutureBlockTime, &params.MaxFutureBlockTime},
+		{spec.SubsidyHalvingInterval, &params.SubsidyHalvingInterval},
+		{spec.CoinbaseMaturity, &params.CoinbaseMaturity},
+	} {
+		if field.value < 0 {
+			return nil, fmt.Errorf("the chain params cannot be negative")
+		}
+		if field.value != 0 {
+			*field.param = field.value
+		}
+	}
+	// 难度调整需要至少两个区块之间的时间间隔，期望用时为 0 时无法计算新的难度
+	if params.RetargetInterval < 2 {
+		return nil, fmt.Errorf("the retarget interval %d is less than 2", params.RetargetInterval)
+	}
+	if params.TargetBlockTime <= 0 {
+		return nil, fmt.Errorf("the target block time %d is not positive", params.TargetBlockTime)
+	}
+	for _, field := range []struct {
+		value int
+		param *int
+	}{
+		{spec.InitialSubsidy, &params.InitialSubsidy},
+		{spec.MaxSupply, &params.MaxSupply},
+		{spec.MaxDataCarrierSize, &params.MaxDataCarrierSize},
+	} {
+		if field.value < 0 {
+			return nil, fmt.Errorf("the chain params cannot be negative")
+		}
+		if field.value != 0 {
+			*field.param = field.value
+		}
+	}
+	return &params, nil
+}
+
+// parseBits 解析十六进制的压缩形式难度
+func parseBits(bits string) (uint32, error) {
+	value, err := strconv.ParseUint(bits, 16, 32)
+	if nil != err || value == 0 || CompactToBig(uint32(value)).Sign() <= 0 {
+		return 0, fmt.Errorf("the bits [%s] is not a positive compact target", bits)
+	}
+	return uint32(value), nil
+}
+
+// GenesisBlock 按照链参数中的创世区块规格生成创世区块
+// 区块中的所有数据都由链参数决定：时间戳、难度、分配金额、消息，以及从 0 开始顺序查找得到的 nonce
+func GenesisBlock(params *ChainParams) (*Block, error) {
+	genesis := params.Genesis
+	if len(genesis.Allocations) == 0 && "" == genesis.Message {
+		return nil, fmt.Errorf("the genesis block has no allocations")
+	}
+	if genesis.Timestamp <= 0 {
+		return nil, fmt.Errorf("the genesis timestamp %d is invalid", genesis.Timestamp)
+	}
+	var outputs []*TxOutput
+	for _, allocation := range genesis.Allocations {
+		if !IsValidForAddress([]byte(allocation.Address)) {
+			return nil, fmt.Errorf("the allocation address [%s] is invalid", allocation.Address)
+		}
+		if allocation.Amount <= 0 {
+			return nil, fmt.Errorf("the allocation amount %d of [%s] is not positive", allocation.Amount, allocation.Address)
+		}
+		outputs = append(outputs, NewTxOutput(allocation.Amount, allocation.Address))
+	}
+	if "" != genesis.Message {
+		if len(genesis.Message) > params.MaxDataCarrierSize {
+			return nil, fmt.Errorf("the genesis message is larger than %d bytes", params.MaxDataCarrierSize)
+		}
+		script := NewScriptBuilder().AddOp(OP_RETURN).AddData([]byte(genesis.Message)).Script()
+		outputs = append(outputs, &TxOutput{Value: 0, ScriptPubKey: script})
+	}
+	// 创世区块的 coinbase 版本固定，提升交易版本号不会改变已有网络的创世区块
+	coinbase := &Transaction{
+		Version: strictSigTxVersion,
+		Vins:    []*TxInput{{TxHash: []byte{}, Vout: -1, ScriptSig: coinbaseScript(1, 0), Sequence: MaxSequence}},
+		Vouts:   outputs,
+	}
+	coinbase.HashTransaction()
+	engine := NewConsensusEngine(params)
+	block := &Block{
+		BlockHeader: BlockHeader{
+			Version:   blockVersion,
+			TimeStamp: genesis.Timestamp,
+			Bits:      params.GenesisBits,
+			Height:    1,
+		},
+		Txs: []*Transaction{coinbase},
+	}
+	if params.Consensus == ConsensusPoA {
+		block.Bits = params.PowLimitBits
+	}
+	block.MerkleRoot = block.HashTransaction()
+	if nil != genesis.Nonce {
+		block.Nonce = *genesis.Nonce
+		block.Hash = block.BlockHeader.Hash()
+		if err := engine.VerifySeal(nil, block); nil != err {
+			return nil, fmt.Errorf("the genesis nonce %d is invalid: %v", *genesis.Nonce, err)
+		}
+	} else if err := engine.Seal(context.Background(), nil, block, &SealOptions{Workers: 1}); nil != err {
+		// 只使用一个 goroutine，保证每个节点找到相同的 nonce
+		return nil, err
+	}
+	if "" != genesis.Hash && genesis.Hash != fmt.Sprintf("%x", block.Hash) {
+		return nil, fmt.Errorf("the genesis hash %x does not match the expected hash %s", block.Hash, genesis.Hash)
+	}
+	return block, nil
+}
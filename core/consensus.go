@@ -0,0 +1,205 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// 共识引擎管理文件
+
+// 区块的封装方式由链参数中的共识算法决定：
+//   pow：工作量证明，区块哈希必须小于难度对应的目标值，难度每隔 RetargetInterval 个区块调整一次
+//   poa：权威证明，链参数中配置的签名者按高度轮流出块（高度为 h 的区块由第 h % n 个签名者签名），
+//        相邻区块的时间戳至少间隔 TargetBlockTime 秒，所有区块的难度相同，累计工作量最大的分支即最长的分支
+// 创世区块不需要签名，它由链规格文件或者各个节点共享的数据库确定
+
+// 共识算法名称
+const (
+	ConsensusPoW = "pow"
+	ConsensusPoA = "poa"
+)
+
+// ErrNotInTurn 本节点没有轮到出块的签名者的私钥
+var ErrNotInTurn = errors.New("not in turn")
+
+// ConsensusEngine 共识引擎，负责区块的封装与验证
+type ConsensusEngine interface {
+	// Name 共识算法名称
+	Name() string
+	// NextBits 计算连接在 prev 之后的区块的难度，prev 为空时计算创世区块的难度，需要的区块头缺失时返回错误
+	NextBits(bc *BlockChain, prev *BlockHeader) (uint32, error)
+	// Seal 封装区块，设置区块哈希以及共识需要的字段（nonce 或者封装签名），prev 为空时封装创世区块
+	Seal(ctx context.Context, prev *BlockHeader, block *Block, opts *SealOptions) error
+	// VerifySeal 验证区块的封装，prev 为空时（创世区块或者孤块）只验证与前一个区块无关的部分
+	VerifySeal(prev *BlockHeader, block *Block) error
+}
+
+// SealOptions 封装区块时使用的本地配置
+type SealOptions struct {
+	Workers  int          // pow 并行计算的 goroutine 数量，小于 1 时使用全部 CPU 核心
+	Progress ProgressFunc // pow 进度回调，可以为空
+	Wallets  *Wallets     // poa 签名者私钥所在的钱包集合
+}
+
+// NewConsensusEngine 根据链参数创建共识引擎
+func NewConsensusEngine(params *ChainParams) ConsensusEngine {
+	switch params.Consensus {
+	case ConsensusPoW, "":
+		return &powEngine{}
+	case ConsensusPoA:
+		return &poaEngine{signers: params.Signers, period: params.TargetBlockTime}
+	}
+	log.Panicf("unknown consensus [%s]\n", params.Consensus)
+	return nil
+}
+
+// CheckConsensusParams 检查共识参数，poa 至少需要一个签名者，签名者公钥必须有效并且不能重复
+func CheckConsensusParams(consensus string, signers [][]byte) error {
+	switch consensus {
+	case ConsensusPoW:
+		if len(signers) != 0 {
+			return fmt.Errorf("the consensus %s has no signers", consensus)
+		}
+	case ConsensusPoA:
+		if len(signers) == 0 {
+			return fmt.Errorf("the consensus %s needs at least one signer", consensus)
+		}
+		for i, signer := range signers {
+			if !isValidPubKey(signer) {
+				return fmt.Errorf("the signer %d [%x] is invalid", i, signer)
+			}
+			for _, other := range signers[:i] {
+				if bytes.Equal(signer, other) {
+					return fmt.Errorf("the signer [%x] is duplicated", signer)
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("unknown consensus [%s]", consensus)
+	}
+	return nil
+}
+
+// Engine 当前链参数的共识引擎
+func (bc *BlockChain) Engine() ConsensusEngine {
+	return NewConsensusEngine(ActiveParams)
+}
+
+// powEngine 工作量证明
+type powEngine struct{}
+
+// Name 共识算法名称
+func (engine *powEngine) Name() string {
+	return ConsensusPoW
+}
+
+// NextBits 按照难度调整规则计算难度
+func (engine *powEngine) NextBits(bc *BlockChain, prev *BlockHeader) (uint32, error) {
+	return bc.CalcNextBits(prev)
+}
+
+// Seal 执行工作量证明
+func (engine *powEngine) Seal(ctx context.Context, prev *BlockHeader, block *Block, opts *SealOptions) error {
+	pow := NewProofOfWork(block)
+	pow.Workers = opts.Workers
+	pow.Progress = opts.Progress
+	hash, nonce, err := pow.Run(ctx)
+	if nil != err {
+		return err
+	}
+	block.Hash, block.Nonce = hash, nonce
+	return nil
+}
+
+// VerifySeal 区块哈希必须由区块头计算得出，并且小于目标值
+func (engine *powEngine) VerifySeal(prev *BlockHeader, block *Block) error {
+	if !NewProofOfWork(block).Validate() {
+		if !bytes.Equal(block.Hash, block.BlockHeader.Hash()) {
+			return rejectBlock(block, RejectBadHash, "the block hash does not match the header")
+		}
+		return rejectBlock(block, RejectInvalidPow, "the block hash is higher than the target %08x", block.Bits)
+	}
+	return nil
+}
+
+// poaEngine 权威证明
+type poaEngine struct {
+	signers [][]byte // 签名者公钥，按轮流出块的顺序排列
+	period  int64    // 相邻区块的最小时间间隔（秒）
+}
+
+// Name 共识算法名称
+func (engine *poaEngine) Name() string {
+	return ConsensusPoA
+}
+
+// NextBits 所有区块使用最低难度，每个区块的工作量相同
+func (engine *poaEngine) NextBits(bc *BlockChain, prev *BlockHeader) (uint32, error) {
+	return ActiveParams.PowLimitBits, nil
+}
+
+// InTurnSigner 轮到为指定高度的区块签名的签名者公钥
+func (engine *poaEngine) InTurnSigner(height int64) []byte {
+	return engine.signers[height%int64(len(engine.signers))]
+}
+
+// Seal 等到与前一个区块间隔 period 秒之后，使用轮到出块的签名者的私钥签名
+// 钱包集合中没有该签名者的私钥时返回 ErrNotInTurn
+func (engine *poaEngine) Seal(ctx context.Context, prev *BlockHeader, block *Block, opts *SealOptions) error {
+	if nil == prev {
+		block.Hash = block.BlockHeader.Hash()
+		return nil
+	}
+	signer := engine.InTurnSigner(block.Height)
+	var wallet *Wallet
+	if nil != opts.Wallets {
+		wallet = opts.Wallets.WalletForPubKey(signer)
+	}
+	if nil == wallet {
+		return fmt.Errorf("%w: the block at height %d is signed by [%x]", ErrNotInTurn, block.Height, signer)
+	}
+	if earliest := prev.TimeStamp + engine.period; block.TimeStamp < earliest {
+		timer := time.NewTimer(time.Until(time.Unix(earliest, 0)))
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		block.TimeStamp = earliest
+	}
+	block.Version = strictSealBlockVersion
+	block.Nonce = 0
+	signature, err := signHash(&wallet.PrivateKey, block.SealHash())
+	if nil != err {
+		return err
+	}
+	block.Signature = signature
+	block.Hash = block.BlockHeader.Hash()
+	return nil
+}
+
+// VerifySeal 区块哈希必须由区块头计算得出，区块必须由轮到出块的签名者签名，并且与前一个区块间隔 period 秒
+func (engine *poaEngine) VerifySeal(prev *BlockHeader, block *Block) error {
+	if !bytes.Equal(block.Hash, block.BlockHeader.Hash()) {
+		return rejectBlock(block, RejectBadHash, "the block hash does not match the header")
+	}
+	// 创世区块不需要签名
+	if len(block.PrevBlockHash) == 0 {
+		return nil
+	}
+	signer := engine.InTurnSigner(block.Height)
+	strict := block.Version >= strictSealBlockVersion
+	if block.Version < sealedBlockVersion || !verifySignature(signer, block.Signature, block.SealHash(), strict) {
+		return rejectBlock(block, RejectBadSeal, "the block is not signed by the signer [%x]", signer)
+	}
+	if nil != prev && block.TimeStamp < prev.TimeStamp+engine.period {
+		return rejectBlock(block, RejectBadSeal, "the timestamp %d is less than %d seconds after the previous block",
+			block.TimeStamp, engine.period)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// 难度调整管理文件
+
+// CompactToBig 将压缩形式的难度（bits）转换为目标值
+// bits 的最高字节表示目标值的字节长度，低 3 字节为尾数
+func CompactToBig(compact uint32) *big.Int {
+	mantissa := compact & 0x007fffff
+	isNegative := compact&0x00800000 != 0
+	exponent := uint(compact >> 24)
+	var bn *big.Int
+	if exponent <= 3 {
+		mantissa >>= 8 * (3 - exponent)
+		bn = big.NewInt(int64(mantissa))
+	} else {
+		bn = big.NewInt(int64(mantissa))
+		bn.Lsh(bn, 8*(exponent-3))
+	}
+	if isNegative {
+		bn = bn.Neg(bn)
+	}
+	return bn
+}
+
+// BigToCompact 将目标值转换为压缩形式的难度（bits）
+func BigToCompact(n *big.Int) uint32 {
+	if n.Sign() == 0 {
+		return 0
+	}
+	var mantissa uint32
+	exponent := uint(len(n.Bytes()))
+	if exponent <= 3 {
+		mantissa = uint32(n.Bits()[0])
+		mantissa <<= 8 * (3 - exponent)
+	} else {
+		tn := new(big.Int).Set(n)
+		mantissa = uint32(tn.Rsh(tn, 8*(exponent-3)).Bits()[0])
+	}
+	// 尾数的最高位是符号位，如果被占用，需要右移一个字节
+	if mantissa&0x00800000 != 0 {
+		mantissa >>= 8
+		exponent++
+	}
+	compact := uint32(exponent<<24) | mantissa
+	if n.Sign() < 0 {
+		compact |= 0x00800000
+	}
+	return compact
+}
+
+// Difficulty 计算难度值（最低难度的目标值与当前目标值的比值）
+func Difficulty(bits uint32) float64 {
+	target := CompactToBig(bits)
+	if target.Sign() <= 0 {
+		return 0
+	}
+	diff, _ := new(big.Float).Quo(
+		new(big.Float).SetInt(ActiveParams.PowLimit),
+		new(big.Float).SetInt(target)).Float64()
+	return diff
+}
+
+// CalcNextBits 根据前一个区块头计算下一个区块的难度
+// 每隔 RetargetInterval 个区块，根据最近 RetargetInterval 个区块的时间戳调整一次难度，
+// 使出块间隔趋向 TargetBlockTime。调整周期内的区块头缺失时返回错误
+func (bc *BlockChain) CalcNextBits(prev *BlockHeader) (uint32, error) {
+	params := ActiveParams
+	if nil == prev {
+		return params.GenesisBits, nil
+	}
+	// 不在调整周期上，沿用前一个区块的难度
+	if prev.Height%params.RetargetInterval != 0 {
+		return prev.Bits, nil
+	}
+	// 找到调整周期内的第一个区块
+	first := prev
+	for i := int64(1); i < params.RetargetInterval; i++ {
+		first = bc.GetHeader(first.PrevBlockHash)
+		if nil == first {
+			return 0, fmt.Errorf("the ancestor of block at height %d is missing", prev.Height)
+		}
+	}
+	// 实际用时与期望用时
+	actualTimespan := prev.TimeStamp - first.TimeStamp
+	targetTimespan := params.TargetBlockTime * (params.RetargetInterval - 1)
+	if targetTimespan <= 0 {
+		// 链参数无效（链规格会拒绝这样的参数），无法调整难度
+		return prev.Bits, nil
+	}
+	// 限制单次调整幅度，最多 4 倍
+	if actualTimespan < targetTimespan/4 {
+		actualTimespan = targetTimespan / 4
+	} else if actualTimespan > targetTimespan*4 {
+		actualTimespan = targetTimespan * 4
+	}
+	// 期望用时很短时 targetTimespan/4 可能为 0，新目标值不能为 0
+	if actualTimespan < 1 {
+		actualTimespan = 1
+	}
+	// 新目标值 = 旧目标值 * 实际用时 / 期望用时
+	newTarget := CompactToBig(prev.Bits)
+	newTarget.Mul(newTarget, big.NewInt(actualTimespan))
+	newTarget.Div(newTarget, big.NewInt(targetTimespan))
+	if newTarget.Cmp(params.PowLimit) > 0 {
+		newTarget.Set(params.PowLimit)
+	}
+	return BigToCompact(newTarget), nil
+}
+
+// CheckBlockBits 检查区块声明的难度是否与共识引擎计算的难度一致
+func (bc *BlockChain) CheckBlockBits(block *Block) error {
+	var prev *BlockHeader
+	if len(block.PrevBlockHash) > 0 {
+		prev = bc.GetHeader(block.PrevBlockHash)
+		if nil == prev {
+			return fmt.Errorf("the previous block [%x] is not found", block.PrevBlockHash)
+		}
+	}
+	expected, err := bc.Engine().NextBits(bc, prev)
+	if nil != err {
+		return err
+	}
+	if block.Bits != expected {
+		return fmt.Errorf("block [%x] has bits %08x, expected %08x", block.Hash, block.Bits, expected)
+	}
+	return nil
+}
+
+// CalcWork 计算指定难度的区块所代表的工作量：2^256 / (target + 1)
+func CalcWork(bits uint32) *big.Int {
+	target := CompactToBig(bits)
+	if target.Sign() <= 0 {
+		return big.NewInt(0)
+	}
+	denominator := new(big.Int).Add(target, big.NewInt(1))
+	return new(big.Int).Div(new(big.Int).Lsh(big.NewInt(1), 256), denominator)
+}
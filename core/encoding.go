@@ -0,0 +1,459 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// 规范编码管理文件
+
+// 区块、交易以及 UTXO 列表使用固定格式的二进制编码，不依赖 Go 语言，其他语言的工具也可以解析：
+// 1. 所有整数都使用小端序
+// 2. 变长字节（bytes）：uint32 长度 + 数据
+// 3. 列表：uint32 元素个数 + 每个元素的编码
+//
+// 交易（Transaction）：
+//   int32   交易版本号
+//   uint32  输入个数，每个输入（TxInput）：
+//             bytes   引用的交易哈希（coinbase 为空）
+//             int32   引用的输出索引（coinbase 为 -1）
+//             bytes   解锁脚本（coinbase 为区块高度 + 额外随机数）
+//             uint32  序列号（第 3 版开始，相对锁定时间）
+//   uint32  输出个数，每个输出（TxOutput）：
+//             int64   金额
+//             bytes   锁定脚本
+//   uint32  锁定时间（第 3 版开始）
+//   bytes   旧的交易哈希（只有第 0 版）
+//   第 2 版交易没有序列号与锁定时间，解码时序列号为 MaxSequence，锁定时间为 0，保证重新编码得到相同的交易哈希
+//   交易哈希 = sha256(交易编码)，不包含在编码中
+//   第 0 版为 migrate 从旧数据库导入的交易：格式与第 2 版相同，解锁脚本为 <旧签名> <旧公钥>，
+//   旧的交易哈希无法由交易内容重新计算，保存在编码末尾并直接作为交易哈希
+//
+// 区块头（BlockHeader）：
+//   int32   区块版本号
+//   bytes   前区块哈希
+//   bytes   Merkle 根
+//   int64   时间戳
+//   uint32  难度（bits）
+//   bytes   封装签名（第 2 版开始，poa 共识中签名者对区块的签名）
+//   int64   nonce
+//   int64   区块高度
+//   第 1 版区块头没有封装签名，工作量证明区块的编码保持不变
+//   区块哈希 = sha256(区块头编码)
+//
+// 区块（Block）：区块头 + uint32 交易个数 + 每笔交易的 bytes（交易编码前加上长度，方便跳过）
+//
+// UTXO 列表（TXOutputs）：uint32 个数，每个 UTXO：
+//   bytes 交易哈希 + int32 输出索引 + 输出 + int64 所在区块高度 + uint8 是否是 coinbase 输出（0/1）
+
+// TxVersion 交易版本号，第 2 版开始输入与输出使用脚本（第 1 版为签名 + 公钥与公钥哈希，需要使用 migrate 迁移），
+// 第 3 版开始包含序列号与锁定时间，第 4 版开始签名与公钥必须使用严格编码（编码格式与第 3 版相同），
+// 第 5 版开始签名哈希包含网络魔数（编码格式与第 3 版相同）
+const TxVersion = 5
+
+// minTxVersion 可以解码的最低交易版本号（迁移交易除外）
+const minTxVersion = 2
+
+// migratedTxVersion 迁移交易的版本号，保留旧的交易哈希与签名，只能由 migrate 写入本地数据库
+const migratedTxVersion = 0
+
+// lockTimeTxVersion 包含序列号与锁定时间的最低交易版本号
+const lockTimeTxVersion = 3
+
+// strictSigTxVersion 签名与公钥必须使用严格编码的最低交易版本号
+const strictSigTxVersion = 4
+
+// replayProtectedTxVersion 签名哈希包含网络魔数的最低交易版本号，之前版本的交易仍然使用原来的签名哈希
+const replayProtectedTxVersion = 5
+
+// sealedBlockVersion 包含封装签名的最低区块版本号
+const sealedBlockVersion = 2
+
+// strictSealBlockVersion 封装签名必须使用严格编码的最低区块版本号（编码格式与第 2 版相同）
+const strictSealBlockVersion = 3
+
+// headerNonceOffset 区块头编码中 nonce 距离末尾的字节数（nonce 之后只有 int64 区块高度），
+// 挖矿时只需要修改这 8 个字节
+const headerNonceOffset = 16
+
+// maxVarBytesLen 单个变长字段的最大长度，防止恶意数据导致分配过大的内存
+const maxVarBytesLen = 32 << 20
+
+// ErrShortData 数据不完整
+var ErrShortData = errors.New("unexpected end of data")
+
+// encoder 规范编码的写入器
+type encoder struct {
+	buf bytes.Buffer
+	err error
+}
+
+func (e *encoder) writeUint32(v uint32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	e.buf.Write(b[:])
+}
+
+func (e *encoder) writeBool(v bool) {
+	if v {
+		e.buf.WriteByte(1)
+	} else {
+		e.buf.WriteByte(0)
+	}
+}
+
+func (e *encoder) writeInt32(v int32) {
+	e.writeUint32(uint32(v))
+}
+
+func (e *encoder) writeInt64(v int64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(v))
+	e.buf.Write(b[:])
+}
+
+func (e *encoder) writeBytes(data []byte) {
+	if len(data) > maxVarBytesLen {
+		e.fail(fmt.Errorf("the field is too long (%d bytes)", len(data)))
+		return
+	}
+	e.writeUint32(uint32(len(data)))
+	e.buf.Write(data)
+}
+
+func (e *encoder) fail(err error) {
+	if nil == e.err {
+		e.err = err
+	}
+}
+
+func (e *encoder) writeTxInput(in *TxInput, version int32) {
+	if nil == in {
+		e.fail(errors.New("nil tx input"))
+		return
+	}
+	e.writeBytes(in.TxHash)
+	e.writeInt32(int32(in.Vout))
+	e.writeBytes(in.ScriptSig)
+	if version >= lockTimeTxVersion {
+		e.writeUint32(in.Sequence)
+	} else if in.Sequence != MaxSequence {
+		e.fail(fmt.Errorf("the transaction version %d has no sequence", version))
+	}
+}
+
+func (e *encoder) writeTxOutput(out *TxOutput) {
+	if nil == out {
+		e.fail(errors.New("nil tx output"))
+		return
+	}
+	e.writeInt64(int64(out.Value))
+	e.writeBytes(out.ScriptPubKey)
+}
+
+func (e *encoder) writeTransaction(tx *Transaction) {
+	if nil == tx {
+		e.fail(errors.New("nil transaction"))
+		return
+	}
+	e.writeInt32(tx.Version)
+	e.writeUint32(uint32(len(tx.Vins)))
+	for _, in := range tx.Vins {
+		e.writeTxInput(in, tx.Version)
+	}
+	e.writeUint32(uint32(len(tx.Vouts)))
+	for _, out := range tx.Vouts {
+		e.writeTxOutput(out)
+	}
+	if tx.Version >= lockTimeTxVersion {
+		e.writeUint32(tx.LockTime)
+	} else if tx.LockTime != 0 {
+		e.fail(fmt.Errorf("the transaction version %d has no lock time", tx.Version))
+	}
+	if tx.Version == migratedTxVersion {
+		e.writeBytes(tx.TxHash)
+	}
+}
+
+func (e *encoder) writeHeader(header *BlockHeader) {
+	e.writeInt32(header.Version)
+	e.writeBytes(header.PrevBlockHash)
+	e.writeBytes(header.MerkleRoot)
+	e.writeInt64(header.TimeStamp)
+	e.writeUint32(header.Bits)
+	if header.Version >= sealedBlockVersion {
+		e.writeBytes(header.Signature)
+	} else if len(header.Signature) != 0 {
+		e.fail(fmt.Errorf("the block version %d has no signature", header.Version))
+	}
+	e.writeInt64(header.Nonce)
+	e.writeInt64(header.Height)
+}
+
+// decoder 规范编码的读取器，出错之后的读取都返回零值，由调用者在最后检查错误
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) next(n int) []byte {
+	if nil != d.err {
+		return nil
+	}
+	if n < 0 || len(d.data) < n {
+		d.err = ErrShortData
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) readUint32() uint32 {
+	b := d.next(4)
+	if nil == b {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (d *decoder) readBool() bool {
+	b := d.next(1)
+	if nil == b {
+		return false
+	}
+	if b[0] > 1 {
+		d.err = fmt.Errorf("invalid bool value %d", b[0])
+	}
+	return b[0] == 1
+}
+
+func (d *decoder) readInt32() int32 {
+	return int32(d.readUint32())
+}
+
+func (d *decoder) readInt64() int64 {
+	b := d.next(8)
+	if nil == b {
+		return 0
+	}
+	return int64(binary.LittleEndian.Uint64(b))
+}
+
+func (d *decoder) readBytes() []byte {
+	n := d.readUint32()
+	if n > maxVarBytesLen {
+		if nil == d.err {
+			d.err = fmt.Errorf("the field is too long (%d bytes)", n)
+		}
+		return nil
+	}
+	b := d.next(int(n))
+	if nil == b {
+		return nil
+	}
+	// 拷贝一份，避免引用原始数据（例如 boltdb 中只在事务内有效的数据）
+	return append([]byte{}, b...)
+}
+
+// readCount 读取列表的元素个数，每个元素至少占用 minSize 字节
+func (d *decoder) readCount(minSize int) int {
+	n := d.readUint32()
+	if nil == d.err && uint64(n)*uint64(minSize) > uint64(len(d.data)) {
+		d.err = ErrShortData
+	}
+	if nil != d.err {
+		return 0
+	}
+	return int(n)
+}
+
+// finish 检查读取错误以及多余的数据
+func (d *decoder) finish(name string) error {
+	if nil == d.err && len(d.data) > 0 {
+		d.err = fmt.Errorf("%d trailing bytes", len(d.data))
+	}
+	if nil != d.err {
+		return fmt.Errorf("decode the %s failed: %v", name, d.err)
+	}
+	return nil
+}
+
+func (d *decoder) readTxInput(version int32) *TxInput {
+	in := &TxInput{
+		TxHash:    d.readBytes(),
+		Vout:      int(d.readInt32()),
+		ScriptSig: d.readBytes(),
+		Sequence:  MaxSequence,
+	}
+	if version >= lockTimeTxVersion {
+		in.Sequence = d.readUint32()
+	}
+	return in
+}
+
+func (d *decoder) readTxOutput() *TxOutput {
+	return &TxOutput{
+		Value:        int(d.readInt64()),
+		ScriptPubKey: d.readBytes(),
+	}
+}
+
+func (d *decoder) readTransaction() *Transaction {
+	tx := &Transaction{Version: d.readInt32()}
+	if nil == d.err && (tx.Version < minTxVersion && tx.Version != migratedTxVersion || tx.Version > TxVersion) {
+		d.err = fmt.Errorf("unsupported transaction version %d", tx.Version)
+	}
+	for i, n := 0, d.readCount(12); i < n; i++ {
+		tx.Vins = append(tx.Vins, d.readTxInput(tx.Version))
+	}
+	for i, n := 0, d.readCount(12); i < n; i++ {
+		tx.Vouts = append(tx.Vouts, d.readTxOutput())
+	}
+	if tx.Version >= lockTimeTxVersion {
+		tx.LockTime = d.readUint32()
+	}
+	if tx.Version == migratedTxVersion {
+		tx.TxHash = d.readBytes()
+	}
+	return tx
+}
+
+func (d *decoder) readHeader() *BlockHeader {
+	header := &BlockHeader{
+		Version:       d.readInt32(),
+		PrevBlockHash: d.readBytes(),
+		MerkleRoot:    d.readBytes(),
+		TimeStamp:     d.readInt64(),
+		Bits:          d.readUint32(),
+	}
+	if header.Version >= sealedBlockVersion {
+		header.Signature = d.readBytes()
+	}
+	header.Nonce = d.readInt64()
+	header.Height = d.readInt64()
+	return header
+}
+
+// EncodeTransaction 交易编码
+func EncodeTransaction(tx *Transaction) ([]byte, error) {
+	e := &encoder{}
+	e.writeTransaction(tx)
+	return e.buf.Bytes(), e.err
+}
+
+// DecodeTransaction 交易解码，交易哈希由编码数据重新计算得出（迁移交易使用编码中保存的旧交易哈希）
+func DecodeTransaction(data []byte) (*Transaction, error) {
+	d := &decoder{data: data}
+	tx := d.readTransaction()
+	if err := d.finish("transaction"); nil != err {
+		return nil, err
+	}
+	if tx.Version != migratedTxVersion {
+		hash := sha256.Sum256(data)
+		tx.TxHash = hash[:]
+	}
+	return tx, nil
+}
+
+// EncodeHeader 区块头编码
+func EncodeHeader(header *BlockHeader) ([]byte, error) {
+	if nil == header {
+		return nil, errors.New("nil block header")
+	}
+	e := &encoder{}
+	e.writeHeader(header)
+	return e.buf.Bytes(), e.err
+}
+
+// DecodeHeader 区块头解码
+func DecodeHeader(data []byte) (*BlockHeader, error) {
+	d := &decoder{data: data}
+	header := d.readHeader()
+	if err := d.finish("block header"); nil != err {
+		return nil, err
+	}
+	return header, nil
+}
+
+// EncodeBlock 区块编码
+func EncodeBlock(block *Block) ([]byte, error) {
+	if nil == block {
+		return nil, errors.New("nil block")
+	}
+	e := &encoder{}
+	e.writeHeader(&block.BlockHeader)
+	e.writeUint32(uint32(len(block.Txs)))
+	for _, tx := range block.Txs {
+		txBytes, err := EncodeTransaction(tx)
+		if nil != err {
+			return nil, err
+		}
+		e.writeBytes(txBytes)
+	}
+	return e.buf.Bytes(), e.err
+}
+
+// DecodeBlock 区块解码，区块哈希与交易哈希由编码数据重新计算得出
+func DecodeBlock(data []byte) (*Block, error) {
+	d := &decoder{data: data}
+	block := &Block{BlockHeader: *d.readHeader()}
+	for i, n := 0, d.readCount(4); i < n; i++ {
+		txBytes := d.readBytes()
+		if nil != d.err {
+			break
+		}
+		tx, err := DecodeTransaction(txBytes)
+		if nil != err {
+			return nil, err
+		}
+		block.Txs = append(block.Txs, tx)
+	}
+	if err := d.finish("block"); nil != err {
+		return nil, err
+	}
+	block.Hash = block.BlockHeader.Hash()
+	return block, nil
+}
+
+// EncodeTxOutputs UTXO 列表编码
+func EncodeTxOutputs(txOutputs *TXOutputs) ([]byte, error) {
+	if nil == txOutputs {
+		return nil, errors.New("nil utxo list")
+	}
+	e := &encoder{}
+	e.writeUint32(uint32(len(txOutputs.UTXOS)))
+	for _, utxo := range txOutputs.UTXOS {
+		if nil == utxo {
+			return nil, errors.New("nil utxo")
+		}
+		e.writeBytes(utxo.TxHash)
+		e.writeInt32(int32(utxo.Index))
+		e.writeTxOutput(utxo.Output)
+		e.writeInt64(utxo.Height)
+		e.writeBool(utxo.IsCoinbase)
+	}
+	return e.buf.Bytes(), e.err
+}
+
+// DecodeTxOutputs UTXO 列表解码
+func DecodeTxOutputs(data []byte) (*TXOutputs, error) {
+	d := &decoder{data: data}
+	txOutputs := &TXOutputs{}
+	for i, n := 0, d.readCount(29); i < n; i++ {
+		txOutputs.UTXOS = append(txOutputs.UTXOS, &UTXO{
+			TxHash:     d.readBytes(),
+			Index:      int(d.readInt32()),
+			Output:     d.readTxOutput(),
+			Height:     d.readInt64(),
+			IsCoinbase: d.readBool(),
+		})
+	}
+	if err := d.finish("utxo list"); nil != err {
+		return nil, err
+	}
+	return txOutputs, nil
+}
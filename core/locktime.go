@@ -0,0 +1,102 @@
+package core
+
+import "fmt"
+
+// 锁定时间管理文件
+
+// 绝对锁定时间（Transaction.LockTime）：
+//   小于 LockTimeThreshold 时为区块高度，交易只能被打包进高度大于锁定时间的区块；
+//   否则为时间戳，交易只能被打包进前一个区块的 median-time-past 大于锁定时间的区块；
+//   所有输入的序列号都是 MaxSequence 时锁定时间不生效
+// 相对锁定时间（TxInput.Sequence，第 3 版交易）：
+//   设置 SequenceLockTimeDisabled 时不生效，否则低 16 位为锁定的数值：
+//   设置 SequenceLockTimeIsSeconds 时以 512 秒为单位，从被花费输出所在区块的前一个区块的 median-time-past 开始计算；
+//   否则为区块数，从被花费输出所在区块的高度开始计算
+
+const (
+	LockTimeThreshold           = 500000000  // 锁定时间小于该值时为区块高度，否则为时间戳
+	MaxSequence                 = 0xffffffff // 序列号的最大值，表示输入没有任何锁定
+	SequenceLockTimeDisabled    = 1 << 31    // 设置时相对锁定时间不生效
+	SequenceLockTimeIsSeconds   = 1 << 22    // 设置时相对锁定时间以 512 秒为单位，否则为区块数
+	SequenceLockTimeMask        = 0x0000ffff // 相对锁定时间的数值
+	SequenceLockTimeGranularity = 9          // 相对锁定时间的单位为 2^9 = 512 秒
+)
+
+// RelativeLockBlocks 生成相对锁定 blocks 个区块的序列号
+func RelativeLockBlocks(blocks int64) (uint32, error) {
+	if blocks < 0 || blocks > SequenceLockTimeMask {
+		return 0, fmt.Errorf("the relative lock %d blocks is out of range [0, %d]", blocks, SequenceLockTimeMask)
+	}
+	return uint32(blocks), nil
+}
+
+// RelativeLockSeconds 生成相对锁定 seconds 秒的序列号，时间向上取整为 512 秒的倍数
+func RelativeLockSeconds(seconds int64) (uint32, error) {
+	units := (seconds + 1<<SequenceLockTimeGranularity - 1) >> SequenceLockTimeGranularity
+	if seconds < 0 || units > SequenceLockTimeMask {
+		return 0, fmt.Errorf("the relative lock %d seconds is out of range", seconds)
+	}
+	return SequenceLockTimeIsSeconds | uint32(units), nil
+}
+
+// IsFinal 判断交易的绝对锁定时间能否被高度为 height 的区块满足，medianTime 为前一个区块的 median-time-past
+func (tx *Transaction) IsFinal(height int64, medianTime int64) bool {
+	if tx.LockTime == 0 {
+		return true
+	}
+	limit := height
+	if tx.LockTime >= LockTimeThreshold {
+		limit = medianTime
+	}
+	if int64(tx.LockTime) < limit {
+		return true
+	}
+	for _, vin := range tx.Vins {
+		if vin.Sequence != MaxSequence {
+			return false
+		}
+	}
+	return true
+}
+
+// checkSequenceLocks 检查交易每个输入的相对锁定时间能否被高度为 height、前一个区块为 prev 的区块满足
+// utxos 为每个输入引用的输出，尚未上链的输出视为位于高度 height 的区块中
+func (bc *BlockChain) checkSequenceLocks(tx *Transaction, utxos []*UTXO, prev *BlockHeader, height int64) error {
+	if tx.Version < lockTimeTxVersion || tx.IsCoinbaseTransaction() {
+		return nil
+	}
+	medianTime := bc.CalcPastMedianTime(prev)
+	for i, vin := range tx.Vins {
+		if vin.Sequence&SequenceLockTimeDisabled != 0 {
+			continue
+		}
+		utxoHeight := utxos[i].Height
+		if 0 == utxoHeight {
+			utxoHeight = height
+		}
+		value := int64(vin.Sequence & SequenceLockTimeMask)
+		if vin.Sequence&SequenceLockTimeIsSeconds != 0 {
+			baseTime := bc.CalcPastMedianTime(bc.ancestorHeader(prev, utxoHeight-1))
+			if minTime := baseTime + value<<SequenceLockTimeGranularity; medianTime < minTime {
+				return fmt.Errorf("input %d is locked until the median time %d (now %d)", i, minTime, medianTime)
+			}
+		} else if minHeight := utxoHeight + value; height < minHeight {
+			return fmt.Errorf("input %d is locked until height %d", i, minHeight)
+		}
+	}
+	return nil
+}
+
+// ancestorHeader 从 header 向前查找高度为 height 的区块头，找不到时返回 nil
+func (bc *BlockChain) ancestorHeader(header *BlockHeader, height int64) *BlockHeader {
+	for nil != header && header.Height > height {
+		if len(header.PrevBlockHash) == 0 {
+			return nil
+		}
+		header = bc.GetHeader(header.PrevBlockHash)
+	}
+	if nil != header && header.Height == height {
+		return header
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 区块时间管理文件
+
+// 区块的时间戳必须满足两个条件：
+// 1. 大于前 MedianTimeSpan 个区块时间戳的中位数（median-time-past），时间戳不能回退太多
+// 2. 不超过网络调整时间 MaxFutureBlockTime 秒，时间戳不能提前太多
+// 网络调整时间 = 本地时间 + 所有节点时间偏差的中位数（节点在 version 消息中报告自己的时间）
+
+// maxTimeSamples 最多记录的节点时间样本数
+const maxTimeSamples = 200
+
+// minTimeSamples 至少需要多少个时间样本（包括本地时间）才调整网络时间，防止少数节点操纵本地节点的时间
+const minTimeSamples = 5
+
+// maxTimeOffset 网络时间偏差的上限（秒），偏差过大时说明本地时间或者节点不可信，不进行调整
+const maxTimeOffset = 70 * 60
+
+// MedianTimeSource 网络调整时间，由节点报告的时间计算得出
+type MedianTimeSource struct {
+	mutex   sync.Mutex
+	offsets map[string]int64 // 节点地址 -> 节点时间与本地时间的偏差（秒）
+	offset  int64            // 当前使用的时间偏差
+}
+
+// NewMedianTimeSource 创建网络调整时间
+func NewMedianTimeSource() *MedianTimeSource {
+	return &MedianTimeSource{offsets: make(map[string]int64)}
+}
+
+// AddTimeSample 记录节点报告的时间，每个节点只记录一次
+func (m *MedianTimeSource) AddTimeSample(source string, timestamp int64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.offsets[source]; ok || len(m.offsets) >= maxTimeSamples {
+		return
+	}
+	m.offsets[source] = timestamp - time.Now().Unix()
+	// 本地时间也作为一个样本（偏差为 0）
+	offsets := []int64{0}
+	for _, offset := range m.offsets {
+		offsets = append(offsets, offset)
+	}
+	if len(offsets) < minTimeSamples {
+		return
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	median := offsets[len(offsets)/2]
+	if median > maxTimeOffset || median < -maxTimeOffset {
+		fmt.Printf("the network time offset %ds is too large, please check the local time\n", median)
+		median = 0
+	}
+	m.offset = median
+}
+
+// Offset 网络时间与本地时间的偏差（秒）
+func (m *MedianTimeSource) Offset() int64 {
+	if nil == m {
+		return 0
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.offset
+}
+
+// AdjustedTime 网络调整时间（Unix 时间戳），没有网络时间时使用本地时间
+func (m *MedianTimeSource) AdjustedTime() int64 {
+	return time.Now().Unix() + m.Offset()
+}
+
+// CalcPastMedianTime 计算以 header 结尾的 MedianTimeSpan 个区块时间戳的中位数
+func (bc *BlockChain) CalcPastMedianTime(header *BlockHeader) int64 {
+	var timestamps []int64
+	for ; nil != header && int64(len(timestamps)) < ActiveParams.MedianTimeSpan; header = bc.GetHeader(header.PrevBlockHash) {
+		timestamps = append(timestamps, header.TimeStamp)
+		if len(header.PrevBlockHash) == 0 {
+			break
+		}
+	}
+	if len(timestamps) == 0 {
+		return 0
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+	return timestamps[len(timestamps)/2]
+}
+
+// nextBlockTime 计算连接在 prev 之后的新区块可以使用的时间戳：网络调整时间，但至少比 median-time-past 大 1 秒
+func (bc *BlockChain) nextBlockTime(prev *BlockHeader) int64 {
+	timestamp := bc.TimeSource.AdjustedTime()
+	if nil != prev {
+		if minTime := bc.CalcPastMedianTime(prev) + 1; timestamp < minTime {
+			timestamp = minTime
+		}
+	}
+	return timestamp
+}
@@ -0,0 +1,474 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"log"
+	"sort"
+	"time"
+)
+
+// 交易池管理文件
+
+// 交易池中保存已经通过验证、尚未被打包进区块的交易。
+// 交易池保存在区块链数据库中，命令行与节点服务共用同一个交易池。
+// 交易可以花费 UTXO 集合中的输出，也可以花费交易池中其他交易的输出（依赖链），
+// 但同一个输出只能被交易池中的一笔交易花费。
+
+// mempoolTableName 交易池表，key：交易哈希  value：交易池条目
+const mempoolTableName = "mempool"
+
+// Mempool 交易池
+type Mempool struct {
+	Blockchain *BlockChain
+}
+
+// MempoolEntry 交易池中的一笔交易
+type MempoolEntry struct {
+	Tx      *Transaction // 交易
+	Time    int64        // 进入交易池的时间
+	Fee     int          // 手续费（输入金额 - 输出金额）
+	Depends [][]byte     // 该交易所花费的、仍在交易池中的交易
+	SpentBy [][]byte     // 交易池中花费了该交易输出的交易
+}
+
+// TxRejectError 交易被交易池拒绝时返回的错误
+type TxRejectError struct {
+	Reason RejectReason // 拒绝原因
+	Hash   []byte       // 被拒绝的交易哈希
+	Msg    string       // 详细描述
+}
+
+// Error 错误描述
+func (e *TxRejectError) Error() string {
+	return fmt.Sprintf("tx [%x] rejected (%s): %s", e.Hash, e.Reason, e.Msg)
+}
+
+// rejectTx 生成一个交易验证错误
+func rejectTx(tx *Transaction, reason RejectReason, format string, args ...interface{}) error {
+	return &TxRejectError{Reason: reason, Hash: tx.TxHash, Msg: fmt.Sprintf(format, args...)}
+}
+
+// AcceptTransaction 验证交易并加入交易池
+func (mp *Mempool) AcceptTransaction(tx *Transaction) error {
+	mp.Blockchain.mutex.Lock()
+	defer mp.Blockchain.mutex.Unlock()
+	return mp.acceptTransaction(tx)
+}
+
+// acceptTransaction 验证交易并加入交易池（调用者需要持有区块链的锁）
+// 1. 交易结构、交易哈希，最多只能有一个不超过 MaxDataCarrierSize 字节的数据输出
+// 2. 每个输入引用的输出必须在 UTXO 集合或交易池中，并且没有被交易池中的其他交易花费，coinbase 输出必须已经成熟
+// 3. 输出金额不能大于输入金额
+// 4. 绝对锁定时间与相对锁定时间必须能被下一个区块满足
+// 5. 签名
+func (mp *Mempool) acceptTransaction(tx *Transaction) error {
+	if len(tx.Vins) == 0 || len(tx.Vouts) == 0 {
+		return rejectTx(tx, RejectMalformed, "the transaction has no inputs or no outputs")
+	}
+	if tx.IsCoinbaseTransaction() {
+		return rejectTx(tx, RejectBadCoinbase, "a coinbase transaction is only valid in a block")
+	}
+	if !bytes.Equal(tx.TxHash, tx.Hash()) {
+		return rejectTx(tx, RejectBadTxHash, "the hash does not match the contents")
+	}
+	// 旧版本交易的签名可以被第三方修改（改变交易哈希），只能出现在已有的区块中
+	if tx.Version < strictSigTxVersion {
+		return rejectTx(tx, RejectNonStandard, "the transaction version %d allows malleable signatures", tx.Version)
+	}
+	var outputValue int
+	var dataOutputs int
+	for _, out := range tx.Vouts {
+		if out.Value < 0 {
+			return rejectTx(tx, RejectBadTxValue, "the transaction has a negative output")
+		}
+		outputValue += out.Value
+		// 每笔交易最多只能有一个标准的数据输出
+		if !IsUnspendable(out.ScriptPubKey) {
+			continue
+		}
+		if data, ok := ExtractNullData(out.ScriptPubKey); !ok || len(data) > ActiveParams.MaxDataCarrierSize {
+			return rejectTx(tx, RejectNonStandard, "the data output is not standard or larger than %d bytes",
+				ActiveParams.MaxDataCarrierSize)
+		}
+		if dataOutputs++; dataOutputs > 1 {
+			return rejectTx(tx, RejectNonStandard, "the transaction has more than one data output")
+		}
+	}
+	entries := mp.entries()
+	if _, ok := entries[hex.EncodeToString(tx.TxHash)]; ok {
+		return rejectTx(tx, RejectDuplicate, "the transaction is already in the mempool")
+	}
+	// 交易池中已经被花费的输出
+	poolSpent := make(map[string][]byte)
+	var poolTxs []*Transaction
+	for _, entry := range entries {
+		poolTxs = append(poolTxs, entry.Tx)
+		for _, vin := range entry.Tx.Vins {
+			poolSpent[fmt.Sprintf("%x:%d", vin.TxHash, vin.Vout)] = entry.Tx.TxHash
+		}
+	}
+	utxoSet := &UTXOSet{Blockchain: mp.Blockchain}
+	// 交易最早被打包进下一个区块
+	spendHeight := mp.Blockchain.GetHeight() + 1
+	tip := mp.Blockchain.GetHeader(mp.Blockchain.TipHash())
+	if medianTime := mp.Blockchain.CalcPastMedianTime(tip); !tx.IsFinal(spendHeight, medianTime) {
+		return rejectTx(tx, RejectNonFinal, "the transaction is locked until %d (next height %d, median time %d)",
+			tx.LockTime, spendHeight, medianTime)
+	}
+	spent := make(map[string]bool)
+	var inputValue int
+	var utxos []*UTXO
+	for _, vin := range tx.Vins {
+		outpoint := fmt.Sprintf("%x:%d", vin.TxHash, vin.Vout)
+		if spent[outpoint] {
+			return rejectTx(tx, RejectDoubleSpend, "the transaction spends %s twice", outpoint)
+		}
+		spent[outpoint] = true
+		if conflict, ok := poolSpent[outpoint]; ok {
+			return rejectTx(tx, RejectConflict, "%s is already spent by tx [%x] in the mempool", outpoint, conflict)
+		}
+		// 优先查找交易池中的交易，再查找 UTXO 集合
+		var utxo *UTXO
+		if entry, ok := entries[hex.EncodeToString(vin.TxHash)]; ok {
+			if vin.Vout >= 0 && vin.Vout < len(entry.Tx.Vouts) && !IsUnspendable(entry.Tx.Vouts[vin.Vout].ScriptPubKey) {
+				utxo = &UTXO{TxHash: entry.Tx.TxHash, Index: vin.Vout, Output: entry.Tx.Vouts[vin.Vout]}
+			}
+		} else {
+			utxo = utxoSet.FindUTXO(vin.TxHash, vin.Vout)
+		}
+		if nil == utxo {
+			return rejectTx(tx, RejectMissingInput, "%s is missing or spent", outpoint)
+		}
+		if !utxo.IsMature(spendHeight) {
+			return rejectTx(tx, RejectImmatureCoinbase, "%s is a coinbase output from height %d", outpoint, utxo.Height)
+		}
+		inputValue += utxo.Output.Value
+		utxos = append(utxos, utxo)
+	}
+	if err := mp.Blockchain.checkSequenceLocks(tx, utxos, tip, spendHeight); nil != err {
+		return rejectTx(tx, RejectSequenceLock, "%v", err)
+	}
+	if outputValue > inputValue {
+		return rejectTx(tx, RejectBadTxValue, "the transaction spends %d but only has %d", outputValue, inputValue)
+	}
+	if err := mp.Blockchain.VerifyTransactionScripts(tx, poolTxs); nil != err {
+		return rejectTx(tx, RejectBadSignature, "the signature is invalid: %v", err)
+	}
+	entry := &MempoolEntry{Tx: tx, Time: time.Now().Unix(), Fee: inputValue - outputValue}
+	err := mp.Blockchain.DB.Update(func(dbTx *bolt.Tx) error {
+		b, err := dbTx.CreateBucketIfNotExists([]byte(mempoolTableName))
+		if nil != err {
+			return err
+		}
+		return b.Put(tx.TxHash, entry.serialize())
+	})
+	if nil != err {
+		log.Panicf("add the tx [%x] to the mempool failed! %v\n", tx.TxHash, err)
+	}
+	return nil
+}
+
+// Transactions 获取交易池中的所有交易，被依赖的交易排在依赖它的交易之前
+func (mp *Mempool) Transactions() []*Transaction {
+	var txs []*Transaction
+	for _, entry := range mp.sortedEntries() {
+		txs = append(txs, entry.Tx)
+	}
+	return txs
+}
+
+// SelectTransactions 选择打包进区块的交易，返回交易列表及其手续费总额
+// 输入无法在 UTXO 集合或者已选择的交易中找到的交易（以及依赖它的交易）不会被选择
+func (mp *Mempool) SelectTransactions() ([]*Transaction, int) {
+	var txs []*Transaction
+	var fees int
+	for _, entry := range mp.resolvedEntries(nil) {
+		txs = append(txs, entry.Tx)
+		fees += entry.Fee
+	}
+	return txs, fees
+}
+
+// revalidate 链重组之后重新检查交易池（调用者需要持有区块链的锁）：
+// 删除输入已经无法找到（被新主链花费，或者所在的交易已经不在主链与交易池中）的交易，以及依赖这些交易的交易
+func (mp *Mempool) revalidate() {
+	var evicted [][]byte
+	mp.resolvedEntries(func(entry *MempoolEntry) {
+		evicted = append(evicted, entry.Tx.TxHash)
+	})
+	if len(evicted) == 0 {
+		return
+	}
+	err := mp.Blockchain.DB.Update(func(dbTx *bolt.Tx) error {
+		b := dbTx.Bucket([]byte(mempoolTableName))
+		if nil == b {
+			return nil
+		}
+		for _, txHash := range evicted {
+			if err := b.Delete(txHash); nil != err {
+				return err
+			}
+		}
+		return nil
+	})
+	if nil != err {
+		log.Panicf("remove the invalid txs from the mempool failed! %v\n", err)
+	}
+	fmt.Printf("removed %d transactions with missing inputs from the mempool\n", len(evicted))
+}
+
+// resolvedEntries 按依赖顺序返回所有输入都能找到的交易池条目：
+// 每个输入引用 UTXO 集合中的输出，或者排在它之前的已找到的交易的输出，并且没有被已找到的交易花费，
+// 其余的条目（包括依赖它们的条目）传给 unresolved，unresolved 可以为空
+func (mp *Mempool) resolvedEntries(unresolved func(entry *MempoolEntry)) []*MempoolEntry {
+	utxoSet := &UTXOSet{Blockchain: mp.Blockchain}
+	resolved := make(map[string]*MempoolEntry)
+	spent := make(map[string]bool)
+	var result []*MempoolEntry
+	for _, entry := range mp.sortedEntries() {
+		ok := true
+		for _, vin := range entry.Tx.Vins {
+			outpoint := fmt.Sprintf("%x:%d", vin.TxHash, vin.Vout)
+			if spent[outpoint] {
+				ok = false
+				break
+			}
+			if parent, inPool := resolved[hex.EncodeToString(vin.TxHash)]; inPool {
+				ok = vin.Vout >= 0 && vin.Vout < len(parent.Tx.Vouts) && !IsUnspendable(parent.Tx.Vouts[vin.Vout].ScriptPubKey)
+			} else {
+				ok = nil != utxoSet.FindUTXO(vin.TxHash, vin.Vout)
+			}
+			if !ok {
+				break
+			}
+		}
+		if !ok {
+			if nil != unresolved {
+				unresolved(entry)
+			}
+			continue
+		}
+		for _, vin := range entry.Tx.Vins {
+			spent[fmt.Sprintf("%x:%d", vin.TxHash, vin.Vout)] = true
+		}
+		resolved[hex.EncodeToString(entry.Tx.TxHash)] = entry
+		result = append(result, entry)
+	}
+	return result
+}
+
+// GetRawMempool 获取交易池中所有交易的哈希
+func (mp *Mempool) GetRawMempool() [][]byte {
+	var hashes [][]byte
+	for _, entry := range mp.sortedEntries() {
+		hashes = append(hashes, entry.Tx.TxHash)
+	}
+	return hashes
+}
+
+// GetEntry 获取交易池中的指定交易及其依赖关系，不存在时返回 nil
+func (mp *Mempool) GetEntry(txHash []byte) *MempoolEntry {
+	entries := mp.entries()
+	entry, ok := entries[hex.EncodeToString(txHash)]
+	if !ok {
+		return nil
+	}
+	for _, vin := range entry.Tx.Vins {
+		if _, ok := entries[hex.EncodeToString(vin.TxHash)]; ok {
+			entry.Depends = appendUniqueHash(entry.Depends, vin.TxHash)
+		}
+	}
+	for _, other := range entries {
+		for _, vin := range other.Tx.Vins {
+			if bytes.Equal(vin.TxHash, txHash) {
+				entry.SpentBy = appendUniqueHash(entry.SpentBy, other.Tx.TxHash)
+			}
+		}
+	}
+	return entry
+}
+
+// appendUniqueHash 添加不重复的哈希
+func appendUniqueHash(hashes [][]byte, hash []byte) [][]byte {
+	for _, h := range hashes {
+		if bytes.Equal(h, hash) {
+			return hashes
+		}
+	}
+	return append(hashes, hash)
+}
+
+// entries 读取交易池中的所有条目，key：交易哈希的十六进制字符串
+func (mp *Mempool) entries() map[string]*MempoolEntry {
+	entries := make(map[string]*MempoolEntry)
+	err := mp.Blockchain.DB.View(func(dbTx *bolt.Tx) error {
+		var err error
+		entries, err = readMempoolEntries(dbTx)
+		return err
+	})
+	if nil != err {
+		log.Panicf("read the mempool failed! %v\n", err)
+	}
+	return entries
+}
+
+// sortedEntries 按进入交易池的时间排序，并保证被依赖的交易排在前面
+func (mp *Mempool) sortedEntries() []*MempoolEntry {
+	entries := mp.entries()
+	var pending []*MempoolEntry
+	for _, entry := range entries {
+		pending = append(pending, entry)
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if pending[i].Time != pending[j].Time {
+			return pending[i].Time < pending[j].Time
+		}
+		return bytes.Compare(pending[i].Tx.TxHash, pending[j].Tx.TxHash) < 0
+	})
+	var sorted []*MempoolEntry
+	added := make(map[string]bool)
+	for len(pending) > 0 {
+		var rest []*MempoolEntry
+		for _, entry := range pending {
+			ready := true
+			for _, vin := range entry.Tx.Vins {
+				key := hex.EncodeToString(vin.TxHash)
+				if _, inPool := entries[key]; inPool && !added[key] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				sorted = append(sorted, entry)
+				added[hex.EncodeToString(entry.Tx.TxHash)] = true
+			} else {
+				rest = append(rest, entry)
+			}
+		}
+		if len(rest) == len(pending) {
+			// 不会出现循环依赖，防御性处理
+			sorted = append(sorted, rest...)
+			break
+		}
+		pending = rest
+	}
+	return sorted
+}
+
+// readMempoolEntries 在数据库事务中读取交易池中的所有条目
+func readMempoolEntries(dbTx *bolt.Tx) (map[string]*MempoolEntry, error) {
+	entries := make(map[string]*MempoolEntry)
+	b := dbTx.Bucket([]byte(mempoolTableName))
+	if nil == b {
+		return entries, nil
+	}
+	err := b.ForEach(func(k, v []byte) error {
+		entry, err := deserializeMempoolEntry(v)
+		if nil != err {
+			return err
+		}
+		entries[hex.EncodeToString(k)] = entry
+		return nil
+	})
+	return entries, err
+}
+
+// removeForBlock 区块连接到主链时，从交易池中删除区块中的交易、
+// 与区块中的交易花费了相同输出的交易，以及依赖这些冲突交易的交易
+func removeForBlock(dbTx *bolt.Tx, block *Block) error {
+	b := dbTx.Bucket([]byte(mempoolTableName))
+	if nil == b {
+		return nil
+	}
+	entries, err := readMempoolEntries(dbTx)
+	if nil != err {
+		return err
+	}
+	blockSpent := make(map[string]bool)
+	for _, tx := range block.Txs {
+		for _, vin := range tx.Vins {
+			blockSpent[fmt.Sprintf("%x:%d", vin.TxHash, vin.Vout)] = true
+		}
+	}
+	// 与区块冲突的交易
+	evicted := make(map[string]bool)
+	for key, entry := range entries {
+		if blockContains(block, entry.Tx.TxHash) {
+			continue
+		}
+		for _, vin := range entry.Tx.Vins {
+			if blockSpent[fmt.Sprintf("%x:%d", vin.TxHash, vin.Vout)] {
+				evicted[key] = true
+			}
+		}
+	}
+	// 依赖冲突交易的交易永远无法上链，一并删除
+	for changed := true; changed; {
+		changed = false
+		for key, entry := range entries {
+			if evicted[key] {
+				continue
+			}
+			for _, vin := range entry.Tx.Vins {
+				if evicted[hex.EncodeToString(vin.TxHash)] {
+					evicted[key] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	for _, tx := range block.Txs {
+		if err := b.Delete(tx.TxHash); nil != err {
+			return err
+		}
+	}
+	for key := range evicted {
+		txHash, _ := hex.DecodeString(key)
+		if err := b.Delete(txHash); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// blockContains 判断区块中是否包含指定交易
+func blockContains(block *Block, txHash []byte) bool {
+	for _, tx := range block.Txs {
+		if bytes.Equal(tx.TxHash, txHash) {
+			return true
+		}
+	}
+	return false
+}
+
+// serialize 交易池条目序列化：int64 进入时间 + int64 手续费 + bytes 交易编码
+func (entry *MempoolEntry) serialize() []byte {
+	e := &encoder{}
+	e.writeInt64(entry.Time)
+	e.writeInt64(int64(entry.Fee))
+	e.writeBytes(entry.Tx.Serialize())
+	if nil != e.err {
+		log.Panicf("serialize the mempool entry failed! %v\n", e.err)
+	}
+	return e.buf.Bytes()
+}
+
+// deserializeMempoolEntry 交易池条目反序列化
+func deserializeMempoolEntry(data []byte) (*MempoolEntry, error) {
+	d := &decoder{data: data}
+	entry := &MempoolEntry{Time: d.readInt64(), Fee: int(d.readInt64())}
+	txBytes := d.readBytes()
+	if err := d.finish("mempool entry"); nil != err {
+		return nil, err
+	}
+	tx, err := DecodeTransaction(txBytes)
+	if nil != err {
+		return nil, err
+	}
+	entry.Tx = tx
+	return entry, nil
+}
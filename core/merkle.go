@@ -1,12 +1,21 @@
 package core
 
-import "crypto/sha256"
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+)
 
 // Merkle 树实现管理
 
 type MerkleTree struct {
 	// 根节点
 	RootNode	*MerkleNode
+	// 是否存在相邻的两个相同节点（例如重复的交易），这种树与复制最后一个节点得到的树根相同，
+	// 攻击者可以借此构造出 Merkle 根相同但交易列表不同的区块，所以这种树对应的区块是无效的
+	Mutated		bool
+	// 每一层的节点，levels[0] 为叶子节点，最后一层为根节点
+	levels		[][]*MerkleNode
 }
 
 // MerkleNode merkle节点结构
@@ -19,36 +28,49 @@ type MerkleNode struct {
 	Data	[]byte
 }
 
+// MerkleProof 交易存在于区块中的证明
+type MerkleProof struct {
+	// 叶子节点的位置，从低位开始，第 i 位为 1 表示第 i 层的节点是右子节点
+	Index	int
+	// 从叶子节点到根节点路径上每一层的兄弟节点哈希
+	Hashes	[][]byte
+}
+
 // NewMerkleTree 创建 Merkle 树，Merkle 根节点之外的其他层次节点数量必须是偶数个，
-// 如果是奇数个，则将最后一个节点复制一份
+// 如果是奇数个，则将最后一个节点复制一份，逐层计算，直到只剩下根节点
 // @txHashes：区块中的交易哈希列表
 func NewMerkleTree(txHashes [][]byte) *MerkleTree {
-	// 节点列表
-	var nodes []MerkleNode
-	// 判断交易数据条数，如果是奇数，则拷贝最后一份
-	if len(txHashes) % 2 != 0 {
-		txHashes = append(txHashes, txHashes[len(txHashes) - 1])
+	mTree := &MerkleTree{}
+	// 没有交易时，根节点为空数据的哈希
+	if len(txHashes) == 0 {
+		mTree.RootNode = NewMerkleNode(nil, nil, nil)
+		mTree.levels = [][]*MerkleNode{{mTree.RootNode}}
+		return mTree
 	}
 	// 遍历所有交易数据，通过哈希生成叶子节点
+	var nodes []*MerkleNode
 	for _, data := range txHashes {
-		node := NewMerkleNode(nil, nil, data)
-		nodes = append(nodes, *node)
+		nodes = append(nodes, NewMerkleNode(nil, nil, data))
 	}
-	// 通过叶子节点创建父节点
-	for i := 0; i < len(txHashes) / 2; i++ {
-		var parentNodes []MerkleNode // 父节点列表
+	mTree.levels = append(mTree.levels, nodes)
+	// 通过子节点创建父节点，直到只剩下根节点
+	for len(nodes) > 1 {
+		var parentNodes []*MerkleNode // 父节点列表
 		for j := 0; j < len(nodes); j += 2 {
-			node := NewMerkleNode(&nodes[j], &nodes[j+1], nil)
-			parentNodes = append(parentNodes, *node)
+			right := j + 1
+			if right == len(nodes) {
+				// 奇数个节点，最后一个节点与自身组合
+				right = j
+			} else if bytes.Equal(nodes[j].Data, nodes[right].Data) {
+				mTree.Mutated = true
+			}
+			parentNodes = append(parentNodes, NewMerkleNode(nodes[j], nodes[right], nil))
 		}
-		if len(parentNodes) % 2 != 0 {
-			parentNodes = append(parentNodes, parentNodes[len(parentNodes)-1])
-		}
-		// 最终，nodes 只保存了根节点的哈希值
 		nodes = parentNodes
+		mTree.levels = append(mTree.levels, nodes)
 	}
-	mTree := MerkleTree{&nodes[0]}
-	return &mTree
+	mTree.RootNode = nodes[0]
+	return mTree
 }
 
 // NewMerkleNode 创建 Merkle 节点
@@ -60,7 +82,7 @@ func NewMerkleNode(left, right *MerkleNode, data []byte) *MerkleNode {
 		node.Data = hash[:]
 	} else {
 		// 非叶子节点
-		prevHashes := append(left.Data, right.Data...)
+		prevHashes := append(append([]byte{}, left.Data...), right.Data...)
 		hash := sha256.Sum256(prevHashes)
 		node.Data = hash[:]
 	}
@@ -69,3 +91,48 @@ func NewMerkleNode(left, right *MerkleNode, data []byte) *MerkleNode {
 	node.Right = right
 	return node
 }
+
+// Proof 生成指定交易存在于树中的证明
+func (mTree *MerkleTree) Proof(txHash []byte) (*MerkleProof, error) {
+	leaf := sha256.Sum256(txHash)
+	index := -1
+	for i, node := range mTree.levels[0] {
+		if bytes.Equal(node.Data, leaf[:]) {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("the transaction [%x] is not in the merkle tree", txHash)
+	}
+	proof := &MerkleProof{Index: index}
+	// 逐层向上，记录兄弟节点
+	for _, nodes := range mTree.levels[:len(mTree.levels)-1] {
+		sibling := index ^ 1
+		if sibling >= len(nodes) {
+			// 奇数个节点时最后一个节点与自身组合
+			sibling = index
+		}
+		proof.Hashes = append(proof.Hashes, nodes[sibling].Data)
+		index /= 2
+	}
+	return proof, nil
+}
+
+// VerifyProof 验证交易存在的证明，只需要区块头中的 Merkle 根
+func VerifyProof(root []byte, txHash []byte, proof *MerkleProof) bool {
+	if nil == proof || proof.Index < 0 || proof.Index>>uint(len(proof.Hashes)) != 0 {
+		return false
+	}
+	hash := sha256.Sum256(txHash)
+	node := hash[:]
+	for i, sibling := range proof.Hashes {
+		if (proof.Index>>uint(i))&1 == 1 {
+			hash = sha256.Sum256(append(append([]byte{}, sibling...), node...))
+		} else {
+			hash = sha256.Sum256(append(append([]byte{}, node...), sibling...))
+		}
+		node = hash[:]
+	}
+	return bytes.Equal(node, root)
+}
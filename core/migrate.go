@@ -0,0 +1,293 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"os"
+)
+
+// 数据库迁移管理文件
+
+// 旧版本的数据库使用 gob 编码保存区块，或者使用第 1 版规范编码（交易输入为签名 + 公钥，输出为公钥哈希），
+// 交易哈希与区块哈希也由旧的编码计算得出。
+// 迁移时沿着旧数据库的主链从创世区块开始，按当前的规范编码重新生成每一个区块：
+// 1. 每笔交易转换为第 0 版的迁移交易，保留旧的交易哈希与签名，不需要任何私钥
+// 2. 保留旧区块的高度与时间戳，重新计算 Merkle 根与难度，不重新执行工作量证明
+// 迁移的结果只由旧数据库决定，不同节点迁移同一个旧数据库得到完全相同的区块链。
+// 新区块写入新的数据库，UTXO 集合、撤销记录与累计工作量随之重建。迁移交易只能出现在迁移得到的区块中，
+// 从网络收到的区块不能包含迁移交易。
+// 侧链区块不会被迁移；旧数据库保留为 block_<id>.db.legacy
+
+// legacyBlock 旧版本区块的 gob 结构，同时兼容拆分区块头之前的字段布局
+type legacyBlock struct {
+	BlockHeader   legacyBlockHeader
+	TimeStamp     int64
+	Hash          []byte
+	PrevBlockHash []byte
+	Height        int64
+	Nonce         int64
+	Txs           []*legacyTransaction
+}
+
+// legacyBlockHeader 旧版本区块头
+type legacyBlockHeader struct {
+	PrevBlockHash []byte
+	TimeStamp     int64
+	Height        int64
+}
+
+// legacyTransaction 旧版本交易
+type legacyTransaction struct {
+	TxHash []byte
+	Vins   []*legacyTxInput
+	Vouts  []*legacyTxOutput
+}
+
+// legacyTxInput 旧版本交易输入
+type legacyTxInput struct {
+	TxHash    []byte
+	Vout      int
+	Signature []byte
+	PublicKey []byte
+}
+
+// legacyTxOutput 旧版本交易输出
+type legacyTxOutput struct {
+	Value         int
+	Ripemd160Hash []byte
+}
+
+// decodeLegacyBlock 解析旧版本（gob 编码）的区块
+func decodeLegacyBlock(blockBytes []byte) (*legacyBlock, error) {
+	var block legacyBlock
+	if err := gob.NewDecoder(bytes.NewReader(blockBytes)).Decode(&block); nil != err {
+		return nil, err
+	}
+	// 拆分区块头之后的区块，字段保存在区块头中
+	if len(block.BlockHeader.PrevBlockHash) > 0 || block.BlockHeader.Height > 0 {
+		block.PrevBlockHash = block.BlockHeader.PrevBlockHash
+		block.TimeStamp = block.BlockHeader.TimeStamp
+		block.Height = block.BlockHeader.Height
+	}
+	return &block, nil
+}
+
+// decodeCanonicalV1Block 解析第 1 版规范编码的区块，区块头与当前版本相同，交易没有脚本：
+// 输入为 bytes 交易哈希 + int32 输出索引 + bytes 签名 + bytes 公钥，输出为 int64 金额 + bytes 公钥哈希
+func decodeCanonicalV1Block(blockBytes []byte) (*legacyBlock, error) {
+	d := &decoder{data: blockBytes}
+	header := d.readHeader()
+	block := &legacyBlock{PrevBlockHash: header.PrevBlockHash, TimeStamp: header.TimeStamp, Height: header.Height}
+	for i, n := 0, d.readCount(4); i < n; i++ {
+		txBytes := d.readBytes()
+		if nil != d.err {
+			break
+		}
+		td := &decoder{data: txBytes}
+		if version := td.readInt32(); nil == td.err && version != 1 {
+			return nil, fmt.Errorf("unsupported transaction version %d", version)
+		}
+		tx := &legacyTransaction{}
+		for j, m := 0, td.readCount(16); j < m; j++ {
+			tx.Vins = append(tx.Vins, &legacyTxInput{
+				TxHash:    td.readBytes(),
+				Vout:      int(td.readInt32()),
+				Signature: td.readBytes(),
+				PublicKey: td.readBytes(),
+			})
+		}
+		for j, m := 0, td.readCount(12); j < m; j++ {
+			tx.Vouts = append(tx.Vouts, &legacyTxOutput{Value: int(td.readInt64()), Ripemd160Hash: td.readBytes()})
+		}
+		if err := td.finish("legacy transaction"); nil != err {
+			return nil, err
+		}
+		txHash := sha256.Sum256(txBytes)
+		tx.TxHash = txHash[:]
+		block.Txs = append(block.Txs, tx)
+	}
+	if err := d.finish("legacy block"); nil != err {
+		return nil, err
+	}
+	block.Hash = header.Hash()
+	return block, nil
+}
+
+// MigrateBlockChain 把旧版本的数据库迁移为规范编码
+func MigrateBlockChain(nodeId string) error {
+	dbFile := fmt.Sprintf(DBName, nodeId)
+	if !DBExits(nodeId) {
+		return fmt.Errorf("the database [%s] does not exist", dbFile)
+	}
+	legacyBlocks, err := readLegacyChain(dbFile)
+	if nil != err {
+		return err
+	}
+	// 在临时文件中重建区块链
+	newFile := dbFile + ".migrating"
+	os.Remove(newFile)
+	db, err := bolt.Open(newFile, 0600, nil)
+	if nil != err {
+		return fmt.Errorf("open db [%s] failed %v", newFile, err)
+	}
+	bc := &BlockChain{DB: db}
+	err = bc.replayLegacyChain(legacyBlocks)
+	db.Close()
+	if nil != err {
+		os.Remove(newFile)
+		return err
+	}
+	if err := os.Rename(dbFile, dbFile+".legacy"); nil != err {
+		return err
+	}
+	return os.Rename(newFile, dbFile)
+}
+
+// readLegacyChain 读取旧数据库中的主链区块（按高度升序）
+func readLegacyChain(dbFile string) ([]*legacyBlock, error) {
+	db, err := bolt.Open(dbFile, 0600, nil)
+	if nil != err {
+		return nil, fmt.Errorf("open db [%s] failed %v", dbFile, err)
+	}
+	defer db.Close()
+	var blocks []*legacyBlock
+	err = db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(BlockTableName))
+		if nil == b {
+			return fmt.Errorf("the database [%s] has no blocks", dbFile)
+		}
+		hash := b.Get([]byte("1"))
+		if _, err := DecodeBlock(b.Get(hash)); nil == err {
+			return fmt.Errorf("the database [%s] is already migrated", dbFile)
+		}
+		for len(hash) > 0 {
+			blockBytes := b.Get(hash)
+			if nil == blockBytes {
+				return fmt.Errorf("the block [%x] is missing", hash)
+			}
+			block, err := decodeLegacyBlock(blockBytes)
+			if nil != err {
+				// 第 1 版规范编码
+				block, err = decodeCanonicalV1Block(blockBytes)
+			}
+			if nil != err {
+				return fmt.Errorf("decode the legacy block [%x] failed: %v", hash, err)
+			}
+			blocks = append(blocks, block)
+			hash = block.PrevBlockHash
+		}
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	for i, j := 0, len(blocks)-1; i < j; i, j = i+1, j-1 {
+		blocks[i], blocks[j] = blocks[j], blocks[i]
+	}
+	return blocks, nil
+}
+
+// replayLegacyChain 按规范编码重新生成旧的区块并写入区块链
+// 旧区块已经由旧版本的节点验证过，旧签名也无法按照当前的规则验证，所以只检查每个输入引用的输出存在并且没有被花费、
+// 输出金额不大于输入金额，然后直接保存区块、更新 UTXO 集合
+func (bc *BlockChain) replayLegacyChain(legacyBlocks []*legacyBlock) error {
+	utxoSet := &UTXOSet{Blockchain: bc}
+	var prev *BlockHeader
+	for _, legacy := range legacyBlocks {
+		var txs []*Transaction
+		for _, legacyTx := range legacy.Txs {
+			txs = append(txs, migrateTransaction(legacyTx))
+		}
+		// 保留旧区块的时间戳，难度按照当前的规则计算，区块只在本地导入，不需要重新执行工作量证明
+		bits, err := bc.CalcNextBits(prev)
+		if nil != err {
+			return err
+		}
+		block := &Block{
+			BlockHeader: BlockHeader{
+				Version:   blockVersion,
+				TimeStamp: legacy.TimeStamp,
+				Bits:      bits,
+				Height:    legacy.Height,
+			},
+			Txs: txs,
+		}
+		if nil != prev {
+			block.PrevBlockHash = prev.Hash()
+		}
+		block.MerkleRoot = block.HashTransaction()
+		block.Hash = block.BlockHeader.Hash()
+		if err := bc.checkMigratedTransactions(block); nil != err {
+			return fmt.Errorf("the legacy block [%x] at height %d is invalid: %v", legacy.Hash, legacy.Height, err)
+		}
+		bc.putBlock(block)
+		bc.setTip(block.Hash)
+		utxoSet.ConnectBlock(block)
+		prev = &block.BlockHeader
+	}
+	return nil
+}
+
+// checkMigratedTransactions 检查迁移区块中的交易只花费 UTXO 集合中或者同一区块中前面交易的输出，
+// 每个输出只能被花费一次，并且输出金额不大于输入金额。
+// 旧版本的节点在每笔转账之后添加一笔 coinbase 交易，所以迁移区块中的 coinbase 可以出现在任意位置，并且可以有多笔
+func (bc *BlockChain) checkMigratedTransactions(block *Block) error {
+	utxoSet := &UTXOSet{Blockchain: bc}
+	blockTxs := make(map[string]*Transaction)
+	spent := make(map[string]bool)
+	for _, tx := range block.Txs {
+		if _, ok := blockTxs[hex.EncodeToString(tx.TxHash)]; ok {
+			return fmt.Errorf("tx [%x] is duplicated", tx.TxHash)
+		}
+		if !tx.IsCoinbaseTransaction() {
+			var inputValue, outputValue int
+			for _, vin := range tx.Vins {
+				outpoint := fmt.Sprintf("%x:%d", vin.TxHash, vin.Vout)
+				if spent[outpoint] {
+					return fmt.Errorf("tx [%x] spends %s twice", tx.TxHash, outpoint)
+				}
+				spent[outpoint] = true
+				if prevTx, ok := blockTxs[hex.EncodeToString(vin.TxHash)]; ok {
+					if vin.Vout < 0 || vin.Vout >= len(prevTx.Vouts) {
+						return fmt.Errorf("tx [%x] spends a missing output %s", tx.TxHash, outpoint)
+					}
+					inputValue += prevTx.Vouts[vin.Vout].Value
+				} else if utxo := utxoSet.FindUTXO(vin.TxHash, vin.Vout); nil != utxo {
+					inputValue += utxo.Output.Value
+				} else {
+					return fmt.Errorf("tx [%x] spends a missing or spent output %s", tx.TxHash, outpoint)
+				}
+			}
+			for _, out := range tx.Vouts {
+				outputValue += out.Value
+			}
+			if outputValue > inputValue {
+				return fmt.Errorf("tx [%x] spends %d but only has %d", tx.TxHash, outputValue, inputValue)
+			}
+		}
+		blockTxs[hex.EncodeToString(tx.TxHash)] = tx
+	}
+	return nil
+}
+
+// migrateTransaction 把旧的交易转换为迁移交易：保留旧的交易哈希、引用的交易哈希与签名，
+// 解锁脚本为 <旧签名> <旧公钥>，输出使用 pay-to-pubkey-hash 锁定脚本
+func migrateTransaction(legacyTx *legacyTransaction) *Transaction {
+	tx := &Transaction{Version: migratedTxVersion, TxHash: legacyTx.TxHash}
+	for _, in := range legacyTx.Vins {
+		tx.Vins = append(tx.Vins, &TxInput{
+			TxHash:    in.TxHash,
+			Vout:      in.Vout,
+			ScriptSig: SignatureScript(in.Signature, in.PublicKey),
+			Sequence:  MaxSequence,
+		})
+	}
+	for _, out := range legacyTx.Vouts {
+		tx.Vouts = append(tx.Vouts, &TxOutput{Value: out.Value, ScriptPubKey: PayToPubKeyHashScript(out.Ripemd160Hash)})
+	}
+	return tx
+}
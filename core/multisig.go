@@ -0,0 +1,154 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+)
+
+// 多重签名交易管理文件
+
+// 花费多签地址的交易需要多个私钥持有者依次签名：
+//   1. 发起者生成交易，每个输入的解锁脚本为 <签名位置1> ... <签名位置n> <赎回脚本>，
+//      签名位置与赎回脚本中的公钥一一对应，尚未签名的位置为空
+//   2. 每个私钥持有者在自己的公钥对应的位置填入签名（签名哈希不包含解锁脚本，签名顺序不影响结果）
+//   3. 签名数量达到 m 之后，去掉空的签名位置，解锁脚本变为 <签名1> ... <签名m> <赎回脚本>
+
+// NewMultiSigTransaction 生成花费多签地址的转账交易，所有签名位置为空
+// @redeemScript：多签地址对应的赎回脚本
+func NewMultiSigTransaction(from string, to string, amount int, fee int, redeemScript []byte, bc *BlockChain,
+	txs []*Transaction) (*Transaction, error) {
+	if _, hash160, err := DecodeAddress(from); nil != err || !IsScriptHashAddress(from) ||
+		!bytes.Equal(hash160, Ripemd160Hash(redeemScript)) {
+		return nil, fmt.Errorf("the redeem script does not match the address [%s]", from)
+	}
+	_, pubKeys, ok := ExtractMultiSig(redeemScript)
+	if !ok {
+		return nil, fmt.Errorf("the redeem script of [%s] is not a multisig script", from)
+	}
+	// 余额不足时 FindSpendableUTXO 直接退出
+	money, spendableUTXODic := bc.FindSpendableUTXO(from, amount+fee, txs)
+	tx := &Transaction{Version: TxVersion}
+	for txHash, indexArray := range spendableUTXODic {
+		txHashBytes, err := hex.DecodeString(txHash)
+		if nil != err {
+			return nil, err
+		}
+		for _, index := range indexArray {
+			scriptSig := multiSigScriptSig(make([][]byte, len(pubKeys)), redeemScript)
+			tx.Vins = append(tx.Vins, &TxInput{txHashBytes, index, scriptSig, MaxSequence})
+		}
+	}
+	tx.Vouts = append(tx.Vouts, NewTxOutput(amount, to))
+	// 找零返回给多签地址
+	if money > amount+fee {
+		tx.Vouts = append(tx.Vouts, NewTxOutput(money-amount-fee, from))
+	}
+	tx.HashTransaction()
+	return tx, nil
+}
+
+// multiSigScriptSig 生成多签输入的解锁脚本：签名列表 + 赎回脚本
+func multiSigScriptSig(signatures [][]byte, redeemScript []byte) []byte {
+	builder := NewScriptBuilder()
+	for _, signature := range signatures {
+		builder.AddData(signature)
+	}
+	return builder.AddData(redeemScript).Script()
+}
+
+// partialMultiSig 解析尚未完成签名的多签输入，返回签名位置列表、赎回脚本与需要的签名数量
+func partialMultiSig(in *TxInput) ([][]byte, []byte, int, error) {
+	redeemScript := lastPushedData(in.ScriptSig)
+	m, pubKeys, ok := ExtractMultiSig(redeemScript)
+	if !ok {
+		return nil, nil, 0, fmt.Errorf("the input does not spend a multisig script")
+	}
+	data, err := PushedData(in.ScriptSig)
+	if nil != err {
+		return nil, nil, 0, err
+	}
+	signatures := data[:len(data)-1]
+	if len(signatures) != len(pubKeys) {
+		return nil, nil, 0, fmt.Errorf("the input is not partially signed (%d signature slots for %d pubkeys)",
+			len(signatures), len(pubKeys))
+	}
+	return signatures, redeemScript, m, nil
+}
+
+// SignMultiSig 使用钱包集合中的私钥为所有多签输入签名，返回新增的签名数量
+func (tx *Transaction) SignMultiSig(wallets *Wallets) (int, error) {
+	var added int
+	for index, vin := range tx.Vins {
+		signatures, redeemScript, _, err := partialMultiSig(vin)
+		if nil != err {
+			return added, fmt.Errorf("input %d: %v", index, err)
+		}
+		_, pubKeys, _ := ExtractMultiSig(redeemScript)
+		for i, pubKey := range pubKeys {
+			wallet := wallets.WalletForPubKey(pubKey)
+			if len(signatures[i]) != 0 || nil == wallet {
+				continue
+			}
+			// 签名哈希由交易副本与赎回脚本计算得出
+			signatures[i] = tx.signInput(index, &wallet.PrivateKey, redeemScript)
+			added++
+		}
+		vin.ScriptSig = multiSigScriptSig(signatures, redeemScript)
+	}
+	tx.HashTransaction()
+	return added, nil
+}
+
+// MultiSigProgress 多签交易的签名进度，返回所有输入中最少的签名数量与需要的签名数量
+func (tx *Transaction) MultiSigProgress() (int, int, error) {
+	have, need := -1, 0
+	for index, vin := range tx.Vins {
+		signatures, _, m, err := partialMultiSig(vin)
+		if nil != err {
+			return 0, 0, fmt.Errorf("input %d: %v", index, err)
+		}
+		var count int
+		for _, signature := range signatures {
+			if len(signature) != 0 {
+				count++
+			}
+		}
+		if have < 0 || count < have {
+			have = count
+		}
+		if m > need {
+			need = m
+		}
+	}
+	if have < 0 {
+		return 0, 0, fmt.Errorf("the transaction has no inputs")
+	}
+	return have, need, nil
+}
+
+// FinalizeMultiSig 签名数量达到要求后，把每个多签输入的解锁脚本变为 <签名1> ... <签名m> <赎回脚本>
+func (tx *Transaction) FinalizeMultiSig() error {
+	scriptSigs := make([][]byte, len(tx.Vins))
+	for index, vin := range tx.Vins {
+		signatures, redeemScript, m, err := partialMultiSig(vin)
+		if nil != err {
+			return fmt.Errorf("input %d: %v", index, err)
+		}
+		var final [][]byte
+		for _, signature := range signatures {
+			if len(signature) != 0 && len(final) < m {
+				final = append(final, signature)
+			}
+		}
+		if len(final) < m {
+			return fmt.Errorf("input %d has %d of %d signatures", index, len(final), m)
+		}
+		scriptSigs[index] = multiSigScriptSig(final, redeemScript)
+	}
+	for index, vin := range tx.Vins {
+		vin.ScriptSig = scriptSigs[index]
+	}
+	tx.HashTransaction()
+	return nil
+}
@@ -0,0 +1,120 @@
+package core
+
+import (
+	"fmt"
+	"math"
+)
+
+// 网络预设管理文件
+
+// 每个网络使用不同的网络魔数、地址版本前缀、种子节点、难度与创世区块，
+// 不同网络的数据库与钱包文件也相互独立（主网沿用原来的文件名）
+
+// 网络名称
+const (
+	MainNet = "mainnet"
+	TestNet = "testnet"
+	RegTest = "regtest"
+)
+
+// TestNetParams 测试网链参数：出块更快、难度更低
+var TestNetParams = ChainParams{
+	Name:      TestNet,
+	Consensus: ConsensusPoW,
+
+	Magic:       [4]byte{0x0b, 0x11, 0x09, 0x07},
+	DefaultPort: 13000,
+	Seeds:       []string{"localhost:13000"},
+
+	PubKeyHashAddrID: 0x6f,
+	ScriptHashAddrID: 0xc4,
+	Bech32HRP:        "tbkc",
+
+	PowLimit:         CompactToBig(0x207fffff),
+	PowLimitBits:     0x207fffff,
+	GenesisBits:      0x1f7fffff, // 相当于哈希值前 9 位为 0
+	TargetBlockTime:  5,
+	RetargetInterval: 10,
+
+	MedianTimeSpan:     11,
+	MaxFutureBlockTime: 2 * 60 * 60,
+
+	InitialSubsidy:         10,
+	SubsidyHalvingInterval: 1000,
+	MaxSupply:              18000,
+	CoinbaseMaturity:       5,
+
+	MaxDataCarrierSize: 80,
+
+	Genesis: GenesisSpec{
+		Timestamp: 1735689600,
+		Message:   "bkc testnet genesis",
+	},
+}
+
+// RegTestParams 回归测试网链参数：最低难度并且不调整难度，coinbase 输出下一个区块就能花费，没有种子节点
+var RegTestParams = ChainParams{
+	Name:      RegTest,
+	Consensus: ConsensusPoW,
+
+	Magic:       [4]byte{0xfa, 0xbf, 0xb5, 0xda},
+	DefaultPort: 18000,
+
+	PubKeyHashAddrID: 0x6f,
+	ScriptHashAddrID: 0xc4,
+	Bech32HRP:        "bkcrt",
+
+	PowLimit:         CompactToBig(0x207fffff),
+	PowLimitBits:     0x207fffff,
+	GenesisBits:      0x207fffff,
+	TargetBlockTime:  1,
+	RetargetInterval: math.MaxInt64, // 相当于不调整难度
+
+	MedianTimeSpan:     11,
+	MaxFutureBlockTime: 2 * 60 * 60,
+
+	InitialSubsidy:         10,
+	SubsidyHalvingInterval: 150,
+	MaxSupply:              3000,
+	CoinbaseMaturity:       1,
+
+	MaxDataCarrierSize: 80,
+
+	Genesis: GenesisSpec{
+		Timestamp: 1735689600,
+		Message:   "bkc regtest genesis",
+	},
+}
+
+// Networks 所有网络预设
+var Networks = map[string]*ChainParams{
+	MainNet: &DefaultParams,
+	TestNet: &TestNetParams,
+	RegTest: &RegTestParams,
+}
+
+// NetworkParams 当前选择的网络的链参数，打开区块链之前 ActiveParams 与它相同
+var NetworkParams = &DefaultParams
+
+// SelectNetwork 选择网络：使用网络预设的链参数，数据库与钱包文件名加上网络名称
+func SelectNetwork(name string) error {
+	params, ok := Networks[name]
+	if !ok {
+		return fmt.Errorf("unknown network [%s]", name)
+	}
+	NetworkParams, ActiveParams = params, params
+	if name == MainNet {
+		DBName, walletFile = "block_%s.db", "Wallets_%s.dat"
+	} else {
+		DBName, walletFile = "block_"+name+"_%s.db", "Wallets_"+name+"_%s.dat"
+	}
+	return nil
+}
+
+// SeedNodes 引导节点地址，没有种子节点时使用本机的默认端口
+func (params *ChainParams) SeedNodes() []string {
+	if len(params.Seeds) != 0 {
+		return params.Seeds
+	}
+	return []string{fmt.Sprintf("localhost:%d", params.DefaultPort)}
+}
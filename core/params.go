@@ -0,0 +1,127 @@
+package core
+
+import (
+	"bytes"
+	"encoding/gob"
+	"github.com/boltdb/bolt"
+	"log"
+	"math/big"
+)
+
+// 链参数管理文件
+
+// ChainParams 链参数，决定区块链的共识规则
+type ChainParams struct {
+	Name      string   // 网络名称
+	Consensus string   // 共识算法：ConsensusPoW 或 ConsensusPoA
+	Signers   [][]byte // poa 签名者公钥，按轮流出块的顺序排列
+
+	Magic       [4]byte  // 网络魔数，写在每条网络消息的开头并参与签名哈希的计算，不同网络的节点不会互相处理消息与交易
+	DefaultPort int      // 节点的默认端口，没有种子节点时作为引导节点的端口
+	Seeds       []string // 种子节点（引导节点）地址
+
+	PubKeyHashAddrID byte   // 公钥哈希地址的版本前缀
+	ScriptHashAddrID byte   // 脚本哈希地址的版本前缀
+	Bech32HRP        string // bech32 地址的可读前缀
+
+	PowLimit         *big.Int // 目标值上限（最低难度）
+	PowLimitBits     uint32   // 目标值上限的压缩形式
+	GenesisBits      uint32   // 创世区块难度（压缩形式）
+	TargetBlockTime  int64    // 期望的出块间隔（秒），poa 中为相邻区块的最小时间间隔
+	RetargetInterval int64    // 每隔多少个区块调整一次难度
+
+	MedianTimeSpan     int64 // 计算 median-time-past 使用的区块数量
+	MaxFutureBlockTime int64 // 区块时间戳最多超过网络调整时间多少秒
+
+	InitialSubsidy         int   // 初始区块奖励
+	SubsidyHalvingInterval int64 // 每隔多少个区块奖励减半
+	MaxSupply              int   // 货币发行总量上限
+	CoinbaseMaturity       int64 // coinbase 输出需要经过多少个区块才能被花费
+
+	MaxDataCarrierSize int // 数据输出最多携带的字节数（交易池策略，不影响区块验证）
+
+	Genesis GenesisSpec // 创世区块规格，创建区块链时没有指定地址则按照它生成创世区块
+}
+
+// DefaultParams 默认链参数（主网）
+var DefaultParams = ChainParams{
+	Name:      MainNet,
+	Consensus: ConsensusPoW,
+
+	Magic:       [4]byte{0xf9, 0xbe, 0xb4, 0xd9},
+	DefaultPort: 3000,
+	Seeds:       []string{"localhost:3000"},
+
+	PubKeyHashAddrID: 0x00,
+	ScriptHashAddrID: 0x05,
+	Bech32HRP:        "bkc",
+
+	PowLimit:         CompactToBig(0x207fffff),
+	PowLimitBits:     0x207fffff,
+	GenesisBits:      0x1f010000, // 相当于哈希值前 16 位为 0
+	TargetBlockTime:  10,
+	RetargetInterval: 10,
+
+	MedianTimeSpan:     11,
+	MaxFutureBlockTime: 2 * 60 * 60,
+
+	InitialSubsidy:         10,
+	SubsidyHalvingInterval: 1000,
+	MaxSupply:              18000, // 10*1000 + 5*1000 + 2*1000 + 1*1000
+	CoinbaseMaturity:       5,
+
+	MaxDataCarrierSize: 80,
+
+	Genesis: GenesisSpec{
+		Timestamp: 1735689600,
+		Message:   "bkc mainnet genesis",
+	},
+}
+
+// ActiveParams 当前使用的链参数，打开区块链时替换为数据库中保存的链参数
+var ActiveParams = &DefaultParams
+
+// ParamsTableName 链参数表名称，创建区块链时保存链参数，之后打开区块链时使用相同的共识规则
+var ParamsTableName = "params"
+
+// putChainParams 保存链参数
+func (bc *BlockChain) putChainParams(params *ChainParams) {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(params); nil != err {
+		log.Panicf("encode the chain params failed %v\n", err)
+	}
+	err := bc.DB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(ParamsTableName))
+		if nil != err {
+			return err
+		}
+		return b.Put([]byte("params"), buffer.Bytes())
+	})
+	if nil != err {
+		log.Panicf("save the chain params failed %v\n", err)
+	}
+}
+
+// loadChainParams 读取数据库中保存的链参数作为当前链参数，
+// 没有保存链参数的数据库（旧版本创建的数据库）以及数据库中没有保存的字段使用当前网络的链参数
+func loadChainParams(db *bolt.DB) {
+	params := *NetworkParams
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(ParamsTableName))
+		if nil == b {
+			return nil
+		}
+		data := b.Get([]byte("params"))
+		if nil == data {
+			return nil
+		}
+		return gob.NewDecoder(bytes.NewReader(data)).Decode(&params)
+	})
+	if nil != err {
+		log.Panicf("load the chain params failed %v\n", err)
+	}
+	if err := CheckConsensusParams(params.Consensus, params.Signers); nil != err {
+		log.Panicf("the chain params in the db are invalid %v\n", err)
+	}
+	ActiveParams = &params
+}
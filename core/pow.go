@@ -1,77 +1,236 @@
 package core
 
 import (
-	"bkc/utils"
 	"bytes"
+	"context"
 	"crypto/sha256"
-	"fmt"
+	"encoding/binary"
 	"math"
 	"math/big"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // 共识算法管理文件
 
 // 实现 POW 实例以及相关功能
 
-// Nonce 循环上线
-var maxNonce = int64(math.MaxInt64)
+// Nonce 循环上限，nonce 用尽之后更新时间戳或者 coinbase 中的额外随机数
+var maxNonce = int64(math.MaxUint32)
 
-// 目标值难度
-const targetBit = 16
+// checkInterval 每尝试多少次哈希检查一次是否需要中断、是否需要报告进度
+const checkInterval = 1 << 14
+
+// progressInterval 进度报告的时间间隔
+const progressInterval = time.Second
+
+// MiningProgress 挖矿进度
+type MiningProgress struct {
+	Hashes   int64         // 已经尝试的哈希次数
+	Elapsed  time.Duration // 已经花费的时间
+	HashRate float64       // 算力（次/秒）
+}
+
+// ProgressFunc 挖矿进度回调函数
+type ProgressFunc func(progress *MiningProgress)
 
 // ProofOfWork 工作量证明的结构
 type ProofOfWork struct {
 	Block 	*Block    // 需要共识验证的区块
 	target	*big.Int // 目标难度的哈希（大数据存储）
+	Progress	ProgressFunc // 进度回调，为空时不报告进度
+	Workers	int	// 并行计算的 goroutine 数量，小于 1 时使用全部 CPU 核心
 }
 
 // NewProofOfWork 创建一个 POW 对象
 func NewProofOfWork(block *Block) *ProofOfWork {
-	target := big.NewInt(1)
-	// 假设数据总长度为 8 位，需求：需要满足前两位为 0，才能解决问题
-	target = target.Lsh(target, 256 -targetBit)
-	// strTarget
+	// 目标值由区块中记录的难度（bits）还原
+	target := CompactToBig(block.Bits)
 	return &ProofOfWork{
 		Block: block,
 		target: target,
 	}
 }
 
-// Run 执行 pow，比较哈希值，返回哈希值以及碰撞的次数
-func (pow *ProofOfWork) Run() ([]byte, int64) {
-	// 碰撞次数
-	var hashInt big.Int
-	var hash [32]byte    // 生成的哈希值
-	var nonce int64 = 0
-	// 无限循环，生成符合调整的哈希值
-	for nonce < maxNonce {
-		// 生成准备数据
-		dataBytes := pow.prepareData(nonce)
-		hash = sha256.Sum256(dataBytes)
-		hashInt.SetBytes(hash[:])
-		// 检测生成的哈希值是否符合条件
-		if pow.target.Cmp(&hashInt) == 1 {
-			// 找到了符合条件的哈希值，中断循环
-			break
+// powResult 满足目标难度的哈希值以及对应的 nonce
+type powResult struct {
+	hash  []byte
+	nonce int64
+}
+
+// Run 执行 pow，比较哈希值，返回哈希值以及对应的 nonce
+// nonce 空间平均分给多个 goroutine 并行计算，区块头只序列化一次，每次尝试只修改其中的 nonce；
+// ctx 被取消（例如最新区块发生变化、节点退出）时停止计算，返回 ctx 的错误；
+// nonce 用尽时更新区块的时间戳，时间戳没有变化时更新 coinbase 中的额外随机数（同时更新 Merkle 根）
+func (pow *ProofOfWork) Run(ctx context.Context) ([]byte, int64, error) {
+	workers := pow.Workers
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	// 目标值超出哈希的范围时，任何哈希都满足条件
+	target, ok := pow.targetBytes()
+	// 碰撞次数（所有 goroutine 共享）
+	var hashes int64
+	start := time.Now()
+	var tick <-chan time.Time
+	if nil != pow.Progress {
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		// 每个区块模板只序列化一次区块头
+		header := pow.Block.BlockHeader.Serialize()
+		roundCtx, cancelRound := context.WithCancel(ctx)
+		found := make(chan powResult, workers)
+		var wg sync.WaitGroup
+		span := (maxNonce + 1) / int64(workers)
+		for i := 0; i < workers; i++ {
+			first, last := int64(i)*span, int64(i+1)*span-1
+			if i == workers-1 {
+				last = maxNonce
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				pow.search(roundCtx, header, target, !ok, first, last, &hashes, found)
+			}()
 		}
-		nonce++
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		var result *powResult
+	wait:
+		for {
+			select {
+			case r := <-found:
+				result = &r
+				break wait
+			case <-done:
+				// 所有 goroutine 都已经结束，可能同时找到了结果
+				select {
+				case r := <-found:
+					result = &r
+				default:
+				}
+				break wait
+			case <-tick:
+				pow.report(atomic.LoadInt64(&hashes), start)
+			}
+		}
+		cancelRound()
+		<-done
+		if nil != result {
+			pow.report(atomic.LoadInt64(&hashes), start)
+			return result.hash, result.nonce, nil
+		}
+		if nil != ctx.Err() {
+			pow.report(atomic.LoadInt64(&hashes), start)
+			return nil, 0, ctx.Err()
+		}
+		// nonce 用尽，修改区块头之后重新计算
+		pow.rollBlock()
+	}
+}
+
+// search 在 [first, last] 范围内查找满足目标难度的 nonce，找到之后写入 found
+// header 为区块头的序列化数据，每次尝试只修改其中 nonce 所在的字节
+func (pow *ProofOfWork) search(ctx context.Context, header []byte, target [32]byte, anyHash bool,
+	first int64, last int64, hashes *int64, found chan<- powResult) {
+	data := append([]byte{}, header...)
+	nonceBytes := data[len(data)-headerNonceOffset : len(data)-headerNonceOffset+8]
+	var count int64
+	for nonce := first; nonce <= last; nonce++ {
+		if count == checkInterval {
+			atomic.AddInt64(hashes, count)
+			count = 0
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+		}
+		binary.LittleEndian.PutUint64(nonceBytes, uint64(nonce))
+		hash := sha256.Sum256(data)
+		count++
+		// 哈希值与目标值都是 32 字节的大端序数据，可以直接按字节比较
+		if anyHash || bytes.Compare(hash[:], target[:]) < 0 {
+			atomic.AddInt64(hashes, count)
+			found <- powResult{hash: hash[:], nonce: nonce}
+			return
+		}
+	}
+	atomic.AddInt64(hashes, count)
+}
+
+// targetBytes 目标值的 32 字节大端序表示，目标值超出 256 位时返回 false
+// 目标值不是正数时返回全 0，任何哈希都不满足条件
+func (pow *ProofOfWork) targetBytes() ([32]byte, bool) {
+	var target [32]byte
+	if pow.target.Sign() <= 0 {
+		return target, true
+	}
+	if pow.target.BitLen() > 256 {
+		return target, false
+	}
+	pow.target.FillBytes(target[:])
+	return target, true
+}
+
+// report 报告挖矿进度
+func (pow *ProofOfWork) report(hashes int64, start time.Time) {
+	if nil == pow.Progress {
+		return
+	}
+	progress := &MiningProgress{Hashes: hashes, Elapsed: time.Since(start)}
+	if seconds := progress.Elapsed.Seconds(); seconds > 0 {
+		progress.HashRate = float64(hashes) / seconds
+	}
+	pow.Progress(progress)
+}
+
+// rollBlock nonce 用尽之后修改区块头，得到新的 nonce 空间：
+// 优先使用当前时间更新时间戳，时间戳不能更新时增加 coinbase 中的额外随机数
+func (pow *ProofOfWork) rollBlock() {
+	block := pow.Block
+	if now := time.Now().Unix(); now > block.TimeStamp {
+		block.TimeStamp = now
+		return
+	}
+	if len(block.Txs) == 0 || !block.Txs[0].IsCoinbaseTransaction() {
+		// 没有 coinbase 交易，只能等待时间戳变化
+		block.TimeStamp++
+		return
 	}
-	fmt.Printf("\n碰撞次数：%v\n", nonce)
-	return hash[:], nonce
+	coinbase := block.Txs[0]
+	coinbase.Vins[0].ScriptSig = coinbaseScript(block.Height, coinbase.coinbaseExtraNonce()+1)
+	coinbase.HashTransaction()
+	block.MerkleRoot = block.HashTransaction()
 }
 
-// prepareData 生成准备数据
+// prepareData 生成准备数据：使用指定 nonce 的区块头序列化数据
 func (pow *ProofOfWork) prepareData(nonce int64) []byte {
-	// 拼接而区块属性，进行哈希计算
-	timeStampBytes := utils.IntToHex(pow.Block.TimeStamp)
-	heightBytes := utils.IntToHex(pow.Block.Height)
-	data := bytes.Join([][]byte{
-		heightBytes,
-		timeStampBytes,
-		pow.Block.PrevBlockHash,
-		pow.Block.HashTransaction(),
-		utils.IntToHex(targetBit),
-		utils.IntToHex(nonce),
-	}, []byte{})
-	return data
-}
\ No newline at end of file
+	header := pow.Block.BlockHeader
+	header.Nonce = nonce
+	return header.Serialize()
+}
+
+// Validate 验证区块的工作量证明是否有效：区块哈希必须由区块数据计算得出，并且小于目标值
+func (pow *ProofOfWork) Validate() bool {
+	hash := sha256.Sum256(pow.prepareData(pow.Block.Nonce))
+	if !bytes.Equal(hash[:], pow.Block.Hash) {
+		return false
+	}
+	return pow.checkHash(hash[:])
+}
+
+// checkHash 判断哈希值是否小于目标值
+func (pow *ProofOfWork) checkHash(hash []byte) bool {
+	var hashInt big.Int
+	hashInt.SetBytes(hash)
+	return pow.target.Sign() > 0 && pow.target.Cmp(&hashInt) == 1
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// 原始交易管理文件
+
+// 原始交易由调用者直接指定输入（包括序列号）、输出与锁定时间，签名与提交分别进行：
+// 签名之后的交易可以保存起来，在锁定时间到达之后再提交到交易池
+
+// NewRawTransaction 生成未签名的原始交易
+func NewRawTransaction(vins []*TxInput, vouts []*TxOutput, lockTime uint32) *Transaction {
+	tx := &Transaction{Version: TxVersion, Vins: vins, Vouts: vouts, LockTime: lockTime}
+	tx.HashTransaction()
+	return tx
+}
+
+// SignRawTransaction 使用钱包集合中的私钥为原始交易签名，返回签名的输入个数
+// 只为花费 pay-to-pubkey-hash 输出的输入签名，被花费的输出在交易池或者 UTXO 集合中查找
+func (bc *BlockChain) SignRawTransaction(tx *Transaction, wallets *Wallets) (int, error) {
+	poolTxs := (&Mempool{Blockchain: bc}).Transactions()
+	utxoSet := &UTXOSet{Blockchain: bc}
+	var signed int
+	for index, vin := range tx.Vins {
+		var output *TxOutput
+		for _, poolTx := range poolTxs {
+			if bytes.Equal(poolTx.TxHash, vin.TxHash) && vin.Vout >= 0 && vin.Vout < len(poolTx.Vouts) {
+				output = poolTx.Vouts[vin.Vout]
+			}
+		}
+		if nil == output {
+			if utxo := utxoSet.FindUTXO(vin.TxHash, vin.Vout); nil != utxo {
+				output = utxo.Output
+			}
+		}
+		if nil == output {
+			return signed, fmt.Errorf("input %d spends a missing or spent output %x:%d", index, vin.TxHash, vin.Vout)
+		}
+		wallet := wallets.WalletForPubKeyHash(ExtractPubKeyHash(output.ScriptPubKey))
+		if nil == wallet {
+			continue
+		}
+		signature := tx.signInput(index, &wallet.PrivateKey, output.ScriptPubKey)
+		vin.ScriptSig = SignatureScript(signature, wallet.PublicKey)
+		signed++
+	}
+	tx.HashTransaction()
+	return signed, nil
+}
@@ -0,0 +1,225 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"log"
+	"math/big"
+)
+
+// 分叉与链重组管理文件
+
+// 所有区块（包括侧链区块）都保存在区块表中，最新区块指向累计工作量最大的分支
+
+// chainWorkTableName 保存每个区块累计工作量的表
+const chainWorkTableName = "chainwork"
+
+// GetChainWork 获取从创世区块到指定区块的累计工作量
+func (bc *BlockChain) GetChainWork(hash []byte) *big.Int {
+	var workBytes []byte
+	bc.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(chainWorkTableName))
+		if nil != b {
+			workBytes = b.Get(hash)
+			if nil != workBytes {
+				workBytes = append([]byte{}, workBytes...)
+			}
+		}
+		return nil
+	})
+	if nil != workBytes {
+		return new(big.Int).SetBytes(workBytes)
+	}
+	// 旧版本数据库中没有保存累计工作量，沿着区块链向前计算
+	work := big.NewInt(0)
+	for len(hash) > 0 {
+		header := bc.GetHeader(hash)
+		if nil == header {
+			break
+		}
+		work.Add(work, CalcWork(header.Bits))
+		hash = header.PrevBlockHash
+	}
+	return work
+}
+
+// putBlock 保存区块及其累计工作量（不改变最新区块）
+func (bc *BlockChain) putBlock(block *Block) {
+	work := CalcWork(block.Bits)
+	if len(block.PrevBlockHash) > 0 {
+		work.Add(work, bc.GetChainWork(block.PrevBlockHash))
+	}
+	err := bc.DB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(BlockTableName))
+		if nil != err {
+			return err
+		}
+		if err := b.Put(block.Hash, block.Serialize()); nil != err {
+			return err
+		}
+		hb, err := tx.CreateBucketIfNotExists([]byte(HeaderTableName))
+		if nil != err {
+			return err
+		}
+		if err := hb.Put(block.Hash, block.BlockHeader.Serialize()); nil != err {
+			return err
+		}
+		wb, err := tx.CreateBucketIfNotExists([]byte(chainWorkTableName))
+		if nil != err {
+			return err
+		}
+		return wb.Put(block.Hash, work.Bytes())
+	})
+	if nil != err {
+		log.Panicf("save the block [%x] failed! %v\n", block.Hash, err)
+	}
+}
+
+// removeBlocks 从数据库中删除区块（用于丢弃无效的分支）
+func (bc *BlockChain) removeBlocks(blocks []*Block) {
+	err := bc.DB.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{BlockTableName, HeaderTableName, chainWorkTableName} {
+			b := tx.Bucket([]byte(name))
+			if nil == b {
+				continue
+			}
+			for _, block := range blocks {
+				if err := b.Delete(block.Hash); nil != err {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if nil != err {
+		log.Panicf("remove the invalid blocks failed! %v\n", err)
+	}
+}
+
+// setTip 更新最新区块的哈希值
+func (bc *BlockChain) setTip(hash []byte) {
+	err := bc.DB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(BlockTableName))
+		if nil != err {
+			return err
+		}
+		return b.Put([]byte("1"), hash)
+	})
+	if nil != err {
+		log.Panicf("update the latest block hash to db failed %v\n", err)
+	}
+	// 通知正在等待最新区块变化的 goroutine
+	bc.tipMutex.Lock()
+	bc.tip = hash
+	if nil != bc.tipChanged {
+		close(bc.tipChanged)
+		bc.tipChanged = nil
+	}
+	bc.tipMutex.Unlock()
+}
+
+// findForkPoint 查找新分支与当前主链的分叉点
+// 返回分叉点区块，以及从分叉点之后到 newTip 的新分支区块（按高度升序），两个分支没有共同的祖先时返回错误
+func (bc *BlockChain) findForkPoint(newTip *Block) (*Block, []*Block, error) {
+	oldBlock := bc.getBlock(bc.TipHash())
+	newBlock := newTip
+	var attach []*Block
+	// prevOf 前一个区块，回退到创世区块之前说明没有共同的祖先
+	prevOf := func(block *Block) (*Block, error) {
+		prev := bc.getBlock(block.PrevBlockHash)
+		if nil == prev {
+			return nil, fmt.Errorf("the branch of [%x] has no common ancestor with the main chain", newTip.Hash)
+		}
+		return prev, nil
+	}
+	var err error
+	// 先把新分支回退到与主链相同的高度
+	for newBlock.Height > oldBlock.Height {
+		attach = append(attach, newBlock)
+		if newBlock, err = prevOf(newBlock); nil != err {
+			return nil, nil, err
+		}
+	}
+	for oldBlock.Height > newBlock.Height {
+		if oldBlock, err = prevOf(oldBlock); nil != err {
+			return nil, nil, err
+		}
+	}
+	// 同时回退，直到两个分支的区块相同
+	for !bytes.Equal(oldBlock.Hash, newBlock.Hash) {
+		attach = append(attach, newBlock)
+		if newBlock, err = prevOf(newBlock); nil != err {
+			return nil, nil, err
+		}
+		if oldBlock, err = prevOf(oldBlock); nil != err {
+			return nil, nil, err
+		}
+	}
+	// 反转为升序
+	for i, j := 0, len(attach)-1; i < j; i, j = i+1, j-1 {
+		attach[i], attach[j] = attach[j], attach[i]
+	}
+	return newBlock, attach, nil
+}
+
+// reorganize 链重组：根据撤销记录依次断开当前主链上分叉点之后的区块，再依次连接新分支的区块
+// 连接时对每个区块的交易进行完整验证，只要有一个区块无效，就丢弃该区块及其之后的区块，恢复原来的主链
+func (bc *BlockChain) reorganize(newTip *Block) error {
+	fork, attach, err := bc.findForkPoint(newTip)
+	if nil != err {
+		return rejectBlock(newTip, RejectOrphan, "%v", err)
+	}
+	// 旧分支上需要断开的区块（从最新区块开始）
+	var detach []*Block
+	for block := bc.getBlock(bc.TipHash()); !bytes.Equal(block.Hash, fork.Hash); block = bc.getBlock(block.PrevBlockHash) {
+		detach = append(detach, block)
+	}
+	fmt.Printf("reorganize: fork at height %d [%x], disconnect %d blocks, connect %d blocks\n",
+		fork.Height, fork.Hash, len(detach), len(attach))
+	utxoSet := &UTXOSet{Blockchain: bc}
+	// 1. 断开旧分支，UTXO 集合回到分叉点时的状态
+	for _, block := range detach {
+		if err := utxoSet.DisconnectBlock(block); nil != err {
+			// 没有撤销记录（例如旧版本数据库中的区块），只能在分叉点重建 UTXO 集合
+			fmt.Printf("%v, rebuild the utxo table at the fork point\n", err)
+			bc.setTip(fork.Hash)
+			utxoSet.ResetUTXOSet()
+			break
+		}
+		bc.setTip(block.PrevBlockHash)
+	}
+	// 2. 连接新分支
+	for i, block := range attach {
+		if err := bc.checkBlockTransactions(block); nil != err {
+			// 新分支无效：丢弃无效的区块，断开已经连接的新分支区块，重新连接旧分支
+			bc.removeBlocks(attach[i:])
+			for j := i - 1; j >= 0; j-- {
+				if err := utxoSet.DisconnectBlock(attach[j]); nil != err {
+					log.Panicf("disconnect the block [%x] failed! %v\n", attach[j].Hash, err)
+				}
+				bc.setTip(attach[j].PrevBlockHash)
+			}
+			for j := len(detach) - 1; j >= 0; j-- {
+				bc.setTip(detach[j].Hash)
+				utxoSet.ConnectBlock(detach[j])
+			}
+			(&Mempool{Blockchain: bc}).revalidate()
+			return err
+		}
+		bc.setTip(block.Hash)
+		utxoSet.ConnectBlock(block)
+	}
+	// 3. 旧分支中没有被新分支包含的交易，重新放回交易池
+	mempool := &Mempool{Blockchain: bc}
+	for i := len(detach) - 1; i >= 0; i-- {
+		for _, tx := range detach[i].Txs {
+			if !tx.IsCoinbaseTransaction() {
+				mempool.acceptTransaction(tx)
+			}
+		}
+	}
+	// 4. 删除输入已经无法找到的交易（例如花费了被新分支双花的交易的输出）
+	mempool.revalidate()
+	return nil
+}
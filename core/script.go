@@ -0,0 +1,609 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// 脚本管理文件
+
+// 交易输出中保存锁定脚本（ScriptPubKey），交易输入中保存解锁脚本（ScriptSig）。
+// 验证输入时先执行解锁脚本，再在同一个栈上执行被花费输出的锁定脚本，
+// 执行结束后栈顶元素为真，说明输入有权花费该输出。
+// 脚本由操作码组成，数据推入操作码后面紧跟要推入栈中的数据：
+//   0x01-0x4b：推入紧跟的 1-75 字节
+//   OP_PUSHDATA1/2/4：先读取 1/2/4 字节（小端序）的长度，再推入对应长度的数据
+
+// 操作码
+const (
+	OP_0                   = 0x00 // 推入空数据（假）
+	OP_PUSHDATA1           = 0x4c // 后面 1 字节为数据长度
+	OP_PUSHDATA2           = 0x4d // 后面 2 字节为数据长度
+	OP_PUSHDATA4           = 0x4e // 后面 4 字节为数据长度
+	OP_1NEGATE             = 0x4f // 推入 -1
+	OP_1                   = 0x51 // 推入 1（OP_1 到 OP_16 推入 1 到 16）
+	OP_16                  = 0x60 // 推入 16
+	OP_NOP                 = 0x61 // 不做任何操作
+	OP_IF                  = 0x63 // 栈顶为真时执行后面的分支
+	OP_NOTIF               = 0x64 // 栈顶为假时执行后面的分支
+	OP_ELSE                = 0x67 // 另一个分支
+	OP_ENDIF               = 0x68 // 分支结束
+	OP_VERIFY              = 0x69 // 栈顶为假时脚本失败
+	OP_RETURN              = 0x6a // 脚本直接失败
+	OP_DROP                = 0x75 // 弹出栈顶
+	OP_DUP                 = 0x76 // 复制栈顶
+	OP_EQUAL               = 0x87 // 比较栈顶两个元素是否相等
+	OP_EQUALVERIFY         = 0x88 // OP_EQUAL + OP_VERIFY
+	OP_HASH160             = 0xa9 // 栈顶替换为 ripemd160(sha256(栈顶))
+	OP_CHECKSIG            = 0xac // 验证签名
+	OP_CHECKSIGVERIFY      = 0xad // OP_CHECKSIG + OP_VERIFY
+	OP_CHECKMULTISIG       = 0xae // 验证多重签名
+	OP_CHECKMULTISIGVERIFY = 0xaf // OP_CHECKMULTISIG + OP_VERIFY
+)
+
+// opcodeNames 操作码名称（反汇编使用）
+var opcodeNames = map[byte]string{
+	OP_0:                   "OP_0",
+	OP_PUSHDATA1:           "OP_PUSHDATA1",
+	OP_PUSHDATA2:           "OP_PUSHDATA2",
+	OP_PUSHDATA4:           "OP_PUSHDATA4",
+	OP_1NEGATE:             "OP_1NEGATE",
+	OP_NOP:                 "OP_NOP",
+	OP_IF:                  "OP_IF",
+	OP_NOTIF:               "OP_NOTIF",
+	OP_ELSE:                "OP_ELSE",
+	OP_ENDIF:               "OP_ENDIF",
+	OP_VERIFY:              "OP_VERIFY",
+	OP_RETURN:              "OP_RETURN",
+	OP_DROP:                "OP_DROP",
+	OP_DUP:                 "OP_DUP",
+	OP_EQUAL:               "OP_EQUAL",
+	OP_EQUALVERIFY:         "OP_EQUALVERIFY",
+	OP_HASH160:             "OP_HASH160",
+	OP_CHECKSIG:            "OP_CHECKSIG",
+	OP_CHECKSIGVERIFY:      "OP_CHECKSIGVERIFY",
+	OP_CHECKMULTISIG:       "OP_CHECKMULTISIG",
+	OP_CHECKMULTISIGVERIFY: "OP_CHECKMULTISIGVERIFY",
+}
+
+// 脚本执行的限制
+const (
+	MaxScriptSize         = 10000 // 脚本的最大长度
+	MaxScriptElementSize  = 520   // 推入栈中的单个元素的最大长度
+	maxStackSize          = 1000  // 栈中元素的最大个数
+	maxOpsPerScript       = 201   // 每个脚本最多执行的非数据推入操作码个数
+	MaxPubKeysPerMultiSig = 20    // 多重签名最多包含的公钥个数
+)
+
+// ErrScriptFalse 脚本执行结束后栈顶不为真
+var ErrScriptFalse = errors.New("the script evaluated to false")
+
+// parsedOpcode 解析后的操作码，data 为数据推入操作码推入的数据
+type parsedOpcode struct {
+	opcode byte
+	data   []byte
+}
+
+// isPush 是否是数据推入操作码（包括 OP_1NEGATE、OP_1 到 OP_16）
+func (pop *parsedOpcode) isPush() bool {
+	return pop.opcode <= OP_16 && pop.opcode != 0x50
+}
+
+// parseScript 把脚本解析为操作码列表
+func parseScript(script []byte) ([]parsedOpcode, error) {
+	var pops []parsedOpcode
+	for i := 0; i < len(script); {
+		opcode := script[i]
+		i++
+		var length int
+		switch {
+		case opcode > OP_0 && opcode < OP_PUSHDATA1:
+			length = int(opcode)
+		case opcode == OP_PUSHDATA1:
+			if i+1 > len(script) {
+				return nil, fmt.Errorf("OP_PUSHDATA1 at %d has no length", i-1)
+			}
+			length = int(script[i])
+			i++
+		case opcode == OP_PUSHDATA2:
+			if i+2 > len(script) {
+				return nil, fmt.Errorf("OP_PUSHDATA2 at %d has no length", i-1)
+			}
+			length = int(binary.LittleEndian.Uint16(script[i:]))
+			i += 2
+		case opcode == OP_PUSHDATA4:
+			if i+4 > len(script) {
+				return nil, fmt.Errorf("OP_PUSHDATA4 at %d has no length", i-1)
+			}
+			length = int(binary.LittleEndian.Uint32(script[i:]))
+			i += 4
+		}
+		if length < 0 || i+length > len(script) {
+			return nil, fmt.Errorf("the push at %d needs %d bytes, only %d left", i, length, len(script)-i)
+		}
+		pop := parsedOpcode{opcode: opcode}
+		if opcode <= OP_PUSHDATA4 {
+			pop.data = script[i : i+length]
+		}
+		i += length
+		pops = append(pops, pop)
+	}
+	return pops, nil
+}
+
+// IsPushOnlyScript 脚本是否只包含数据推入操作码
+func IsPushOnlyScript(script []byte) bool {
+	pops, err := parseScript(script)
+	if nil != err {
+		return false
+	}
+	for _, pop := range pops {
+		if !pop.isPush() {
+			return false
+		}
+	}
+	return true
+}
+
+// PushedData 获取脚本中所有数据推入操作码推入的数据
+func PushedData(script []byte) ([][]byte, error) {
+	pops, err := parseScript(script)
+	if nil != err {
+		return nil, err
+	}
+	var data [][]byte
+	for _, pop := range pops {
+		if pop.opcode <= OP_PUSHDATA4 {
+			data = append(data, pop.data)
+		}
+	}
+	return data, nil
+}
+
+// DisasmScript 把脚本反汇编为可读的字符串
+func DisasmScript(script []byte) string {
+	pops, err := parseScript(script)
+	if nil != err {
+		return fmt.Sprintf("[error: %v] %x", err, script)
+	}
+	var buffer bytes.Buffer
+	for i, pop := range pops {
+		if i > 0 {
+			buffer.WriteByte(' ')
+		}
+		switch {
+		case pop.opcode == OP_0:
+			buffer.WriteString("OP_0")
+		case pop.opcode <= OP_PUSHDATA4:
+			fmt.Fprintf(&buffer, "%x", pop.data)
+		case pop.opcode >= OP_1 && pop.opcode <= OP_16:
+			fmt.Fprintf(&buffer, "OP_%d", pop.opcode-OP_1+1)
+		default:
+			if name, ok := opcodeNames[pop.opcode]; ok {
+				buffer.WriteString(name)
+			} else {
+				fmt.Fprintf(&buffer, "OP_UNKNOWN%d", pop.opcode)
+			}
+		}
+	}
+	return buffer.String()
+}
+
+// ScriptBuilder 脚本构造器
+type ScriptBuilder struct {
+	script []byte
+}
+
+// NewScriptBuilder 创建脚本构造器
+func NewScriptBuilder() *ScriptBuilder {
+	return &ScriptBuilder{}
+}
+
+// AddOp 添加操作码
+func (b *ScriptBuilder) AddOp(opcode byte) *ScriptBuilder {
+	b.script = append(b.script, opcode)
+	return b
+}
+
+// AddData 添加推入数据的操作码，根据数据长度选择最短的编码
+func (b *ScriptBuilder) AddData(data []byte) *ScriptBuilder {
+	length := len(data)
+	switch {
+	case length == 0:
+		b.script = append(b.script, OP_0)
+	case length < OP_PUSHDATA1:
+		b.script = append(b.script, byte(length))
+	case length <= 0xff:
+		b.script = append(b.script, OP_PUSHDATA1, byte(length))
+	case length <= 0xffff:
+		b.script = append(b.script, OP_PUSHDATA2, 0, 0)
+		binary.LittleEndian.PutUint16(b.script[len(b.script)-2:], uint16(length))
+	default:
+		b.script = append(b.script, OP_PUSHDATA4, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(b.script[len(b.script)-4:], uint32(length))
+	}
+	b.script = append(b.script, data...)
+	return b
+}
+
+// AddInt 添加推入整数的操作码，-1 到 16 使用对应的操作码
+func (b *ScriptBuilder) AddInt(n int) *ScriptBuilder {
+	switch {
+	case n == 0:
+		return b.AddOp(OP_0)
+	case n == -1:
+		return b.AddOp(OP_1NEGATE)
+	case n >= 1 && n <= 16:
+		return b.AddOp(byte(OP_1 + n - 1))
+	}
+	return b.AddData(scriptNum(n))
+}
+
+// Script 获取构造的脚本
+func (b *ScriptBuilder) Script() []byte {
+	return b.script
+}
+
+// scriptNum 把整数编码为栈中的数值：小端序，最高字节的最高位为符号位
+func scriptNum(n int) []byte {
+	if n == 0 {
+		return nil
+	}
+	negative := n < 0
+	abs := n
+	if negative {
+		abs = -n
+	}
+	var result []byte
+	for abs > 0 {
+		result = append(result, byte(abs&0xff))
+		abs >>= 8
+	}
+	if result[len(result)-1]&0x80 != 0 {
+		if negative {
+			result = append(result, 0x80)
+		} else {
+			result = append(result, 0x00)
+		}
+	} else if negative {
+		result[len(result)-1] |= 0x80
+	}
+	return result
+}
+
+// asInt 把栈中的元素解析为整数，最多 4 字节
+func asInt(data []byte) (int, error) {
+	if len(data) > 4 {
+		return 0, fmt.Errorf("the number %x is longer than 4 bytes", data)
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+	var n int64
+	for i, b := range data {
+		n |= int64(b) << uint(8*i)
+	}
+	// 最高字节的最高位为符号位
+	if data[len(data)-1]&0x80 != 0 {
+		n &= ^(int64(0x80) << uint(8*(len(data)-1)))
+		n = -n
+	}
+	return int(n), nil
+}
+
+// asBool 栈中元素的真假：全部为 0（包括负 0）为假，其他为真
+func asBool(data []byte) bool {
+	for i, b := range data {
+		if b != 0 {
+			// 负 0
+			if i == len(data)-1 && b == 0x80 {
+				return false
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// fromBool 真假值对应的栈中元素
+func fromBool(v bool) []byte {
+	if v {
+		return []byte{1}
+	}
+	return nil
+}
+
+// scriptEngine 脚本执行引擎
+type scriptEngine struct {
+	tx      *Transaction // 正在验证的交易
+	index   int          // 正在验证的输入索引
+	script  []byte       // 正在执行的脚本，用于计算签名哈希
+	stack   [][]byte     // 数据栈
+	conds   []bool       // 条件分支栈，记录每一层分支是否执行
+	opCount int          // 已经执行的非数据推入操作码个数
+}
+
+// VerifyScript 验证交易 tx 的第 index 个输入：先执行解锁脚本 scriptSig，再执行被花费输出的锁定脚本 scriptPubKey
+func VerifyScript(scriptSig []byte, scriptPubKey []byte, tx *Transaction, index int) error {
+	// 解锁脚本只能推入数据，防止解锁脚本改变锁定脚本的执行逻辑
+	if !IsPushOnlyScript(scriptSig) {
+		return errors.New("the signature script is not push only")
+	}
+	engine := &scriptEngine{tx: tx, index: index}
+	if err := engine.execute(scriptSig); nil != err {
+		return err
+	}
+	// pay-to-script-hash 需要在解锁脚本执行后的栈上再执行赎回脚本
+	sigStack := append([][]byte{}, engine.stack...)
+	if err := engine.execute(scriptPubKey); nil != err {
+		return err
+	}
+	if len(engine.stack) == 0 || !asBool(engine.stack[len(engine.stack)-1]) {
+		return ErrScriptFalse
+	}
+	if nil == ExtractScriptHash(scriptPubKey) {
+		return nil
+	}
+	// 锁定脚本已经验证了赎回脚本的哈希，栈顶的赎回脚本出栈后执行
+	redeemScript := sigStack[len(sigStack)-1]
+	engine.stack = sigStack[:len(sigStack)-1]
+	if err := engine.execute(redeemScript); nil != err {
+		return fmt.Errorf("the redeem script failed: %v", err)
+	}
+	if len(engine.stack) == 0 || !asBool(engine.stack[len(engine.stack)-1]) {
+		return ErrScriptFalse
+	}
+	return nil
+}
+
+// execute 执行脚本
+func (e *scriptEngine) execute(script []byte) error {
+	if len(script) > MaxScriptSize {
+		return fmt.Errorf("the script size %d is larger than %d", len(script), MaxScriptSize)
+	}
+	pops, err := parseScript(script)
+	if nil != err {
+		return err
+	}
+	e.script = script
+	e.opCount = 0
+	e.conds = nil
+	for _, pop := range pops {
+		if err := e.step(&pop); nil != err {
+			return fmt.Errorf("%s failed: %v", DisasmScript([]byte{pop.opcode}), err)
+		}
+		if len(e.stack) > maxStackSize {
+			return fmt.Errorf("the stack size is larger than %d", maxStackSize)
+		}
+	}
+	if len(e.conds) != 0 {
+		return errors.New("the script has an unbalanced conditional")
+	}
+	return nil
+}
+
+// executing 当前分支是否需要执行
+func (e *scriptEngine) executing() bool {
+	for _, cond := range e.conds {
+		if !cond {
+			return false
+		}
+	}
+	return true
+}
+
+// step 执行一个操作码
+func (e *scriptEngine) step(pop *parsedOpcode) error {
+	if len(pop.data) > MaxScriptElementSize {
+		return fmt.Errorf("the pushed data is larger than %d bytes", MaxScriptElementSize)
+	}
+	if !pop.isPush() {
+		e.opCount++
+		if e.opCount > maxOpsPerScript {
+			return fmt.Errorf("more than %d operations", maxOpsPerScript)
+		}
+	}
+	// 条件分支操作码在不执行的分支中也需要处理
+	switch pop.opcode {
+	case OP_IF, OP_NOTIF:
+		cond := false
+		if e.executing() {
+			data, err := e.pop()
+			if nil != err {
+				return err
+			}
+			cond = asBool(data)
+			if pop.opcode == OP_NOTIF {
+				cond = !cond
+			}
+		}
+		e.conds = append(e.conds, cond)
+		return nil
+	case OP_ELSE:
+		if len(e.conds) == 0 {
+			return errors.New("no matching OP_IF")
+		}
+		e.conds[len(e.conds)-1] = !e.conds[len(e.conds)-1]
+		return nil
+	case OP_ENDIF:
+		if len(e.conds) == 0 {
+			return errors.New("no matching OP_IF")
+		}
+		e.conds = e.conds[:len(e.conds)-1]
+		return nil
+	}
+	if !e.executing() {
+		return nil
+	}
+	switch {
+	case pop.opcode <= OP_PUSHDATA4:
+		e.push(pop.data)
+		return nil
+	case pop.opcode == OP_1NEGATE:
+		e.push(scriptNum(-1))
+		return nil
+	case pop.opcode >= OP_1 && pop.opcode <= OP_16:
+		e.push(scriptNum(int(pop.opcode-OP_1) + 1))
+		return nil
+	}
+	switch pop.opcode {
+	case OP_NOP:
+		return nil
+	case OP_VERIFY:
+		return e.verify()
+	case OP_RETURN:
+		return errors.New("the script is unspendable")
+	case OP_DROP:
+		_, err := e.pop()
+		return err
+	case OP_DUP:
+		data, err := e.peek()
+		if nil != err {
+			return err
+		}
+		e.push(data)
+		return nil
+	case OP_EQUAL, OP_EQUALVERIFY:
+		a, err := e.pop()
+		if nil != err {
+			return err
+		}
+		b, err := e.pop()
+		if nil != err {
+			return err
+		}
+		e.push(fromBool(bytes.Equal(a, b)))
+		if pop.opcode == OP_EQUALVERIFY {
+			return e.verify()
+		}
+		return nil
+	case OP_HASH160:
+		data, err := e.pop()
+		if nil != err {
+			return err
+		}
+		e.push(Ripemd160Hash(data))
+		return nil
+	case OP_CHECKSIG, OP_CHECKSIGVERIFY:
+		pubKey, err := e.pop()
+		if nil != err {
+			return err
+		}
+		signature, err := e.pop()
+		if nil != err {
+			return err
+		}
+		e.push(fromBool(e.checkSignature(signature, pubKey)))
+		if pop.opcode == OP_CHECKSIGVERIFY {
+			return e.verify()
+		}
+		return nil
+	case OP_CHECKMULTISIG, OP_CHECKMULTISIGVERIFY:
+		ok, err := e.checkMultiSig()
+		if nil != err {
+			return err
+		}
+		e.push(fromBool(ok))
+		if pop.opcode == OP_CHECKMULTISIGVERIFY {
+			return e.verify()
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown opcode %02x", pop.opcode)
+}
+
+func (e *scriptEngine) push(data []byte) {
+	e.stack = append(e.stack, data)
+}
+
+func (e *scriptEngine) pop() ([]byte, error) {
+	data, err := e.peek()
+	if nil != err {
+		return nil, err
+	}
+	e.stack = e.stack[:len(e.stack)-1]
+	return data, nil
+}
+
+func (e *scriptEngine) peek() ([]byte, error) {
+	if len(e.stack) == 0 {
+		return nil, errors.New("the stack is empty")
+	}
+	return e.stack[len(e.stack)-1], nil
+}
+
+func (e *scriptEngine) popInt() (int, error) {
+	data, err := e.pop()
+	if nil != err {
+		return 0, err
+	}
+	return asInt(data)
+}
+
+// verify 弹出栈顶，栈顶为假时失败
+func (e *scriptEngine) verify() error {
+	data, err := e.pop()
+	if nil != err {
+		return err
+	}
+	if !asBool(data) {
+		return errors.New("the verification failed")
+	}
+	return nil
+}
+
+// checkSignature 使用公钥验证签名，签名哈希由交易与正在执行的脚本计算得出，
+// 第 4 版开始的交易要求签名与公钥使用严格编码
+func (e *scriptEngine) checkSignature(signature []byte, pubKey []byte) bool {
+	strict := e.tx.Version >= strictSigTxVersion
+	return verifySignature(pubKey, signature, e.tx.SignatureHash(e.index, e.script), strict)
+}
+
+// checkMultiSig 验证多重签名，栈中的数据为：<签名1> ... <签名m> <m> <公钥1> ... <公钥n> <n>
+// 签名的顺序必须与公钥的顺序一致（不需要比特币中多余的 OP_0）
+func (e *scriptEngine) checkMultiSig() (bool, error) {
+	n, err := e.popInt()
+	if nil != err {
+		return false, err
+	}
+	if n < 0 || n > MaxPubKeysPerMultiSig {
+		return false, fmt.Errorf("the pubkey count %d is out of range", n)
+	}
+	e.opCount += n
+	if e.opCount > maxOpsPerScript {
+		return false, fmt.Errorf("more than %d operations", maxOpsPerScript)
+	}
+	pubKeys := make([][]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		if pubKeys[i], err = e.pop(); nil != err {
+			return false, err
+		}
+	}
+	m, err := e.popInt()
+	if nil != err {
+		return false, err
+	}
+	if m < 0 || m > n {
+		return false, fmt.Errorf("the signature count %d is out of range", m)
+	}
+	signatures := make([][]byte, m)
+	for i := m - 1; i >= 0; i-- {
+		if signatures[i], err = e.pop(); nil != err {
+			return false, err
+		}
+	}
+	// 每个签名依次匹配后面的公钥
+	k := 0
+	for _, signature := range signatures {
+		for k < n && !e.checkSignature(signature, pubKeys[k]) {
+			k++
+		}
+		if k == n {
+			return false, nil
+		}
+		k++
+	}
+	return true, nil
+}
@@ -0,0 +1,182 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// 标准脚本管理文件
+
+// 普通转账使用的标准脚本（pay-to-pubkey-hash）：
+//   锁定脚本：OP_DUP OP_HASH160 <公钥哈希> OP_EQUALVERIFY OP_CHECKSIG
+//   解锁脚本：<签名> <公钥>
+
+// PayToPubKeyHashScript 生成支付给公钥哈希的锁定脚本
+func PayToPubKeyHashScript(pubKeyHash []byte) []byte {
+	return NewScriptBuilder().AddOp(OP_DUP).AddOp(OP_HASH160).AddData(pubKeyHash).
+		AddOp(OP_EQUALVERIFY).AddOp(OP_CHECKSIG).Script()
+}
+
+// PayToAddressScript 生成支付给地址的锁定脚本，脚本哈希地址使用 pay-to-script-hash 脚本
+func PayToAddressScript(address string) []byte {
+	if IsScriptHashAddress(address) {
+		return PayToScriptHashScript(StringToHash160(address))
+	}
+	return PayToPubKeyHashScript(StringToHash160(address))
+}
+
+// ExtractPubKeyHash 从 pay-to-pubkey-hash 锁定脚本中获取公钥哈希，不是标准脚本时返回 nil
+func ExtractPubKeyHash(script []byte) []byte {
+	if len(script) == 25 && script[0] == OP_DUP && script[1] == OP_HASH160 && script[2] == 20 &&
+		script[23] == OP_EQUALVERIFY && script[24] == OP_CHECKSIG {
+		return script[3:23]
+	}
+	return nil
+}
+
+// SignatureScript 生成 pay-to-pubkey-hash 的解锁脚本
+func SignatureScript(signature []byte, pubKey []byte) []byte {
+	return NewScriptBuilder().AddData(signature).AddData(pubKey).Script()
+}
+
+// ExtractSignatureScriptPubKey 从 pay-to-pubkey-hash 解锁脚本中获取公钥，不是标准脚本时返回 nil
+func ExtractSignatureScriptPubKey(scriptSig []byte) []byte {
+	if !IsPushOnlyScript(scriptSig) {
+		return nil
+	}
+	data, err := PushedData(scriptSig)
+	if nil != err || len(data) != 2 {
+		return nil
+	}
+	return data[1]
+}
+
+// 数据输出的锁定脚本：OP_RETURN <数据>，执行到 OP_RETURN 时失败，输出永远不能被花费，也不会进入 UTXO 集合
+
+// NullDataScript 生成数据输出的锁定脚本，数据不能超过 MaxDataCarrierSize 字节
+func NullDataScript(data []byte) ([]byte, error) {
+	if len(data) > ActiveParams.MaxDataCarrierSize {
+		return nil, fmt.Errorf("the data size %d is larger than %d", len(data), ActiveParams.MaxDataCarrierSize)
+	}
+	return NewScriptBuilder().AddOp(OP_RETURN).AddData(data).Script(), nil
+}
+
+// ExtractNullData 从数据输出的锁定脚本中获取数据，不是数据输出时返回 false
+func ExtractNullData(script []byte) ([]byte, bool) {
+	if len(script) == 0 || script[0] != OP_RETURN {
+		return nil, false
+	}
+	if len(script) == 1 {
+		return nil, true
+	}
+	data, err := PushedData(script[1:])
+	if nil != err || len(data) != 1 || !IsPushOnlyScript(script[1:]) {
+		return nil, false
+	}
+	return data[0], true
+}
+
+// IsUnspendable 判断锁定脚本是否永远不能被花费：以 OP_RETURN 开头或者超过脚本长度上限
+func IsUnspendable(script []byte) bool {
+	return len(script) > 0 && script[0] == OP_RETURN || len(script) > MaxScriptSize
+}
+
+// 多重签名使用 pay-to-script-hash 脚本，赎回脚本的哈希决定地址：
+//   赎回脚本：<m> <公钥1> ... <公钥n> <n> OP_CHECKMULTISIG
+//   锁定脚本：OP_HASH160 <赎回脚本哈希> OP_EQUAL
+//   解锁脚本：<签名1> ... <签名m> <赎回脚本>
+// 验证时先确认赎回脚本的哈希与锁定脚本一致，再用剩余的签名执行赎回脚本
+
+// PayToScriptHashScript 生成支付给脚本哈希的锁定脚本
+func PayToScriptHashScript(scriptHash []byte) []byte {
+	return NewScriptBuilder().AddOp(OP_HASH160).AddData(scriptHash).AddOp(OP_EQUAL).Script()
+}
+
+// ExtractScriptHash 从 pay-to-script-hash 锁定脚本中获取脚本哈希，不是标准脚本时返回 nil
+func ExtractScriptHash(script []byte) []byte {
+	if len(script) == 23 && script[0] == OP_HASH160 && script[1] == 20 && script[22] == OP_EQUAL {
+		return script[2:22]
+	}
+	return nil
+}
+
+// MultiSigScript 生成 m-of-n 多重签名的赎回脚本
+func MultiSigScript(m int, pubKeys [][]byte) ([]byte, error) {
+	n := len(pubKeys)
+	if n == 0 || n > MaxPubKeysPerMultiSig {
+		return nil, fmt.Errorf("the pubkey count %d is out of range [1, %d]", n, MaxPubKeysPerMultiSig)
+	}
+	if m < 1 || m > n {
+		return nil, fmt.Errorf("the signature count %d is out of range [1, %d]", m, n)
+	}
+	builder := NewScriptBuilder().AddInt(m)
+	for i, pubKey := range pubKeys {
+		if !isValidPubKey(pubKey) {
+			return nil, fmt.Errorf("the pubkey %d [%x] is invalid", i, pubKey)
+		}
+		for _, other := range pubKeys[:i] {
+			if bytes.Equal(pubKey, other) {
+				return nil, fmt.Errorf("the pubkey [%x] is duplicated", pubKey)
+			}
+		}
+		builder.AddData(pubKey)
+	}
+	script := builder.AddInt(n).AddOp(OP_CHECKMULTISIG).Script()
+	// 赎回脚本会被解锁脚本作为数据推入栈中
+	if len(script) > MaxScriptElementSize {
+		return nil, fmt.Errorf("the redeem script size %d is larger than %d", len(script), MaxScriptElementSize)
+	}
+	return script, nil
+}
+
+// ExtractMultiSig 从多重签名赎回脚本中获取签名数量与公钥列表，不是标准脚本时返回 false
+func ExtractMultiSig(script []byte) (int, [][]byte, bool) {
+	pops, err := parseScript(script)
+	if nil != err || len(pops) < 4 || pops[len(pops)-1].opcode != OP_CHECKMULTISIG {
+		return 0, nil, false
+	}
+	m, okM := smallInt(pops[0].opcode)
+	n, okN := smallInt(pops[len(pops)-2].opcode)
+	if !okM || !okN || n != len(pops)-3 || m < 1 || m > n {
+		return 0, nil, false
+	}
+	var pubKeys [][]byte
+	for _, pop := range pops[1 : len(pops)-2] {
+		if !pop.isPush() || len(pop.data) == 0 {
+			return 0, nil, false
+		}
+		pubKeys = append(pubKeys, pop.data)
+	}
+	return m, pubKeys, true
+}
+
+// smallInt OP_1 到 OP_16 对应的数值
+func smallInt(opcode byte) (int, bool) {
+	if opcode < OP_1 || opcode > OP_16 {
+		return 0, false
+	}
+	return int(opcode-OP_1) + 1, true
+}
+
+// ScriptHashAddress 通过赎回脚本生成地址：脚本哈希地址的版本前缀 + 脚本哈希 + 校验和
+func ScriptHashAddress(script []byte) string {
+	return encodeAddress(ActiveParams.ScriptHashAddrID, Ripemd160Hash(script))
+}
+
+// IsScriptHashAddress 判断地址是否为当前网络的脚本哈希地址
+func IsScriptHashAddress(address string) bool {
+	version, _, err := DecodeAddress(address)
+	return nil == err && version == ActiveParams.ScriptHashAddrID
+}
+
+// lastPushedData 解锁脚本最后推入的数据：pay-to-pubkey-hash 中为公钥，pay-to-script-hash 中为赎回脚本
+func lastPushedData(scriptSig []byte) []byte {
+	if !IsPushOnlyScript(scriptSig) {
+		return nil
+	}
+	data, err := PushedData(scriptSig)
+	if nil != err || len(data) == 0 {
+		return nil
+	}
+	return data[len(data)-1]
+}
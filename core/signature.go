@@ -0,0 +1,174 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"math/big"
+)
+
+// 签名与公钥编码管理文件
+
+// 签名固定为 64 字节：r、s 各占 32 字节（大端，不足时在前面补 0），s 必须不大于 N/2（低 s 值），
+// 否则任何人都可以把 s 替换为 N - s 得到另一个有效签名，从而改变交易哈希
+// 公钥为 33 字节的压缩公钥：0x02（y 为偶数）或者 0x03（y 为奇数）+ 32 字节 x 坐标，
+// 旧版本钱包的公钥为 64 字节的 x + y（各占 32 字节），更早的钱包省略了坐标开头的 0（长度小于 64 字节），
+// 两种旧公钥都仍然可以花费发送到旧地址的输出
+// 第 4 版交易（strictSigTxVersion）与第 3 版区块（strictSealBlockVersion）开始强制使用严格编码，
+// 之前的版本按照原来的方式（从中间拆分）解析，保证已有的区块仍然有效
+
+// 签名与公钥的长度
+const (
+	signatureLen        = 64
+	compressedPubKeyLen = 33
+	legacyPubKeyLen     = 64
+)
+
+// errNonCanonicalSignature 签名不是严格编码
+var errNonCanonicalSignature = errors.New("the signature is not canonical")
+
+// signHash 使用私钥为哈希签名，返回严格编码的签名
+func signHash(privateKey *ecdsa.PrivateKey, hash []byte) ([]byte, error) {
+	r, s, err := ecdsa.Sign(rand.Reader, privateKey, hash)
+	if nil != err {
+		return nil, err
+	}
+	// 低 s 值
+	n := privateKey.Curve.Params().N
+	if s.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		s.Sub(n, s)
+	}
+	signature := make([]byte, signatureLen)
+	r.FillBytes(signature[:signatureLen/2])
+	s.FillBytes(signature[signatureLen/2:])
+	return signature, nil
+}
+
+// parseSignature 解析签名，strict 为 true 时只接受严格编码
+func parseSignature(signature []byte, strict bool) (*big.Int, *big.Int, error) {
+	if !strict {
+		// 旧版本的签名 r + s 从中间拆分
+		if len(signature) == 0 {
+			return nil, nil, errNonCanonicalSignature
+		}
+		half := len(signature) / 2
+		return new(big.Int).SetBytes(signature[:half]), new(big.Int).SetBytes(signature[half:]), nil
+	}
+	if len(signature) != signatureLen {
+		return nil, nil, errNonCanonicalSignature
+	}
+	r := new(big.Int).SetBytes(signature[:signatureLen/2])
+	s := new(big.Int).SetBytes(signature[signatureLen/2:])
+	n := elliptic.P256().Params().N
+	if r.Sign() <= 0 || r.Cmp(n) >= 0 || s.Sign() <= 0 || s.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		return nil, nil, errNonCanonicalSignature
+	}
+	return r, s, nil
+}
+
+// IsCanonicalSignature 判断签名是否为严格编码（64 字节 r + s，低 s 值）
+func IsCanonicalSignature(signature []byte) bool {
+	_, _, err := parseSignature(signature, true)
+	return nil == err
+}
+
+// verifySignature 验证 ECDSA 签名，strict 为 true 时签名与公钥必须是严格编码
+func verifySignature(pubKey []byte, signature []byte, hash []byte, strict bool) bool {
+	r, s, err := parseSignature(signature, strict)
+	if nil != err {
+		return false
+	}
+	rawPublicKey, ok := parsePubKey(pubKey, strict)
+	if !ok {
+		return false
+	}
+	return ecdsa.Verify(rawPublicKey, hash, r, s)
+}
+
+// parsePubKey 解析公钥，坐标必须在椭圆曲线上，strict 为 true 时只接受压缩公钥与旧公钥
+func parsePubKey(pubKey []byte, strict bool) (*ecdsa.PublicKey, bool) {
+	curve := elliptic.P256()
+	if strict && len(pubKey) == compressedPubKeyLen {
+		// 前缀必须是 0x02 或者 0x03，x 坐标必须小于 p 并且在曲线上
+		x, y := elliptic.UnmarshalCompressed(curve, pubKey)
+		if nil == x {
+			return nil, false
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, true
+	}
+	if strict && len(pubKey) > compressedPubKeyLen && len(pubKey) < legacyPubKeyLen {
+		return parseUnpaddedLegacyPubKey(pubKey)
+	}
+	if strict && len(pubKey) != legacyPubKeyLen {
+		return nil, false
+	}
+	if len(pubKey) == 0 {
+		return nil, false
+	}
+	// 旧公钥 x + y 从中间拆分
+	half := len(pubKey) / 2
+	x, y := new(big.Int).SetBytes(pubKey[:half]), new(big.Int).SetBytes(pubKey[half:])
+	if !curve.IsOnCurve(x, y) {
+		return nil, false
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, true
+}
+
+// parseUnpaddedLegacyPubKey 解析省略了坐标开头 0 的旧公钥，两个坐标都不能以 0 开头，
+// 按照 x 坐标从长到短的顺序拆分，使用第一个在椭圆曲线上的拆分
+func parseUnpaddedLegacyPubKey(pubKey []byte) (*ecdsa.PublicKey, bool) {
+	curve := elliptic.P256()
+	for xLen := legacyPubKeyLen / 2; xLen >= len(pubKey)-legacyPubKeyLen/2; xLen-- {
+		xBytes, yBytes := pubKey[:xLen], pubKey[xLen:]
+		if xBytes[0] == 0 || yBytes[0] == 0 {
+			continue
+		}
+		x, y := new(big.Int).SetBytes(xBytes), new(big.Int).SetBytes(yBytes)
+		if curve.IsOnCurve(x, y) {
+			return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, true
+		}
+	}
+	return nil, false
+}
+
+// isValidPubKey 判断公钥是否有效（严格编码）
+func isValidPubKey(pubKey []byte) bool {
+	_, ok := parsePubKey(pubKey, true)
+	return ok
+}
+
+// publicKeyBytes 公钥的字节表示：33 字节压缩公钥
+func publicKeyBytes(publicKey *ecdsa.PublicKey) []byte {
+	return elliptic.MarshalCompressed(publicKey.Curve, publicKey.X, publicKey.Y)
+}
+
+// legacyPublicKeyBytes 旧版本钱包的公钥字节表示：x 坐标 + y 坐标，各占 32 字节
+func legacyPublicKeyBytes(publicKey *ecdsa.PublicKey) []byte {
+	pubKey := make([]byte, legacyPubKeyLen)
+	publicKey.X.FillBytes(pubKey[:legacyPubKeyLen/2])
+	publicKey.Y.FillBytes(pubKey[legacyPubKeyLen/2:])
+	return pubKey
+}
+
+// unpaddedLegacyPublicKeyBytes 更早版本钱包的公钥字节表示：x 坐标 + y 坐标，省略开头的 0
+func unpaddedLegacyPublicKeyBytes(publicKey *ecdsa.PublicKey) []byte {
+	return append(publicKey.X.Bytes(), publicKey.Y.Bytes()...)
+}
+
+// legacyPublicKeys 公钥所有的旧编码
+func legacyPublicKeys(publicKey *ecdsa.PublicKey) [][]byte {
+	return [][]byte{legacyPublicKeyBytes(publicKey), unpaddedLegacyPublicKeyBytes(publicKey)}
+}
+
+// pubKeyForScript 锁定脚本承诺的公钥编码：公钥哈希与某个旧公钥一致时使用该旧公钥，否则使用压缩公钥
+func pubKeyForScript(publicKey *ecdsa.PublicKey, scriptPubKey []byte) []byte {
+	pubKeyHash := ExtractPubKeyHash(scriptPubKey)
+	for _, legacy := range legacyPublicKeys(publicKey) {
+		if bytes.Equal(pubKeyHash, Ripemd160Hash(legacy)) {
+			return legacy
+		}
+	}
+	return publicKeyBytes(publicKey)
+}
@@ -0,0 +1,31 @@
+package core
+
+// 区块奖励管理文件
+
+// BlockSubsidy 计算指定高度区块的奖励（不包含手续费）
+// 每隔 SubsidyHalvingInterval 个区块奖励减半，累计发行量不能超过 MaxSupply
+func BlockSubsidy(height int64) int {
+	params := ActiveParams
+	if height < 1 {
+		return 0
+	}
+	// 创世区块的高度为 1
+	era := (height - 1) / params.SubsidyHalvingInterval
+	if era >= 63 {
+		return 0
+	}
+	subsidy := params.InitialSubsidy >> uint(era)
+	// 之前的区块已经发行的数量
+	issued := 0
+	for e := int64(0); e < era; e++ {
+		issued += (params.InitialSubsidy >> uint(e)) * int(params.SubsidyHalvingInterval)
+	}
+	issued += subsidy * int((height-1)%params.SubsidyHalvingInterval)
+	if issued >= params.MaxSupply {
+		return 0
+	}
+	if subsidy > params.MaxSupply-issued {
+		subsidy = params.MaxSupply - issued
+	}
+	return subsidy
+}
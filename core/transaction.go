@@ -1,46 +1,45 @@
 package core
 
 import (
-	"bkc/utils"
-	"bytes"
 	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
 	"crypto/sha256"
-	"encoding/gob"
+	"encoding/binary"
 	"encoding/hex"
+	"fmt"
 	"log"
-	"math/big"
-	"time"
 )
 
 // 交易管理文件
 
-// 定义奖励数量
-const subsidy = 10
-
 // Transaction 定义一个交易基本结构
 type Transaction struct {
+	Version		int32      // 交易版本号
 	TxHash		[]byte     // 交易哈希标识
 	Vins		[]*TxInput   // 输入列表
 	Vouts		[]*TxOutput // 输出列表
+	LockTime	uint32     // 锁定时间：小于 LockTimeThreshold 时为区块高度，否则为时间戳，0 表示不锁定
 }
 
 // NewCoinbaseTransaction 实现 coinbase 交易
-func NewCoinbaseTransaction(address string) *Transaction {
+// coinbase 的输入中写入区块高度与额外随机数，保证不同区块中的 coinbase 交易哈希不同
+// @height：coinbase 所在区块的高度
+// @extraNonce：额外随机数，挖矿时可以通过修改它得到不同的 Merkle 根
+// @fees：区块中所有交易的手续费总额，与区块奖励一起支付给矿工
+func NewCoinbaseTransaction(address string, height int64, extraNonce int64, fees int) *Transaction {
 	// 输入，coinbase 特点：
-	// txHash: nil, vout: -1, ScriptSig: 系统奖励
+	// txHash: nil, vout: -1, ScriptSig: 区块高度 + 额外随机数（不会被执行）
 	txInput := &TxInput{
 		TxHash: []byte{},
 		Vout: -1,
-		Signature: nil,
-		PublicKey: nil,
+		ScriptSig: coinbaseScript(height, extraNonce),
+		Sequence: MaxSequence,
 	}
-	// 输出：value，address
-	txOutput := NewTxOutput(subsidy, address)
+	// 输出：区块奖励 + 手续费，address
+	txOutput := NewTxOutput(BlockSubsidy(height) + fees, address)
 
 	// 输入输出组装交易
 	txCoinbase := &Transaction{
+		Version: TxVersion,
 		TxHash: nil,
 		Vins: []*TxInput{txInput},
 		Vouts: []*TxOutput{txOutput},
@@ -50,16 +49,57 @@ func NewCoinbaseTransaction(address string) *Transaction {
 	return txCoinbase
 }
 
+// coinbaseScript 生成 coinbase 输入中的数据：区块高度 + 额外随机数（小端序）
+func coinbaseScript(height int64, extraNonce int64) []byte {
+	script := make([]byte, 16)
+	binary.LittleEndian.PutUint64(script[:8], uint64(height))
+	binary.LittleEndian.PutUint64(script[8:], uint64(extraNonce))
+	return script
+}
+
+// CoinbaseHeight 获取 coinbase 交易中写入的区块高度
+func (tx *Transaction) CoinbaseHeight() (int64, bool) {
+	if !tx.IsCoinbaseTransaction() || len(tx.Vins[0].ScriptSig) < 8 {
+		return 0, false
+	}
+	return int64(binary.LittleEndian.Uint64(tx.Vins[0].ScriptSig[:8])), true
+}
+
+// coinbaseExtraNonce 获取 coinbase 交易中写入的额外随机数
+func (tx *Transaction) coinbaseExtraNonce() int64 {
+	if !tx.IsCoinbaseTransaction() || len(tx.Vins[0].ScriptSig) < 16 {
+		return 0
+	}
+	return int64(binary.LittleEndian.Uint64(tx.Vins[0].ScriptSig[8:16]))
+}
+
 // NewSimpleTransaction 生成普通转账交易
-func NewSimpleTransaction(from string, to string, amount int, bc *BlockChain,
+// @fee：手续费，输入金额 - 转账金额 - 手续费作为找零返回给 from
+func NewSimpleTransaction(from string, to string, amount int, fee int, bc *BlockChain,
+	txs []*Transaction, nodeId string) *Transaction {
+	return NewTransferTransaction(from, to, amount, fee, 0, nil, bc, txs, nodeId)
+}
+
+// NewTransferTransaction 生成普通转账交易
+// @lockTime：交易在锁定时间（区块高度或者时间戳）之后才能被打包，0 表示不锁定
+// @data：不为空时在转账输出之后添加一个携带数据的输出
+func NewTransferTransaction(from string, to string, amount int, fee int, lockTime uint32, data []byte, bc *BlockChain,
 	txs []*Transaction, nodeId string) *Transaction {
 	var txInputs []*TxInput   // 输入列表
 	var txOutputs []*TxOutput // 输出列表
 	// 调用可花费 UTXO 函数
-	money, spendableUTXODic := bc.FindSpendableUTXO(from, amount, txs)
+	money, spendableUTXODic := bc.FindSpendableUTXO(from, amount + fee, txs)
 	// 获取钱包集合对象
 	wallets := NewWallets(nodeId)
-	wallet := wallets.Wallets[from]
+	wallet := wallets.WalletForAddress(from)
+	if nil == wallet {
+		log.Panicf("the wallet of [%s] is not found\n", from)
+	}
+	// 所有输入的序列号都是 MaxSequence 时锁定时间不生效
+	sequence := uint32(MaxSequence)
+	if lockTime != 0 {
+		sequence = MaxSequence - 1
+	}
 	// 输入
 	for txHash, indexArray := range spendableUTXODic {
 		txHashBytes, err := hex.DecodeString(txHash)
@@ -68,48 +108,47 @@ func NewSimpleTransaction(from string, to string, amount int, bc *BlockChain,
 		}
 		// 遍历索引列表
 		for _, index := range indexArray {
-			txInput := &TxInput{txHashBytes, index, nil, wallet.PublicKey}
+			txInput := &TxInput{txHashBytes, index, nil, sequence}
 			txInputs = append(txInputs, txInput)
 		}
 	}
 	// 输出（转账源）
 	txOutput := NewTxOutput(amount, to)
 	txOutputs = append(txOutputs, txOutput)
-	// 找零
-	if money > amount {
- 		txOutput = NewTxOutput(money - amount, from)
-		txOutputs = append(txOutputs, txOutput)
-	} else {
+	// 数据输出
+	if len(data) > 0 {
+		dataOutput, err := NewDataTxOutput(data)
+		if nil != err {
+			log.Panicf("create the data output failed! %v\n", err)
+		}
+		txOutputs = append(txOutputs, dataOutput)
+	}
+	// 找零（没有输出的部分就是手续费）
+	if money < amount + fee {
 		log.Panicf("余额不足...\n")
 	}
+	if money > amount + fee {
+ 		txOutput = NewTxOutput(money - amount - fee, from)
+		txOutputs = append(txOutputs, txOutput)
+	}
 
 	tx := Transaction{
+		Version: TxVersion,
 		TxHash: nil,
 		Vins: txInputs,
 		Vouts: txOutputs,
+		LockTime: lockTime,
 	}
-	// 生成一笔完整的交易
-	tx.HashTransaction()
 	// 对交易进行签名
-	bc.SignTransaction(&tx, wallet.PrivateKey)
+	bc.SignTransaction(&tx, wallet.PrivateKey, txs)
+	// 交易哈希包含签名，必须在签名之后生成
+	tx.HashTransaction()
 	return &tx
 }
 
-// HashTransaction 生成交易哈希（交易序列化），不同时间生成的交易哈希值不同
+// HashTransaction 生成交易哈希，交易哈希只由交易内容决定，任何节点都可以重新计算
 func (tx *Transaction) HashTransaction() {
-	var result bytes.Buffer
-	// 设置编码对象
-	encoder := gob.NewEncoder(&result)
-	if err := encoder.Encode(tx); err != nil {
-		log.Panicf("tx Hash encoded failed %v\n", err)
-	}
-	// 添加时间戳标识，不添加会导致所有的 coinbase 交易哈希完全相同
-	tm := time.Now().UnixNano()
-	// 用于生成哈希的原数据
-	txHashBytes := bytes.Join([][]byte{result.Bytes(), utils.IntToHex(tm)}, []byte{})
-	// 生成哈希值
-	hash := sha256.Sum256(txHashBytes)
-	tx.TxHash = hash[:]
+	tx.TxHash = tx.Hash()
 }
 
 // IsCoinbaseTransaction 判断指定的交易是否时一个 coinbase 交易
@@ -117,7 +156,7 @@ func (tx *Transaction) IsCoinbaseTransaction() bool {
 	return -1 == tx.Vins[0].Vout && 0 == len(tx.Vins[0].TxHash)
 }
 
-// Sign 交易签名
+// Sign 交易签名，只能为 pay-to-pubkey-hash 输出签名，解锁脚本为 <签名> <公钥>
 // prevTxs：代表当前交易的输入所引用的所有 OUTPUT 所属的交易
 func (tx *Transaction) Sign(privateKey ecdsa.PrivateKey, prevTxs map[string]Transaction) {
 	// 处理输入，保证交易的正确性
@@ -128,28 +167,44 @@ func (tx *Transaction) Sign(privateKey ecdsa.PrivateKey, prevTxs map[string]Tran
 			log.Panicf("ERROR: Prev transaction is no correct!\n")
 		}
 	}
-	// 提取需要签名的属性
-	txCopy := tx.TrimmedCopy()
-	// 处理交易副本的输入
-	for vin_id, vin := range txCopy.Vins {
+	for vin_id, vin := range tx.Vins {
 		// 获取关联交易
 		prevTx := prevTxs[hex.EncodeToString(vin.TxHash)]
-		// 找到发送者（当前输入引用的哈希——输出的哈希）
-		txCopy.Vins[vin_id].PublicKey = prevTx.Vouts[vin.Vout].Ripemd160Hash
-		// 生成交易副本的哈希
-		txCopy.TxHash = txCopy.Hash()
-		// 调用核心签名函数
-		r, s, err := ecdsa.Sign(rand.Reader, &privateKey, txCopy.TxHash)
-		if nil != err {
-			log.Panicf("sign to transaction [%x] failed！ %v\n", txCopy, err)
-		}
-		// 组成交易签名
-		signature := append(r.Bytes(), s.Bytes()...)
-		tx.Vins[vin_id].Signature = signature
+		scriptPubKey := prevTx.Vouts[vin.Vout].ScriptPubKey
+		signature := tx.signInput(vin_id, &privateKey, scriptPubKey)
+		// 旧版本钱包的输出需要使用旧公钥解锁
+		tx.Vins[vin_id].ScriptSig = SignatureScript(signature, pubKeyForScript(&privateKey.PublicKey, scriptPubKey))
+	}
+}
+
+// signInput 为第 index 个输入生成签名 r + s（严格编码）
+// 签名哈希由交易副本与 subScript（被花费输出的锁定脚本或者赎回脚本）计算得出（不是交易哈希）
+func (tx *Transaction) signInput(index int, privateKey *ecdsa.PrivateKey, subScript []byte) []byte {
+	sigHash := tx.SignatureHash(index, subScript)
+	// 调用核心签名函数
+	signature, err := signHash(privateKey, sigHash)
+	if nil != err {
+		log.Panicf("sign to transaction [%x] failed！ %v\n", sigHash, err)
+	}
+	return signature
+}
+
+// SignatureHash 计算第 index 个输入的签名哈希
+// 交易副本中所有输入的解锁脚本清空，第 index 个输入的解锁脚本替换为 subScript（被花费输出的锁定脚本）
+// 第 5 版开始签名哈希包含网络魔数，为一个网络签名的交易在其他网络上无效；
+// 之前版本的交易（包括已经上链以及保存在交易池中的交易）仍然使用不包含魔数的签名哈希
+func (tx *Transaction) SignatureHash(index int, subScript []byte) []byte {
+	txCopy := tx.TrimmedCopy()
+	txCopy.Vins[index].ScriptSig = subScript
+	data := txCopy.Serialize()
+	if tx.Version >= replayProtectedTxVersion {
+		data = append(ActiveParams.Magic[:], data...)
 	}
+	hash := sha256.Sum256(data)
+	return hash[:]
 }
 
-// TrimmedCopy 交易拷贝，生成一个专门用于交易签名的副本
+// TrimmedCopy 交易拷贝，生成一个专门用于交易签名的副本（不包含解锁脚本）
 func (tx *Transaction) TrimmedCopy() Transaction {
 	// 重新组装生成一个新的交易
 	var inputs []*TxInput
@@ -160,75 +215,59 @@ func (tx *Transaction) TrimmedCopy() Transaction {
 			vin.TxHash,
 			vin.Vout,
 			nil,
-			nil,
+			vin.Sequence,
 		})
 	}
 	// 组装 output
 	for _, vout := range tx.Vouts {
 		outputs = append(outputs, &TxOutput{
 			vout.Value,
-			vout.Ripemd160Hash,
+			vout.ScriptPubKey,
 		})
 	}
-	txCopy := Transaction{tx.TxHash, inputs, outputs}
+	txCopy := Transaction{Version: tx.Version, TxHash: tx.TxHash, Vins: inputs, Vouts: outputs, LockTime: tx.LockTime}
 	return txCopy
 }
 
-// Serialize 交易序列化
+// Serialize 交易序列化（规范编码）
 func (tx *Transaction) Serialize() []byte {
-	var buffer bytes.Buffer
-	encoder := gob.NewEncoder(&buffer)
-	if err := encoder.Encode(tx); nil != err {
+	txBytes, err := EncodeTransaction(tx)
+	if nil != err {
 		log.Panicf("serialize the tx to []byte failed! %v\n", err)
 	}
-	return buffer.Bytes()
+	return txBytes
 }
 
-// Hash 设置用于签名的交易的哈希
+// Hash 计算交易内容的哈希，既用作交易哈希，也用于计算签名哈希
+// 哈希由交易的规范编码计算得出（交易哈希本身不包含在编码中）
 func (tx *Transaction) Hash() []byte {
-	txCopy := tx
-	txCopy.TxHash = []byte{}
 	hash := sha256.Sum256(tx.Serialize())
 	return hash[:]
 }
 
-// Verity 验证签名
+// Verity 验证签名：依次执行每个输入的解锁脚本与被花费输出的锁定脚本
 func (tx *Transaction) Verity(prevTxs map[string]Transaction) bool {
+	return nil == tx.VerifyScripts(prevTxs)
+}
+
+// VerifyScripts 执行每个输入的解锁脚本与被花费输出的锁定脚本，返回第一个验证失败的原因
+func (tx *Transaction) VerifyScripts(prevTxs map[string]Transaction) error {
 	// 检查能否找到交易哈希
 	for _, vin := range tx.Vins {
 		if prevTxs[hex.EncodeToString(vin.TxHash)].TxHash == nil {
 			log.Panicf("VERIFY ERROR : transaction verity failed!\n")
 		}
 	}
-	// 提取相同的交易签名属性
-	txCopy := tx.TrimmedCopy()
-	// 使用相同的椭圆
-	curve := elliptic.P256()
 	// 遍历 tx 输入，对每笔输入所引用的输出进行校验
 	for vinId, vin := range tx.Vins {
 		// 获取关联交易
 		prevTx := prevTxs[hex.EncodeToString(vin.TxHash)]
-		// 找到发送者（当前输入引用的哈希——输出的哈希）
-		txCopy.Vins[vinId].PublicKey = prevTx.Vouts[vin.Vout].Ripemd160Hash
-		// 由需要验证的数据生成的交易哈希，必须要与签名时的数据完全一致
-		txCopy.TxHash = txCopy.Hash()
-		// 在比特币中，签名是一个数值对，r、s 代表签名
-		// 获取 r、s，两者长度相等，要从输入的 signature 中获取
-		r, s := big.Int{}, big.Int{}
-		sigLen := len(vin.Signature)
-		r.SetBytes(vin.Signature[:(sigLen/2)])
-		s.SetBytes(vin.Signature[(sigLen/2):])
-		// 获取公钥，由 x，y 坐标组成
-		x, y := big.Int{}, big.Int{}
-		pubKeyLen := len(vin.PublicKey)
-		x.SetBytes(vin.PublicKey[:(pubKeyLen/2)])
-		y.SetBytes(vin.PublicKey[(pubKeyLen/2):])
-		rawPublicKey := ecdsa.PublicKey{curve, &x, &y}
-		// 调用验证签名核心函数
-		if !ecdsa.Verify(&rawPublicKey, txCopy.TxHash, &r, &s) {
-			return false
+		if vin.Vout < 0 || vin.Vout >= len(prevTx.Vouts) {
+			return fmt.Errorf("input %d spends a missing output %d", vinId, vin.Vout)
+		}
+		if err := VerifyScript(vin.ScriptSig, prevTx.Vouts[vin.Vout].ScriptPubKey, tx, vinId); nil != err {
+			return fmt.Errorf("input %d: %v", vinId, err)
 		}
-
 	}
-	return true
-}
\ No newline at end of file
+	return nil
+}
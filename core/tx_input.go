@@ -10,13 +10,18 @@ import (
 type TxInput struct {
 	TxHash		[]byte		// 交易哈希（不是指当前的交易哈希）
 	Vout		int			// 引用的上一笔交易的输出索引号
-	Signature	[]byte		// 数字签名
-	PublicKey	[]byte		// 公钥
+	ScriptSig	[]byte		// 解锁脚本（coinbase 中为区块高度 + 额外随机数）
+	Sequence	uint32		// 序列号，用于相对锁定时间，MaxSequence 表示不锁定
 }
 
-// UnLockRipemd160Hash 传递哈希160进行判断
+// UnLockRipemd160Hash 传递哈希160进行判断，只能判断标准的解锁脚本：
+// pay-to-pubkey-hash 比较公钥的哈希，pay-to-script-hash 比较赎回脚本的哈希
 func (in *TxInput) UnLockRipemd160Hash(ripemd160Hash []byte) bool {
+	pubKey := lastPushedData(in.ScriptSig)
+	if nil == pubKey {
+		return false
+	}
 	// 获取 input 的ripemd160
-	inputRipemd160Hash := Ripemd160Hash(in.PublicKey)
+	inputRipemd160Hash := Ripemd160Hash(pubKey)
 	return bytes.Compare(inputRipemd160Hash, ripemd160Hash) == 0
 }
\ No newline at end of file
@@ -1,65 +1,69 @@
 package core
 
 import (
-	"bkc/utils"
 	"bytes"
-	"encoding/gob"
-	"fmt"
 	"log"
 )
 
 // 存入所有输出的集合
 
+// TXOutputs 一笔交易中所有未花费输出的集合，UTXO 中记录了输出在交易中的索引
 type TXOutputs struct {
-	TXOutputs []*TxOutput
+	UTXOS []*UTXO
 }
 
 // TxOutput 交易的输出管理
 type TxOutput struct {
 	Value			int			// 金额
-	Ripemd160Hash 	[]byte		//用脚本语言意味着比特币可以也作为智能合约平台
+	ScriptPubKey 	[]byte		// 锁定脚本，用脚本语言意味着比特币可以也作为智能合约平台
 }
 
-// UnLockScriptPubkeyWithAddress output 身份验证
+// UnLockScriptPubkeyWithAddress output 身份验证，只能判断地址对应的标准锁定脚本
 func (out *TxOutput) UnLockScriptPubkeyWithAddress(address string) bool {
-	// 转换
-	hash160 := StringToHash160(address)
-	fmt.Printf("%x\n", hash160)
-	return bytes.Compare(hash160, out.Ripemd160Hash) == 0
+	return bytes.Compare(PayToAddressScript(address), out.ScriptPubKey) == 0
 }
 
-// StringToHash160 string 转 hash160
+// StringToHash160 string 转 hash160，去掉版本前缀与校验和，脚本哈希地址得到脚本哈希
+// 调用者需要事先检查地址，无效的地址会生成无法花费的输出，因此直接 panic
 func StringToHash160(address string) []byte {
-	pubKeyHash := utils.Base58Decode([]byte(address))
-	hash160 := pubKeyHash[:len(pubKeyHash) - addressCheckSumLen]
-	return hash160[:]
+	_, hash160, err := DecodeAddress(address)
+	if nil != err {
+		log.Panicf("%v\n", err)
+	}
+	return hash160
 }
 
-// NewTxOutput 新建 output 对象
+// NewTxOutput 新建 output 对象，使用标准的 pay-to-pubkey-hash 锁定脚本
 func NewTxOutput(value int, address string) *TxOutput {
 	txOutput := &TxOutput{}
-	hash160 := StringToHash160(address)
 	txOutput.Value = value
-	txOutput.Ripemd160Hash = hash160
+	txOutput.ScriptPubKey = PayToAddressScript(address)
 	return txOutput
 }
 
-// Serialize 输出集合序列化
+// NewDataTxOutput 新建携带数据的输出，金额为 0，不能被花费
+func NewDataTxOutput(data []byte) (*TxOutput, error) {
+	script, err := NullDataScript(data)
+	if nil != err {
+		return nil, err
+	}
+	return &TxOutput{Value: 0, ScriptPubKey: script}, nil
+}
+
+// Serialize 输出集合序列化（规范编码）
 func (txOutputs *TXOutputs) Serialize() []byte {
-	var result bytes.Buffer
-	encoder := gob.NewEncoder(&result)
-	if err := encoder.Encode(txOutputs); nil != err {
+	result, err := EncodeTxOutputs(txOutputs)
+	if nil != err {
 		log.Panicf("serialize the utxo failed! %v\n", err)
 	}
-	return result.Bytes()
+	return result
 }
 
-// Deserializer 输出集合序列化
+// Deserializer 输出集合反序列化
 func Deserializer(txOutputsBytes []byte) *TXOutputs {
-	var txOutputs TXOutputs
-	decoder := gob.NewDecoder(bytes.NewReader(txOutputsBytes))
-	if err := decoder.Decode(&txOutputs); nil != err {
+	txOutputs, err := DecodeTxOutputs(txOutputsBytes)
+	if nil != err {
 		log.Panicf("deserialize the struct utxo failed! %v\n", err)
 	}
-	return &txOutputs
-}
\ No newline at end of file
+	return txOutputs
+}
@@ -8,4 +8,17 @@ type UTXO struct {
 	Index	int
 	// Output 本身
 	Output	*TxOutput
+	// 所属交易所在区块的高度（尚未上链的交易为 0）
+	Height	int64
+	// 是否是 coinbase 交易的输出
+	IsCoinbase	bool
+}
+
+// IsMature 判断 UTXO 能否被高度为 spendHeight 的区块中的交易花费
+// coinbase 输出需要经过 CoinbaseMaturity 个区块才能被花费，创世区块的 coinbase 除外（否则链上没有可以流通的货币）
+func (utxo *UTXO) IsMature(spendHeight int64) bool {
+	if !utxo.IsCoinbase || utxo.Height == 1 {
+		return true
+	}
+	return spendHeight-utxo.Height >= ActiveParams.CoinbaseMaturity
 }
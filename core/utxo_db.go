@@ -65,10 +65,9 @@ func (utxoSet *UTXOSet) FindUTXOWithAddress(address string) []*UTXO {
 			// 通过游标遍历 boltdb 数据库中的数据
 			for k, v := c.First(); nil != k; k, v = c.Next() {
 				txOutputs := Deserializer(v)
-				for _, utxo := range txOutputs.TXOutputs {
-					if utxo.UnLockScriptPubkeyWithAddress(address) {
-						utxoSignle := UTXO{Output: utxo}
-						utxos = append(utxos, &utxoSignle)
+				for _, utxo := range txOutputs.UTXOS {
+					if utxo.Output.UnLockScriptPubkeyWithAddress(address) {
+						utxos = append(utxos, utxo)
 					}
 				}
 			}
@@ -81,6 +80,50 @@ func (utxoSet *UTXOSet) FindUTXOWithAddress(address string) []*UTXO {
 	return utxos
 }
 
+// FindAllUTXO 获取 UTXO 集合中的所有 UTXO
+func (utxoSet *UTXOSet) FindAllUTXO() []*UTXO {
+	var utxos []*UTXO
+	err := utxoSet.Blockchain.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(utxoTableName))
+		if nil != b {
+			return b.ForEach(func(k, v []byte) error {
+				utxos = append(utxos, Deserializer(v).UTXOS...)
+				return nil
+			})
+		}
+		return nil
+	})
+	if nil != err {
+		log.Panicf("find all utxo failed! %v\n", err)
+	}
+	return utxos
+}
+
+// FindUTXO 查找指定交易哈希与输出索引对应的 UTXO，不存在（已花费）时返回 nil
+func (utxoSet *UTXOSet) FindUTXO(txHash []byte, index int) *UTXO {
+	var utxo *UTXO
+	err := utxoSet.Blockchain.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(utxoTableName))
+		if nil != b {
+			outputBytes := b.Get(txHash)
+			if nil == outputBytes {
+				return nil
+			}
+			for _, out := range Deserializer(outputBytes).UTXOS {
+				if out.Index == index {
+					utxo = out
+					break
+				}
+			}
+		}
+		return nil
+	})
+	if nil != err {
+		log.Panicf("find the utxo [%x:%d] failed! %v\n", txHash, index, err)
+	}
+	return utxo
+}
+
 // GetBalance 查询余额
 func (utxoSet *UTXOSet) GetBalance(address string) int {
 	UTXOS := utxoSet.FindUTXOWithAddress(address)
@@ -88,7 +131,7 @@ func (utxoSet *UTXOSet) GetBalance(address string) int {
 	for _, utxo := range UTXOS {
 		fmt.Printf("utxo-txhash: %x\n", utxo.TxHash)
 		fmt.Printf("utxo-index: %x\n", utxo.Index)
-		fmt.Printf("utxo-Ripemd160Hash: %x\n", utxo.Output.Ripemd160Hash)
+		fmt.Printf("utxo-ScriptPubKey: %s\n", DisasmScript(utxo.Output.ScriptPubKey))
 		fmt.Printf("utxo-Value: %x\n", utxo.Output.Value)
 		amount += utxo.Output.Value
 	}
@@ -96,46 +139,9 @@ func (utxoSet *UTXOSet) GetBalance(address string) int {
 }
 
 
-// Update 更新
+// Update 更新：把最新区块连接到 UTXO 集合
 func (utxoSet *UTXOSet) Update() {
 	// 获取最新区块
 	latestBlock, _ := utxoSet.Blockchain.Iterator().PreBlock()
-	utxoSet.Blockchain.DB.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(utxoTableName))
-		if nil != b {
-			// 查找最新一个区块的交易列表，因为每上链一个区块，utxo table 都更新一次，所以只需要查找最近一个区块中的交易
-			for _, tx := range latestBlock.Txs {
-				if !tx.IsCoinbaseTransaction() {
-					// 2. 将已经被当前这笔交易的输入所引用的 UTXO 删掉
-					for _, vin := range tx.Vins {
-						// 需要更新的输出
-						updateOutputs := TXOutputs{}
-						// 获取指定输入所引用的交易哈希的输出
-						outputBytes := b.Get(vin.TxHash)
-						// 输出列表
-						outs := Deserializer(outputBytes)
-						for outIdx, out := range outs.TXOutputs {
-							if vin.Vout != outIdx {
-								updateOutputs.TXOutputs = append(updateOutputs.TXOutputs, out)
-							}
-						}
-						// 如果交易中没有 UTXO 了，删除该交易
-						if len(updateOutputs.TXOutputs) == 0 {
-							b.Delete(vin.TxHash)
-						} else {
-							// 将更新之后的 utxo 数据存入数据库
-							b.Put(vin.TxHash, updateOutputs.Serialize())
-						}
-					}
-				}
-
-				// 获取当前区块中新生成的交易输出
-				// 1. 将最新区块中的 UTXO 插入
-				newOutputs := TXOutputs{}
-				newOutputs.TXOutputs = append(newOutputs.TXOutputs, tx.Vouts...)
-				b.Put(tx.TxHash, newOutputs.Serialize())
-			}
-		}
-		return nil
-	})
-}
\ No newline at end of file
+	utxoSet.ConnectBlock(latestBlock)
+}
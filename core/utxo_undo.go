@@ -0,0 +1,150 @@
+package core
+
+import (
+	"fmt"
+	"github.com/boltdb/bolt"
+	"log"
+)
+
+// UTXO 撤销记录管理文件
+
+// 连接区块时，把区块中的交易花费掉的 UTXO（包括输出本身以及所属的交易哈希、索引）
+// 按花费顺序保存为该区块的撤销记录，断开区块时根据撤销记录把 UTXO 集合恢复到连接之前的状态
+
+// undoTableName 保存区块撤销记录的表，key：区块哈希  value：被花费的 UTXO 列表
+const undoTableName = "undoTable"
+
+// ConnectBlock 把区块连接到 UTXO 集合：删除被花费的输出，加入新生成的输出，并保存撤销记录
+func (utxoSet *UTXOSet) ConnectBlock(block *Block) {
+	err := utxoSet.Blockchain.DB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(utxoTableName))
+		if nil != err {
+			return err
+		}
+		undo := TXOutputs{}
+		for _, tx := range block.Txs {
+			if !tx.IsCoinbaseTransaction() {
+				// 1. 将已经被当前这笔交易的输入所引用的 UTXO 删掉，并记录到撤销记录中
+				for _, vin := range tx.Vins {
+					spent, err := spendUTXO(b, vin.TxHash, vin.Vout)
+					if nil != err {
+						return err
+					}
+					undo.UTXOS = append(undo.UTXOS, spent)
+				}
+			}
+			// 2. 将当前交易中新生成的输出插入（不能被花费的数据输出除外）
+			for index, out := range tx.Vouts {
+				if IsUnspendable(out.ScriptPubKey) {
+					continue
+				}
+				if err := addUTXO(b, &UTXO{tx.TxHash, index, out, block.Height, tx.IsCoinbaseTransaction()}); nil != err {
+					return err
+				}
+			}
+		}
+		// 3. 保存撤销记录
+		ub, err := tx.CreateBucketIfNotExists([]byte(undoTableName))
+		if nil != err {
+			return err
+		}
+		if err := ub.Put(block.Hash, undo.Serialize()); nil != err {
+			return err
+		}
+		// 4. 从交易池中删除已经上链以及与之冲突的交易
+		return removeForBlock(tx, block)
+	})
+	if nil != err {
+		log.Panicf("connect the block [%x] to utxo table failed! %v\n", block.Hash, err)
+	}
+}
+
+// DisconnectBlock 从 UTXO 集合中断开区块：删除区块中生成的输出，根据撤销记录恢复被花费的输出
+// 只能断开当前 UTXO 集合中最后一个被连接的区块
+func (utxoSet *UTXOSet) DisconnectBlock(block *Block) error {
+	return utxoSet.Blockchain.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(utxoTableName))
+		ub := tx.Bucket([]byte(undoTableName))
+		if nil == b || nil == ub {
+			return fmt.Errorf("the undo data of block [%x] is not found", block.Hash)
+		}
+		undoBytes := ub.Get(block.Hash)
+		if nil == undoBytes {
+			return fmt.Errorf("the undo data of block [%x] is not found", block.Hash)
+		}
+		undo := Deserializer(undoBytes).UTXOS
+		// 按照与连接时相反的顺序处理交易
+		for i := len(block.Txs) - 1; i >= 0; i-- {
+			tx := block.Txs[i]
+			// 1. 删除当前交易生成的输出
+			if err := b.Delete(tx.TxHash); nil != err {
+				return err
+			}
+			if tx.IsCoinbaseTransaction() {
+				continue
+			}
+			// 2. 恢复当前交易花费掉的输出
+			for j := len(tx.Vins) - 1; j >= 0; j-- {
+				if len(undo) == 0 {
+					return fmt.Errorf("the undo data of block [%x] is inconsistent", block.Hash)
+				}
+				spent := undo[len(undo)-1]
+				undo = undo[:len(undo)-1]
+				if err := addUTXO(b, spent); nil != err {
+					return err
+				}
+			}
+		}
+		if len(undo) != 0 {
+			return fmt.Errorf("the undo data of block [%x] is inconsistent", block.Hash)
+		}
+		return ub.Delete(block.Hash)
+	})
+}
+
+// spendUTXO 从 UTXO 表中删除指定的输出，返回被删除的 UTXO
+func spendUTXO(b *bolt.Bucket, txHash []byte, index int) (*UTXO, error) {
+	outputBytes := b.Get(txHash)
+	if nil == outputBytes {
+		return nil, fmt.Errorf("the utxo [%x:%d] is not found", txHash, index)
+	}
+	var spent *UTXO
+	updateOutputs := TXOutputs{}
+	for _, out := range Deserializer(outputBytes).UTXOS {
+		if out.Index == index {
+			spent = out
+		} else {
+			updateOutputs.UTXOS = append(updateOutputs.UTXOS, out)
+		}
+	}
+	if nil == spent {
+		return nil, fmt.Errorf("the utxo [%x:%d] is not found", txHash, index)
+	}
+	// 如果交易中没有 UTXO 了，删除该交易
+	if len(updateOutputs.UTXOS) == 0 {
+		return spent, b.Delete(txHash)
+	}
+	return spent, b.Put(txHash, updateOutputs.Serialize())
+}
+
+// addUTXO 向 UTXO 表中加入一个输出，同一交易的输出按索引排序
+func addUTXO(b *bolt.Bucket, utxo *UTXO) error {
+	outputs := TXOutputs{}
+	if outputBytes := b.Get(utxo.TxHash); nil != outputBytes {
+		outputs = *Deserializer(outputBytes)
+	}
+	index := len(outputs.UTXOS)
+	for i, out := range outputs.UTXOS {
+		if out.Index == utxo.Index {
+			return fmt.Errorf("the utxo [%x:%d] already exists", utxo.TxHash, utxo.Index)
+		}
+		if out.Index > utxo.Index {
+			index = i
+			break
+		}
+	}
+	outputs.UTXOS = append(outputs.UTXOS, nil)
+	copy(outputs.UTXOS[index+1:], outputs.UTXOS[index:])
+	outputs.UTXOS[index] = utxo
+	return b.Put(utxo.TxHash, outputs.Serialize())
+}
@@ -0,0 +1,292 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+)
+
+// 区块验证管理文件
+
+// RejectReason 区块被拒绝的原因
+type RejectReason int
+
+const (
+	// RejectDuplicate 区块已经存在
+	RejectDuplicate RejectReason = iota + 1
+	// RejectMalformed 区块结构不完整
+	RejectMalformed
+	// RejectOrphan 找不到前一个区块
+	RejectOrphan
+	// RejectBadHeight 区块高度与前一个区块不连续
+	RejectBadHeight
+	// RejectBadBits 区块难度不符合难度调整规则
+	RejectBadBits
+	// RejectInvalidPow 区块哈希不满足目标难度
+	RejectInvalidPow
+	// RejectBadHash 区块哈希与区块头不一致
+	RejectBadHash
+	// RejectBadMerkleRoot 区块头中的 Merkle 根与交易列表不一致
+	RejectBadMerkleRoot
+	// RejectBadCoinbase 区块没有且只有一笔位于首位的 coinbase 交易
+	RejectBadCoinbase
+	// RejectBadCoinbaseValue coinbase 交易的奖励超出规定
+	RejectBadCoinbaseValue
+	// RejectMissingInput 交易引用的输出不存在或者已经被花费
+	RejectMissingInput
+	// RejectDoubleSpend 区块内的多笔交易花费了同一个输出
+	RejectDoubleSpend
+	// RejectBadTxValue 交易输出金额大于输入金额
+	RejectBadTxValue
+	// RejectBadSignature 交易签名验证失败
+	RejectBadSignature
+	// RejectBadTxHash 交易哈希与交易内容不一致
+	RejectBadTxHash
+	// RejectConflict 交易花费的输出已经被交易池中的其他交易花费
+	RejectConflict
+	// RejectImmatureCoinbase 交易花费了尚未成熟的 coinbase 输出
+	RejectImmatureCoinbase
+	// RejectTimeTooOld 区块时间戳不大于前面区块时间戳的中位数
+	RejectTimeTooOld
+	// RejectTimeTooNew 区块时间戳超过网络调整时间太多
+	RejectTimeTooNew
+	// RejectNonFinal 交易的锁定时间尚未到达
+	RejectNonFinal
+	// RejectSequenceLock 交易输入的相对锁定时间尚未到达
+	RejectSequenceLock
+	// RejectNonStandard 交易不符合交易池的标准策略
+	RejectNonStandard
+	// RejectBadSeal 区块不是由轮到出块的签名者签名
+	RejectBadSeal
+	// RejectBadGenesis 已经有创世区块时收到了没有前一个区块的区块（例如其他链的创世区块）
+	RejectBadGenesis
+)
+
+// rejectReasonNames 拒绝原因的名称
+var rejectReasonNames = map[RejectReason]string{
+	RejectDuplicate:        "duplicate",
+	RejectMalformed:        "malformed",
+	RejectOrphan:           "orphan",
+	RejectBadHeight:        "bad-height",
+	RejectBadBits:          "bad-diffbits",
+	RejectInvalidPow:       "high-hash",
+	RejectBadHash:          "bad-blockhash",
+	RejectBadMerkleRoot:    "bad-merkleroot",
+	RejectBadCoinbase:      "bad-coinbase",
+	RejectBadCoinbaseValue: "bad-cb-amount",
+	RejectMissingInput:     "missing-inputs",
+	RejectDoubleSpend:      "double-spend",
+	RejectBadTxValue:       "bad-txns-value",
+	RejectBadSignature:     "bad-signature",
+	RejectBadTxHash:        "bad-txid",
+	RejectConflict:         "txn-mempool-conflict",
+	RejectImmatureCoinbase: "bad-txns-premature-spend-of-coinbase",
+	RejectTimeTooOld:       "time-too-old",
+	RejectTimeTooNew:       "time-too-new",
+	RejectNonFinal:         "bad-txns-nonfinal",
+	RejectSequenceLock:     "non-sequence-final",
+	RejectNonStandard:      "non-standard",
+	RejectBadSeal:          "bad-seal",
+	RejectBadGenesis:       "bad-genesis",
+}
+
+// String 拒绝原因的名称
+func (reason RejectReason) String() string {
+	if name, ok := rejectReasonNames[reason]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", int(reason))
+}
+
+// BlockRejectError 区块验证失败时返回的错误，记录了拒绝的原因
+type BlockRejectError struct {
+	Reason RejectReason // 拒绝原因
+	Hash   []byte       // 被拒绝的区块哈希
+	Msg    string       // 详细描述
+}
+
+// Error 错误描述
+func (e *BlockRejectError) Error() string {
+	return fmt.Sprintf("block [%x] rejected (%s): %s", e.Hash, e.Reason, e.Msg)
+}
+
+// rejectBlock 生成一个区块验证错误
+func rejectBlock(block *Block, reason RejectReason, format string, args ...interface{}) error {
+	return &BlockRejectError{Reason: reason, Hash: block.Hash, Msg: fmt.Sprintf(format, args...)}
+}
+
+// IsRejectReason 判断错误是否是指定原因的区块或交易验证错误
+func IsRejectReason(err error, reason RejectReason) bool {
+	switch rejectErr := err.(type) {
+	case *BlockRejectError:
+		return rejectErr.Reason == reason
+	case *TxRejectError:
+		return rejectErr.Reason == reason
+	}
+	return false
+}
+
+// CheckBlock 对区块进行完整的验证
+// 1. 区块结构与交易结构
+// 2. Merkle 根、区块哈希以及共识引擎的封装（工作量证明或者签名者的签名）
+// 3. 与前一个区块的连接关系、高度、难度以及时间戳
+// 4. coinbase 交易
+// 5. 如果区块连接在当前最新区块之后，还要基于 UTXO 集合验证交易的输入、金额与签名
+func (bc *BlockChain) CheckBlock(block *Block) error {
+	if err := checkBlockSanity(block); nil != err {
+		return err
+	}
+	// 区块头中的 Merkle 根必须与交易列表一致，并且交易列表不能通过重复交易构造出相同的 Merkle 根
+	mTree := block.BuildMerkleTree()
+	if mTree.Mutated {
+		return rejectBlock(block, RejectBadMerkleRoot, "the transactions contain duplicates")
+	}
+	if !bytes.Equal(block.MerkleRoot, mTree.RootNode.Data) {
+		return rejectBlock(block, RejectBadMerkleRoot, "the merkle root %x does not match the transactions", block.MerkleRoot)
+	}
+	// 已经有创世区块时，没有前一个区块的区块只能属于其他链，不能作为侧链保存
+	if len(block.PrevBlockHash) == 0 && len(bc.TipHash()) > 0 {
+		return rejectBlock(block, RejectBadGenesis, "the chain already has a genesis block")
+	}
+	// 前一个区块（只需要区块头）
+	prev := bc.GetHeader(block.PrevBlockHash)
+	// 先验证封装，再处理孤块，避免无效的区块触发同步
+	if err := bc.Engine().VerifySeal(prev, block); nil != err {
+		return err
+	}
+	if len(block.PrevBlockHash) > 0 && nil == prev {
+		return rejectBlock(block, RejectOrphan, "the previous block [%x] is not found", block.PrevBlockHash)
+	}
+	if nil == prev && block.Height != 1 || nil != prev && block.Height != prev.Height+1 {
+		return rejectBlock(block, RejectBadHeight, "the height %d does not follow the previous block", block.Height)
+	}
+	if err := bc.CheckBlockBits(block); nil != err {
+		return rejectBlock(block, RejectBadBits, "%v", err)
+	}
+	// 时间戳必须大于前面区块的 median-time-past，并且不能超过网络调整时间太多
+	if nil != prev {
+		if medianTime := bc.CalcPastMedianTime(prev); block.TimeStamp <= medianTime {
+			return rejectBlock(block, RejectTimeTooOld, "the timestamp %d is not after the median time %d", block.TimeStamp, medianTime)
+		}
+	}
+	if maxTime := bc.TimeSource.AdjustedTime() + ActiveParams.MaxFutureBlockTime; block.TimeStamp > maxTime {
+		return rejectBlock(block, RejectTimeTooNew, "the timestamp %d is later than %d", block.TimeStamp, maxTime)
+	}
+	// 只有连接在最新区块之后的区块才能基于 UTXO 集合进行验证
+	if nil != prev && bytes.Equal(block.PrevBlockHash, bc.TipHash()) {
+		return bc.checkBlockTransactions(block)
+	}
+	return nil
+}
+
+// checkBlockSanity 与上下文无关的区块结构检查
+func checkBlockSanity(block *Block) error {
+	if len(block.Hash) == 0 || len(block.Txs) == 0 {
+		return rejectBlock(block, RejectMalformed, "the block has no hash or no transactions")
+	}
+	for _, tx := range block.Txs {
+		if len(tx.Vins) == 0 || len(tx.Vouts) == 0 {
+			return rejectBlock(block, RejectMalformed, "tx [%x] has no inputs or no outputs", tx.TxHash)
+		}
+		for _, out := range tx.Vouts {
+			if out.Value < 0 {
+				return rejectBlock(block, RejectBadTxValue, "tx [%x] has a negative output", tx.TxHash)
+			}
+		}
+		// 迁移交易的签名无法按照当前的规则验证，只能由 migrate 从本地的旧数据库导入
+		if tx.Version == migratedTxVersion {
+			return rejectBlock(block, RejectMalformed, "tx [%x] is a migrated transaction", tx.TxHash)
+		}
+		// 交易哈希必须能够由交易内容重新计算得到
+		if !bytes.Equal(tx.TxHash, tx.Hash()) {
+			return rejectBlock(block, RejectBadTxHash, "tx [%x] does not match its contents", tx.TxHash)
+		}
+	}
+	// 第一笔交易必须是 coinbase 交易，并且只能有一笔 coinbase 交易
+	if !block.Txs[0].IsCoinbaseTransaction() {
+		return rejectBlock(block, RejectBadCoinbase, "the first transaction is not a coinbase")
+	}
+	for _, tx := range block.Txs[1:] {
+		if tx.IsCoinbaseTransaction() {
+			return rejectBlock(block, RejectBadCoinbase, "more than one coinbase transaction")
+		}
+	}
+	// coinbase 中必须写入所在区块的高度
+	if height, ok := block.Txs[0].CoinbaseHeight(); !ok || height != block.Height {
+		return rejectBlock(block, RejectBadCoinbase, "the coinbase does not commit to the height %d", block.Height)
+	}
+	return nil
+}
+
+// checkBlockTransactions 基于 UTXO 集合验证区块中的普通交易
+// 交易只能花费 UTXO 集合中的输出或者同一区块中前面交易的输出，并且每个输出只能被花费一次，
+// coinbase 输出需要成熟之后才能被花费，coinbase 最多只能领取区块奖励与所有交易的手续费之和，
+// 交易的绝对锁定时间与相对锁定时间必须已经到达
+func (bc *BlockChain) checkBlockTransactions(block *Block) error {
+	utxoSet := &UTXOSet{Blockchain: bc}
+	prev := bc.GetHeader(block.PrevBlockHash)
+	medianTime := bc.CalcPastMedianTime(prev)
+	// 区块内已经处理过的交易
+	blockTxs := make(map[string]*Transaction)
+	// 区块内已经被花费的输出
+	spent := make(map[string]bool)
+	// 手续费总额
+	var fees int
+	for index, tx := range block.Txs {
+		if !tx.IsFinal(block.Height, medianTime) {
+			return rejectBlock(block, RejectNonFinal, "tx [%x] is locked until %d", tx.TxHash, tx.LockTime)
+		}
+		if index > 0 {
+			var inputValue, outputValue int
+			var utxos []*UTXO
+			for _, vin := range tx.Vins {
+				outpoint := fmt.Sprintf("%x:%d", vin.TxHash, vin.Vout)
+				if spent[outpoint] {
+					return rejectBlock(block, RejectDoubleSpend, "tx [%x] spends %s twice", tx.TxHash, outpoint)
+				}
+				spent[outpoint] = true
+				// 优先查找区块内的交易，再查找 UTXO 集合
+				var utxo *UTXO
+				if prevTx, ok := blockTxs[hex.EncodeToString(vin.TxHash)]; ok {
+					if vin.Vout >= 0 && vin.Vout < len(prevTx.Vouts) && !IsUnspendable(prevTx.Vouts[vin.Vout].ScriptPubKey) {
+						utxo = &UTXO{prevTx.TxHash, vin.Vout, prevTx.Vouts[vin.Vout], block.Height, prevTx.IsCoinbaseTransaction()}
+					}
+				} else {
+					utxo = utxoSet.FindUTXO(vin.TxHash, vin.Vout)
+				}
+				if nil == utxo {
+					return rejectBlock(block, RejectMissingInput, "tx [%x] spends a missing or spent output %s", tx.TxHash, outpoint)
+				}
+				if !utxo.IsMature(block.Height) {
+					return rejectBlock(block, RejectImmatureCoinbase, "tx [%x] spends the coinbase output %s from height %d",
+						tx.TxHash, outpoint, utxo.Height)
+				}
+				inputValue += utxo.Output.Value
+				utxos = append(utxos, utxo)
+			}
+			if err := bc.checkSequenceLocks(tx, utxos, prev, block.Height); nil != err {
+				return rejectBlock(block, RejectSequenceLock, "tx [%x]: %v", tx.TxHash, err)
+			}
+			for _, out := range tx.Vouts {
+				outputValue += out.Value
+			}
+			if outputValue > inputValue {
+				return rejectBlock(block, RejectBadTxValue, "tx [%x] spends %d but only has %d", tx.TxHash, outputValue, inputValue)
+			}
+			if !bc.VerityTransaction(tx, block.Txs[:index]) {
+				return rejectBlock(block, RejectBadSignature, "the signature of tx [%x] is invalid", tx.TxHash)
+			}
+			fees += inputValue - outputValue
+		}
+		blockTxs[hex.EncodeToString(tx.TxHash)] = tx
+	}
+	var reward int
+	for _, out := range block.Txs[0].Vouts {
+		reward += out.Value
+	}
+	if subsidy := BlockSubsidy(block.Height); reward > subsidy+fees {
+		return rejectBlock(block, RejectBadCoinbaseValue, "the coinbase pays %d, more than the subsidy %d plus fees %d",
+			reward, subsidy, fees)
+	}
+	return nil
+}
@@ -1,19 +1,17 @@
 package core
 
 import (
-	"bkc/utils"
 	"bytes"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/gob"
 	"golang.org/x/crypto/ripemd160"
 	"log"
+	"math/big"
 )
 
-// 校验和长度
-const addressCheckSumLen = 4
-
 // 钱包管理相关文件
 
 // Wallet 钱包基本结构
@@ -42,10 +40,51 @@ func newKeyPair() (ecdsa.PrivateKey, []byte)  {
 		log.Panicf("ecdsa generate private key failed! %v\n", err)
 	}
 	// 3. 通过私钥生成公钥
-	pubKey := append(priv.PublicKey.X.Bytes(), priv.PublicKey.Y.Bytes()...)
+	pubKey := publicKeyBytes(&priv.PublicKey)
 	return *priv, pubKey
 }
 
+// walletData 钱包的持久化结构，ecdsa.PrivateKey 中的椭圆曲线无法直接使用 gob 编码
+type walletData struct {
+	D			[]byte	// 私钥
+	PublicKey	[]byte	// 公钥
+}
+
+// GobEncode 钱包编码，只保存私钥数值与公钥
+func (w *Wallet) GobEncode() ([]byte, error) {
+	var buffer bytes.Buffer
+	data := walletData{D: w.PrivateKey.D.Bytes(), PublicKey: w.PublicKey}
+	if err := gob.NewEncoder(&buffer).Encode(data); nil != err {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// GobDecode 钱包解码，通过私钥数值还原完整的密钥对
+// 旧版本钱包的公钥为 x + y（64 字节，或者省略了坐标开头的 0）：保留钱包文件中的旧公钥，地址不变，
+// 仍然可以花费旧地址上的输出；与私钥不对应的公钥改为压缩公钥
+func (w *Wallet) GobDecode(walletBytes []byte) error {
+	var data walletData
+	if err := gob.NewDecoder(bytes.NewReader(walletBytes)).Decode(&data); nil != err {
+		return err
+	}
+	w.restore(data.D, data.PublicKey)
+	return nil
+}
+
+// restore 通过私钥数值还原完整的密钥对，publicKey 为钱包文件中保存的公钥
+func (w *Wallet) restore(d []byte, publicKey []byte) {
+	curve := elliptic.P256()
+	w.PrivateKey.Curve = curve
+	w.PrivateKey.D = new(big.Int).SetBytes(d)
+	w.PrivateKey.X, w.PrivateKey.Y = curve.ScalarBaseMult(d)
+	w.PublicKey = publicKeyBytes(&w.PrivateKey.PublicKey)
+	for _, legacy := range legacyPublicKeys(&w.PrivateKey.PublicKey) {
+		if bytes.Equal(publicKey, legacy) {
+			w.PublicKey = legacy
+		}
+	}
+}
 
 // Ripemd160Hash 实现双哈希
 func Ripemd160Hash(pubKey []byte) []byte {
@@ -60,38 +99,16 @@ func Ripemd160Hash(pubKey []byte) []byte {
 	return rmd160.Sum(nil)
 }
 
-// CheckSum 生成校验和
-func CheckSum(input []byte) []byte {
-	first_hash := sha256.Sum256(input)
-	second_hash := sha256.Sum256(first_hash[:])
-	return second_hash[:addressCheckSumLen]
-}
-
 // GetAddress 通过钱包（公钥）获取地址
 func (w *Wallet) GetAddress() []byte {
 	// 1. 获取 hash160
 	ripemd160Hash := Ripemd160Hash(w.PublicKey)
-	// 2. 获取校验和
-	checkSumBytes := CheckSum(ripemd160Hash)
-	// 3. 地址组成拼接
-	addressBytes := append(ripemd160Hash, checkSumBytes...)
-	// 4. base58编码
-	b58Bytes := utils.Base58Encode(addressBytes)
-	return b58Bytes
+	// 2. 版本前缀 + hash160 + 校验和，base58编码
+	return []byte(encodeAddress(ActiveParams.PubKeyHashAddrID, ripemd160Hash))
+}
+
+// GetBech32Address 通过钱包（公钥）获取 bech32 形式的地址，与 GetAddress 对应同一个 hash160
+func (w *Wallet) GetBech32Address() []byte {
+	return []byte(encodeBech32Address(ActiveParams.PubKeyHashAddrID, Ripemd160Hash(w.PublicKey)))
 }
 
-// IsValidForAddress 判断地址有效性
-func IsValidForAddress(addressBytes []byte) bool {
-	// 1. 地址通过 base58Decode 进行解码
-	pubkeyCheckSumByte := utils.Base58Decode(addressBytes)
-	// 2. 拆分，进行校验和校验
-	checkSumBytes := pubkeyCheckSumByte[len(pubkeyCheckSumByte) -addressCheckSumLen:]
-	ripemd160hash := pubkeyCheckSumByte[:len(pubkeyCheckSumByte) -addressCheckSumLen]
-	// 3. 生成校验和
-	checkBytes := CheckSum(ripemd160hash)
-	// 4. 比较
-	if bytes.Compare(checkBytes, checkSumBytes) == 0 {
-		return true
-	}
-	return false
-}
\ No newline at end of file
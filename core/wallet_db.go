@@ -2,22 +2,23 @@ package core
 
 import (
 	"bytes"
-	"crypto/elliptic"
 	"encoding/gob"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math/big"
 	"os"
 )
 
 // 钱包集合管理文件
 
-// 钱包集合持久化文件
-const walletFile = "Wallets_%s.dat"
+// 钱包集合持久化文件，不同网络使用不同的文件
+var walletFile = "Wallets_%s.dat"
 
 // Wallets 钱包集合的基本结构
 type Wallets struct {
 	Wallets map[string] *Wallet // key:地址  value:钱包结构
+	Scripts map[string] []byte  // key:多签地址  value:赎回脚本
 }
 
 // NewWallets 初始化钱包集合
@@ -28,6 +29,7 @@ func NewWallets(nodeId string) *Wallets {
 	if _, err := os.Stat(walletFile); os.IsNotExist(err) {
 		wallets := &Wallets{}
 		wallets.Wallets = make(map[string] *Wallet)
+		wallets.Scripts = make(map[string] []byte)
 		return wallets
 	}
 	// 2. 文件存在，读取内容
@@ -36,15 +38,69 @@ func NewWallets(nodeId string) *Wallets {
 		log.Panicf("read the file content failed %v\n", err)
 	}
 	var wallets Wallets
-	gob.Register(elliptic.P256())
 	decoder := gob.NewDecoder(bytes.NewReader(fileContent))
 	err = decoder.Decode(&wallets)
 	if nil != err {
-		log.Panicf("decode the file content failed! %v\n", err)
+		// 旧版本的钱包文件
+		legacy, legacyErr := decodeLegacyWallets(fileContent)
+		if nil != legacyErr {
+			log.Panicf("decode the file content failed! %v\n", err)
+		}
+		wallets = *legacy
 	}
+	// 旧版本的钱包文件中没有赎回脚本
+	if nil == wallets.Scripts {
+		wallets.Scripts = make(map[string] []byte)
+	}
+	wallets.rekey()
 	return &wallets
 }
 
+// legacyWallets 旧版本的钱包文件结构：gob 直接编码 ecdsa.PrivateKey（包含注册过的椭圆曲线），
+// 解码时只需要私钥数值与公钥，其余字段（椭圆曲线、公钥坐标）被忽略，不需要注册椭圆曲线的类型
+type legacyWallets struct {
+	Wallets map[string] *legacyWallet
+}
+
+// legacyWallet 旧版本的钱包
+type legacyWallet struct {
+	PrivateKey struct {
+		D *big.Int
+	}
+	PublicKey []byte
+}
+
+// decodeLegacyWallets 解析旧版本的钱包文件
+func decodeLegacyWallets(fileContent []byte) (*Wallets, error) {
+	var legacy legacyWallets
+	if err := gob.NewDecoder(bytes.NewReader(fileContent)).Decode(&legacy); nil != err {
+		return nil, err
+	}
+	wallets := &Wallets{Wallets: make(map[string] *Wallet)}
+	for address, legacyWallet := range legacy.Wallets {
+		if nil == legacyWallet.PrivateKey.D {
+			return nil, fmt.Errorf("the wallet [%s] has no private key", address)
+		}
+		wallet := &Wallet{}
+		wallet.restore(legacyWallet.PrivateKey.D.Bytes(), legacyWallet.PublicKey)
+		wallets.Wallets[address] = wallet
+	}
+	return wallets, nil
+}
+
+// rekey 按照当前的地址编码重新计算钱包与赎回脚本的地址，旧版本钱包文件中的地址使用旧的编码
+func (wallets *Wallets) rekey() {
+	keyed := make(map[string] *Wallet, len(wallets.Wallets))
+	for _, wallet := range wallets.Wallets {
+		keyed[string(wallet.GetAddress())] = wallet
+	}
+	scripts := make(map[string] []byte, len(wallets.Scripts))
+	for _, script := range wallets.Scripts {
+		scripts[ScriptHashAddress(script)] = script
+	}
+	wallets.Wallets, wallets.Scripts = keyed, scripts
+}
+
 // CreateWallet 添加新的钱包到集合中
 func (wallets *Wallets) CreateWallet(nodeId string)  {
 	// 1. 创建钱包
@@ -55,11 +111,57 @@ func (wallets *Wallets) CreateWallet(nodeId string)  {
 	wallets.SaveWallets(nodeId)
 }
 
+// AddScript 添加赎回脚本到集合中，返回赎回脚本对应的地址
+func (wallets *Wallets) AddScript(script []byte, nodeId string) string {
+	address := ScriptHashAddress(script)
+	wallets.Scripts[address] = script
+	wallets.SaveWallets(nodeId)
+	return address
+}
+
+// WalletForAddress 查找地址（Base58Check 或者 Bech32 形式）对应的钱包，没有找到时返回 nil
+func (wallets *Wallets) WalletForAddress(address string) *Wallet {
+	version, hash160, err := DecodeAddress(address)
+	if nil != err || version != ActiveParams.PubKeyHashAddrID {
+		return nil
+	}
+	return wallets.WalletForPubKeyHash(hash160)
+}
+
+// ScriptForAddress 查找多签地址（Base58Check 或者 Bech32 形式）对应的赎回脚本
+func (wallets *Wallets) ScriptForAddress(address string) ([]byte, bool) {
+	version, hash160, err := DecodeAddress(address)
+	if nil != err || version != ActiveParams.ScriptHashAddrID {
+		return nil, false
+	}
+	script, ok := wallets.Scripts[encodeAddress(version, hash160)]
+	return script, ok
+}
+
+// WalletForPubKey 查找公钥对应的钱包，没有找到时返回 nil
+func (wallets *Wallets) WalletForPubKey(pubKey []byte) *Wallet {
+	for _, wallet := range wallets.Wallets {
+		if bytes.Equal(wallet.PublicKey, pubKey) {
+			return wallet
+		}
+	}
+	return nil
+}
+
+// WalletForPubKeyHash 查找公钥哈希对应的钱包，没有找到时返回 nil
+func (wallets *Wallets) WalletForPubKeyHash(pubKeyHash []byte) *Wallet {
+	for _, wallet := range wallets.Wallets {
+		if bytes.Equal(Ripemd160Hash(wallet.PublicKey), pubKeyHash) {
+			return wallet
+		}
+	}
+	return nil
+}
+
 // SaveWallets 持久化钱包信息(存储到文件中)
 func (wallets *Wallets) SaveWallets(nodeId string) {
 	walletFile := fmt.Sprintf(walletFile, nodeId)
 	var content bytes.Buffer	// 钱包内容
-	gob.Register(elliptic.P256())   // 注册256椭圆，注册之后，可以直接在内部对 curve 的接口进行编码
 	encoder := gob.NewEncoder(&content)
 	err := encoder.Encode(&wallets)
 	if nil != err {
@@ -20,4 +20,6 @@ const (
 	CMD_GETDATA = "getdata"
 	// 接收到新区块之后，进行处理
 	CMD_BLOCK = "block"
+	// 接收到新交易之后，加入交易池并转发
+	CMD_TX = "tx"
 )
\ No newline at end of file
@@ -0,0 +1,135 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// 网络消息编码管理文件
+
+// 消息（命令之后的数据）使用与区块、交易相同的规范编码，不依赖 Go 语言：
+// 1. 所有整数都使用小端序
+// 2. 变长字节（bytes）与字符串：uint32 长度 + 数据
+// 3. 列表：uint32 元素个数 + 每个元素的编码
+//
+// version：  int64 区块高度 + bytes 节点地址 + int64 时间戳
+// getblocks：bytes 节点地址
+// inv：      bytes 节点地址 + 区块哈希列表（每个哈希为 bytes）
+// getdata：  bytes 节点地址 + bytes 区块哈希
+// block：    bytes 节点地址 + bytes 区块编码
+// tx：       bytes 节点地址 + bytes 交易编码
+
+// maxMessageFieldLen 单个变长字段的最大长度，防止恶意消息导致分配过大的内存
+const maxMessageFieldLen = 32 << 20
+
+// errShortMessage 消息不完整
+var errShortMessage = errors.New("unexpected end of message")
+
+// message 可以编码为网络消息的结构
+type message interface {
+	write(w *messageWriter)
+	read(r *messageReader)
+}
+
+// encodeMessage 把消息编码为字节
+func encodeMessage(msg message) []byte {
+	w := &messageWriter{}
+	msg.write(w)
+	return w.buf.Bytes()
+}
+
+// decodeMessage 解析消息，数据不完整、字段过长或者有多余的数据时返回错误
+func decodeMessage(data []byte, msg message) error {
+	r := &messageReader{data: data}
+	msg.read(r)
+	if nil == r.err && len(r.data) > 0 {
+		r.err = fmt.Errorf("%d trailing bytes", len(r.data))
+	}
+	return r.err
+}
+
+// messageWriter 消息的写入器
+type messageWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *messageWriter) writeUint32(v uint32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	w.buf.Write(b[:])
+}
+
+func (w *messageWriter) writeInt64(v int64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(v))
+	w.buf.Write(b[:])
+}
+
+func (w *messageWriter) writeBytes(data []byte) {
+	w.writeUint32(uint32(len(data)))
+	w.buf.Write(data)
+}
+
+// messageReader 消息的读取器，出错之后的读取都返回零值，由 decodeMessage 在最后检查错误
+type messageReader struct {
+	data []byte
+	err  error
+}
+
+func (r *messageReader) next(n int) []byte {
+	if nil != r.err {
+		return nil
+	}
+	if n < 0 || len(r.data) < n {
+		r.err = errShortMessage
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *messageReader) readUint32() uint32 {
+	b := r.next(4)
+	if nil == b {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (r *messageReader) readInt64() int64 {
+	b := r.next(8)
+	if nil == b {
+		return 0
+	}
+	return int64(binary.LittleEndian.Uint64(b))
+}
+
+func (r *messageReader) readBytes() []byte {
+	n := r.readUint32()
+	if n > maxMessageFieldLen {
+		if nil == r.err {
+			r.err = fmt.Errorf("the field is too long (%d bytes)", n)
+		}
+		return nil
+	}
+	b := r.next(int(n))
+	if nil == b {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+// readCount 读取列表的元素个数，每个元素至少占用 minSize 字节
+func (r *messageReader) readCount(minSize int) int {
+	n := r.readUint32()
+	if nil == r.err && uint64(n)*uint64(minSize) > uint64(len(r.data)) {
+		r.err = errShortMessage
+	}
+	if nil != r.err {
+		return 0
+	}
+	return int(n)
+}
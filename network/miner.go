@@ -0,0 +1,71 @@
+package network
+
+import (
+	"bkc/core"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 节点挖矿管理文件
+
+// minerRetryDelay 挖矿出错之后重试前的等待时间
+const minerRetryDelay = 5 * time.Second
+
+// startMiner 持续挖矿：不断根据交易池生成区块模板，使用共识引擎封装区块，
+// 把新区块添加到区块链中，并广播给其他节点，直到 ctx 被取消
+func startMiner(ctx context.Context, bc *core.BlockChain, minerAddress string, workers int) {
+	fmt.Printf("开始挖矿，矿工地址 [%s]\n", minerAddress)
+	for {
+		tipChanged := bc.TipChanged()
+		block, err := bc.MineBlock(ctx, minerAddress, workers, printMiningProgress)
+		if nil != ctx.Err() {
+			fmt.Println("停止挖矿")
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			// 挖矿期间收到了其他节点的区块，基于新的最新区块重新挖矿
+			fmt.Println("最新区块已经变化，重新生成区块模板")
+			continue
+		}
+		if errors.Is(err, core.ErrNotInTurn) {
+			// poa 共识中没有轮到本节点出块，等待其他签名者的区块
+			fmt.Printf("%v\n", err)
+			select {
+			case <-tipChanged:
+			case <-ctx.Done():
+			}
+			continue
+		}
+		if nil != err {
+			// 等待一段时间（或者最新区块变化）之后再重试，避免同样的错误导致空转
+			fmt.Printf("%v\n", err)
+			select {
+			case <-tipChanged:
+			case <-time.After(minerRetryDelay):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		// 挖出的区块没有成为最新区块，不需要广播
+		if !bytes.Equal(bc.TipHash(), block.Hash) {
+			continue
+		}
+		fmt.Printf("挖出新区块 [%x]，高度 %d，交易 %d 笔\n", block.Hash, block.Height, len(block.Txs))
+		broadcastBlock(block.Hash)
+	}
+}
+
+// printMiningProgress 打印挖矿进度
+func printMiningProgress(progress *core.MiningProgress) {
+	fmt.Printf("碰撞次数：%d，耗时 %v，算力 %.0f 次/秒\n", progress.Hashes, progress.Elapsed, progress.HashRate)
+}
+
+// broadcastBlock 向所有已知节点展示新区块
+func broadcastBlock(hash []byte) {
+	for _, node := range peers() {
+		sendInv(node, [][]byte{hash})
+	}
+}
@@ -0,0 +1,70 @@
+package network
+
+import (
+	"bkc/core"
+	"bytes"
+	"encoding/hex"
+	"sync"
+)
+
+// 孤块管理文件
+
+// maxOrphanBlocks 最多缓存的孤块数量，超过时淘汰最早到达的孤块，避免其他节点发送大量孤块耗尽内存
+const maxOrphanBlocks = 100
+
+// 区块可能先于其父区块到达，此时先缓存，等父区块添加成功之后再处理
+var (
+	orphanMutex  sync.Mutex
+	orphanBlocks = make(map[string][]*core.Block) // key：父区块哈希  value：等待该父区块的区块列表
+	orphanOrder  []*core.Block                    // 按照到达顺序排列的所有孤块
+)
+
+// addOrphanBlock 缓存孤块，已经缓存的孤块不会重复添加
+func addOrphanBlock(block *core.Block) {
+	orphanMutex.Lock()
+	defer orphanMutex.Unlock()
+	key := hex.EncodeToString(block.PrevBlockHash)
+	for _, orphan := range orphanBlocks[key] {
+		if bytes.Equal(orphan.Hash, block.Hash) {
+			return
+		}
+	}
+	if len(orphanOrder) >= maxOrphanBlocks {
+		removeOrphanBlock(orphanOrder[0])
+	}
+	orphanBlocks[key] = append(orphanBlocks[key], block)
+	orphanOrder = append(orphanOrder, block)
+}
+
+// removeOrphanBlock 从缓存中删除指定的孤块，调用者需要持有 orphanMutex
+func removeOrphanBlock(block *core.Block) {
+	key := hex.EncodeToString(block.PrevBlockHash)
+	var siblings []*core.Block
+	for _, orphan := range orphanBlocks[key] {
+		if orphan != block {
+			siblings = append(siblings, orphan)
+		}
+	}
+	if len(siblings) == 0 {
+		delete(orphanBlocks, key)
+	} else {
+		orphanBlocks[key] = siblings
+	}
+	for i, orphan := range orphanOrder {
+		if orphan == block {
+			orphanOrder = append(orphanOrder[:i], orphanOrder[i+1:]...)
+			break
+		}
+	}
+}
+
+// takeOrphanBlocks 取出所有以指定区块为父区块的孤块
+func takeOrphanBlocks(parentHash []byte) []*core.Block {
+	orphanMutex.Lock()
+	defer orphanMutex.Unlock()
+	blocks := orphanBlocks[hex.EncodeToString(parentHash)]
+	for _, block := range blocks {
+		removeOrphanBlock(block)
+	}
+	return blocks
+}
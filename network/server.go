@@ -3,22 +3,31 @@ package network
 import (
 	"bkc/core"
 	"bkc/utils"
+	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 )
 
 // 网络服务文件管理
 
-// 3000 作为引导节点（主节点）的地址
-var knownNodes = []string{"localhost:3000"}
+// 已知节点地址，第一个为引导节点（主节点），它的端口由链参数决定
+var knownNodes []string
+
+// knownNodesMutex 保护 knownNodes，请求在不同的 goroutine 中处理
+var knownNodesMutex sync.Mutex
 
 // 节点地址
 var nodeAddress string
 
-// StartServer 启动服务
-func StartServer(nodeId string) {
+// StartServer 启动服务，minerAddress 不为空时同时使用 workers 个线程持续挖矿
+func StartServer(nodeId string, minerAddress string, workers int) {
 	fmt.Printf("启动服务[%s]...\n", nodeId)
 	// 节点地址赋值
 	nodeAddress = fmt.Sprintf("localhost:%s", nodeId)
@@ -27,19 +36,50 @@ func StartServer(nodeId string) {
 	if nil != err {
 		log.Panicf("listen address of %s failed! %v\n", nodeAddress, err)
 	}
-	defer listen.Close()
 	// 获取 blockchain 对象
 	bc := core.BlockchainObject(nodeId)
+	defer bc.DB.Close()
+	// 链参数从数据库中读取之后才能确定引导节点
+	knownNodesMutex.Lock()
+	knownNodes = append([]string{}, core.ActiveParams.SeedNodes()...)
+	knownNodesMutex.Unlock()
+	// 收到退出信号时停止挖矿并关闭监听
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		listen.Close()
+	}()
 
 	// 两个节点：主节点负责保存数据，钱包节点负责发送请求，同步数据
 	if nodeAddress != knownNodes[0] {
 		// 不是主节点，发送请求，同步数据
 		sendVersion(knownNodes[0], bc)
 	}
+	// 命令行在节点停止时提交到交易池的交易，由节点启动之后广播
+	for _, node := range peers() {
+		announceMempool(node, bc)
+	}
+	// 启动挖矿
+	minerDone := make(chan struct{})
+	if "" != minerAddress {
+		go func() {
+			startMiner(ctx, bc, minerAddress, workers)
+			close(minerDone)
+		}()
+	} else {
+		close(minerDone)
+	}
 
 	for {
 		// 2. 生成连接，接收请求
 		conn, err := listen.Accept()
+		if nil != ctx.Err() {
+			// 等待挖矿结束之后再关闭数据库
+			<-minerDone
+			fmt.Printf("停止服务[%s]...\n", nodeId)
+			return
+		}
 		if nil != err {
 			log.Panicf("accept connect failed! %v\n", err)
 		}
@@ -56,19 +96,29 @@ func handleConnection(conn net.Conn, bc *core.BlockChain) {
 	if nil != err {
 		log.Panicf("Receive a Request failed! %v\n", err)
 	}
-	cmd := utils.BytesToCommand(request[:12])
+	// 忽略其他网络的消息
+	magic := core.ActiveParams.Magic
+	if len(request) < len(magic)+COMMAND_LENGTH || !bytes.Equal(request[:len(magic)], magic[:]) {
+		fmt.Printf("Receive a Request from another network from [%s]\n", conn.RemoteAddr())
+		return
+	}
+	request = request[len(magic):]
+	cmd := utils.BytesToCommand(request[:COMMAND_LENGTH])
+	payload := request[COMMAND_LENGTH:]
 	fmt.Printf("Receive a Command: %s\n", cmd)
 	switch cmd {
 	case CMD_VERSION:
-		handleVersion(request, bc)
+		handleVersion(payload, bc)
 	case CMD_GETDATA:
-		handleGetData(request, bc)
+		handleGetData(payload, bc)
 	case CMD_GETBLOCKS:
-		handleGetBlocks(request, bc)
+		handleGetBlocks(payload, bc)
 	case CMD_INV:
-		handleInv(request, bc)
+		handleInv(payload, bc)
 	case CMD_BLOCK:
-		handleBlock(request, bc)
+		handleBlock(payload, bc)
+	case CMD_TX:
+		handleTx(payload, bc)
 	default:
 		fmt.Println("Unknown command")
 	}
@@ -82,3 +132,29 @@ func CommandToBytes(command string) []byte {
 	}
 	return bytes[:]
 }
+
+// addKnownNode 记录新的节点地址，返回是否是之前未知的节点
+func addKnownNode(address string) bool {
+	knownNodesMutex.Lock()
+	defer knownNodesMutex.Unlock()
+	for _, node := range knownNodes {
+		if node == address {
+			return false
+		}
+	}
+	knownNodes = append(knownNodes, address)
+	return true
+}
+
+// peers 获取除当前节点之外的所有已知节点
+func peers() []string {
+	knownNodesMutex.Lock()
+	defer knownNodesMutex.Unlock()
+	var nodes []string
+	for _, node := range knownNodes {
+		if node != nodeAddress {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
@@ -3,4 +3,14 @@ package network
 type BlockData struct {
 	AddrFrom 	string		// 节点地址
 	Block		[]byte		// 区块数据（序列化数据）
-}
\ No newline at end of file
+}
+
+func (b *BlockData) write(w *messageWriter) {
+	w.writeBytes([]byte(b.AddrFrom))
+	w.writeBytes(b.Block)
+}
+
+func (b *BlockData) read(r *messageReader) {
+	b.AddrFrom = string(r.readBytes())
+	b.Block = r.readBytes()
+}
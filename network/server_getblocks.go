@@ -2,4 +2,12 @@ package network
 
 type GetBlocks struct {
 	AddrFrom	string		// 从哪一个节点开始同步
-}
\ No newline at end of file
+}
+
+func (g *GetBlocks) write(w *messageWriter) {
+	w.writeBytes([]byte(g.AddrFrom))
+}
+
+func (g *GetBlocks) read(r *messageReader) {
+	g.AddrFrom = string(r.readBytes())
+}
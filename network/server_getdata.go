@@ -4,4 +4,14 @@ package network
 type GetData struct {
 	AddrFrom	string		// 当前地址
 	ID			[]byte		// 区块哈希
-}
\ No newline at end of file
+}
+
+func (g *GetData) write(w *messageWriter) {
+	w.writeBytes([]byte(g.AddrFrom))
+	w.writeBytes(g.ID)
+}
+
+func (g *GetData) read(r *messageReader) {
+	g.AddrFrom = string(r.readBytes())
+	g.ID = r.readBytes()
+}
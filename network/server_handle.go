@@ -2,26 +2,28 @@ package network
 
 import (
 	"bkc/core"
-	"bytes"
-	"encoding/gob"
 	"fmt"
-	"log"
 )
 
 // 请求处理文件管理
 
 // handleVersion version
-func handleVersion(request []byte, bc *core.BlockChain) {
+func handleVersion(payload []byte, bc *core.BlockChain) {
 	fmt.Println("the request of version handle...")
-	var buffer bytes.Buffer
 	var data Version
-	// 1. 解析请求
-	dataBytes := request[12:]
-	// 2. 生成 version 结构
-	buffer.Write(dataBytes)
-	decoder := gob.NewDecoder(&buffer)
-	if err := decoder.Decode(&data); nil != err {
-		log.Panicf("decode the version struct failed! %v\n", err)
+	// 1. 解析请求，无效的消息直接丢弃
+	if err := decodeMessage(payload, &data); nil != err {
+		fmt.Printf("drop the version message: %v\n", err)
+		return
+	}
+	// 记录请求方，新区块与新交易也会发送给它，新节点还会收到交易池中已有的交易
+	if addKnownNode(data.AddrFrom) {
+		announceMempool(data.AddrFrom, bc)
+	}
+	// 记录请求方的时间，调整网络时间
+	if data.Timestamp > 0 {
+		bc.TimeSource.AddTimeSample(data.AddrFrom, data.Timestamp)
+		fmt.Printf("the network time offset : %ds\n", bc.TimeSource.Offset())
 	}
 	// 3. 获取请求方的区块高度
 	versionHeight := data.Height
@@ -38,17 +40,13 @@ func handleVersion(request []byte, bc *core.BlockChain) {
 }
 
 // handleGetBlocks 数据同步请求处理
-func handleGetBlocks(request []byte, bc *core.BlockChain) {
+func handleGetBlocks(payload []byte, bc *core.BlockChain) {
 	fmt.Println("the request of get blocks handle...")
-	var buffer bytes.Buffer
 	var data GetBlocks
-	// 1. 解析请求
-	dataBytes := request[12:]
-	// 2. 生成 getblocks 结构
-	buffer.Write(dataBytes)
-	decoder := gob.NewDecoder(&buffer)
-	if err := decoder.Decode(&data); nil != err {
-		log.Panicf("decode the getblocks struct failed! %v\n", err)
+	// 1. 解析请求，无效的消息直接丢弃
+	if err := decodeMessage(payload, &data); nil != err {
+		fmt.Printf("drop the getblocks message: %v\n", err)
+		return
 	}
 	// 3. 获取区块链所有区块的哈希
 	hashes := bc.GetBlockHashes()
@@ -57,35 +55,28 @@ func handleGetBlocks(request []byte, bc *core.BlockChain) {
 }
 
 // handleInv
-func handleInv(request []byte, bc *core.BlockChain) {
+func handleInv(payload []byte, bc *core.BlockChain) {
 	fmt.Println("the request of inv handle...")
-	var buffer bytes.Buffer
 	var data Inv
-	// 1. 解析请求
-	dataBytes := request[12:]
-	// 2. 生成 inv 结构
-	buffer.Write(dataBytes)
-	decoder := gob.NewDecoder(&buffer)
-	if err := decoder.Decode(&data); nil != err {
-		log.Panicf("decode the inv struct failed! %v\n", err)
+	// 1. 解析请求，无效的消息直接丢弃
+	if err := decodeMessage(payload, &data); nil != err {
+		fmt.Printf("drop the inv message: %v\n", err)
+		return
 	}
-	for _, hash := range data.Hashes {
-		sendGetData(data.AddrFrom, hash)
+	// 区块哈希列表从最新区块开始排列，倒序请求，使父区块先于子区块到达
+	for i := len(data.Hashes) - 1; i >= 0; i-- {
+		sendGetData(data.AddrFrom, data.Hashes[i])
 	}
 }
 
 // handleGetData 处理获取指定区块的请求
-func handleGetData(request []byte, bc *core.BlockChain) {
+func handleGetData(payload []byte, bc *core.BlockChain) {
 	fmt.Println("the request of get block handle...")
-	var buffer bytes.Buffer
 	var data GetData
-	// 1. 解析请求
-	dataBytes := request[12:]
-	// 2. 生成 getData 结构
-	buffer.Write(dataBytes)
-	decoder := gob.NewDecoder(&buffer)
-	if err := decoder.Decode(&data); nil != err {
-		log.Panicf("decode the getData struct failed! %v\n", err)
+	// 1. 解析请求，无效的消息直接丢弃
+	if err := decodeMessage(payload, &data); nil != err {
+		fmt.Printf("drop the getdata message: %v\n", err)
+		return
 	}
 	// 3. 通过传过来的区块哈希，获取本地节点的区块
 	blockBytes := bc.GetBlock(data.ID)
@@ -93,23 +84,69 @@ func handleGetData(request []byte, bc *core.BlockChain) {
 }
 
 // handleBlock 接收到新区块时，进行处理
-func handleBlock(request []byte, bc *core.BlockChain) {
+func handleBlock(payload []byte, bc *core.BlockChain) {
 	fmt.Println("the request of handle block handle...")
-	var buffer bytes.Buffer
 	var data BlockData
-	// 1. 解析请求
-	dataBytes := request[12:]
-	// 2. 生成 getData 结构
-	buffer.Write(dataBytes)
-	decoder := gob.NewDecoder(&buffer)
-	if err := decoder.Decode(&data); nil != err {
-		log.Panicf("decode the blockData struct failed! %v\n", err)
+	// 1. 解析请求，无效的消息直接丢弃
+	if err := decodeMessage(payload, &data); nil != err {
+		fmt.Printf("drop the block message: %v\n", err)
+		return
+	}
+	// 3. 验证并将接收到的区块添加到区块链中（UTXO 在添加时同步更新）
+	block, err := core.DecodeBlock(data.Block)
+	if nil != err {
+		fmt.Printf("drop the block from [%s]: %v\n", data.AddrFrom, err)
+		return
+	}
+	processBlock(block, bc)
+}
+
+// processBlock 添加区块，父区块未到达的区块先作为孤块缓存，
+// 区块添加成功之后，继续处理等待该区块的孤块
+func processBlock(block *core.Block, bc *core.BlockChain) {
+	blocks := []*core.Block{block}
+	for len(blocks) > 0 {
+		block, blocks = blocks[0], blocks[1:]
+		err := bc.AddBlock(block)
+		if nil != err {
+			if core.IsRejectReason(err, core.RejectOrphan) {
+				addOrphanBlock(block)
+			}
+			if !core.IsRejectReason(err, core.RejectDuplicate) {
+				fmt.Printf("%v\n", err)
+			}
+			continue
+		}
+		blocks = append(blocks, takeOrphanBlocks(block.Hash)...)
 	}
-	// 3. 将接收到的区块添加到区块链中
-	blockBytes := data.Block
-	block := core.Deserialize(blockBytes)
-	bc.AddBlock(block)
-	// 4. 更新 UTXO
-	utxoSet := core.UTXOSet{bc}
-	utxoSet.Update()
-}
\ No newline at end of file
+}
+// handleTx 接收到新交易时，验证并加入交易池，然后转发给其他已知节点
+func handleTx(payload []byte, bc *core.BlockChain) {
+	fmt.Println("the request of handle tx handle...")
+	var data TxData
+	// 1. 解析请求，无效的消息直接丢弃
+	if err := decodeMessage(payload, &data); nil != err {
+		fmt.Printf("drop the tx message: %v\n", err)
+		return
+	}
+	tx, err := core.DecodeTransaction(data.Transaction)
+	if nil != err {
+		fmt.Printf("drop the tx from [%s]: %v\n", data.AddrFrom, err)
+		return
+	}
+	// 2. 加入交易池，已经存在或者无效的交易不再转发
+	mempool := &core.Mempool{Blockchain: bc}
+	if err := mempool.AcceptTransaction(tx); nil != err {
+		if !core.IsRejectReason(err, core.RejectDuplicate) {
+			fmt.Printf("%v\n", err)
+		}
+		return
+	}
+	fmt.Printf("the tx [%x] is added to the mempool\n", tx.TxHash)
+	// 3. 转发
+	for _, node := range peers() {
+		if node != data.AddrFrom {
+			sendTx(node, data.Transaction)
+		}
+	}
+}
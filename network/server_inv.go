@@ -4,4 +4,19 @@ package network
 type Inv struct {
 	AddrFrom	string		// 当前节点的地址
 	Hashes		[][]byte	// 当前节点所拥有的区块的 Hash 列表
-}
\ No newline at end of file
+}
+
+func (inv *Inv) write(w *messageWriter) {
+	w.writeBytes([]byte(inv.AddrFrom))
+	w.writeUint32(uint32(len(inv.Hashes)))
+	for _, hash := range inv.Hashes {
+		w.writeBytes(hash)
+	}
+}
+
+func (inv *Inv) read(r *messageReader) {
+	inv.AddrFrom = string(r.readBytes())
+	for i, n := 0, r.readCount(4); i < n; i++ {
+		inv.Hashes = append(inv.Hashes, r.readBytes())
+	}
+}
@@ -2,23 +2,27 @@ package network
 
 import (
 	"bkc/core"
-	"bkc/utils"
 	"bytes"
+	"fmt"
 	"io"
 	"log"
 	"net"
+	"time"
 )
 
-// sendMessage 发送请求
+// sendMessage 发送请求，请求的开头为当前链参数的网络魔数
 func sendMessage(to string, message []byte) {
 	// 1. 连接上服务器
 	conn, err := net.Dial(PROTOCOL, to)
 	if nil != err {
-		log.Panicf("connect to server [%s] failed! %v\n", to, err)
+		// 对方节点不在线时不影响当前节点（例如挖矿节点广播新区块）
+		fmt.Printf("connect to server [%s] failed! %v\n", to, err)
+		return
 	}
 	defer conn.Close()
 	// 要发送的数据
-	_, err = io.Copy(conn, bytes.NewReader(message))
+	magic := core.ActiveParams.Magic
+	_, err = io.Copy(conn, bytes.NewReader(append(magic[:], message...)))
 	if nil != err {
 		log.Panicf("add the data to conn failed! %v", err)
 	}
@@ -29,9 +33,9 @@ func sendVersion(toAddress string, bc *core.BlockChain) {
 	// 1. 获取当前节点的区块高度
 	height := bc.GetHeight()
 	// 2. 组装生成 version
-	versionData := Version{Height: int(height), AddrFrom: nodeAddress}
+	versionData := Version{Height: int(height), AddrFrom: nodeAddress, Timestamp: time.Now().Unix()}
 	// 3. 组装成要发送的请求
-	data := utils.GobEncode(versionData)
+	data := encodeMessage(&versionData)
 	// 4. 将命令与版本组装成完整的请求
 	request := append(CommandToBytes(CMD_VERSION), data...)
 	// 5. 发送请求
@@ -41,7 +45,7 @@ func sendVersion(toAddress string, bc *core.BlockChain) {
 // sendGetBlocks 从指定节点同步数据
 func sendGetBlocks(toAddress string) {
 	// 1. 生成数据
-	data := utils.GobEncode(GetBlocks{AddrFrom: nodeAddress})
+	data := encodeMessage(&GetBlocks{AddrFrom: nodeAddress})
 	// 2. 组装请求
 	request := append(CommandToBytes(CMD_GETBLOCKS), data...)
 	// 3. 发送请求
@@ -51,7 +55,7 @@ func sendGetBlocks(toAddress string) {
 // sendGetData 发送获取指定节点请求
 func sendGetData(toAddress string, hash []byte) {
 	// 1. 生成数据
-	data := utils.GobEncode(GetData{AddrFrom: nodeAddress, ID: hash})
+	data := encodeMessage(&GetData{AddrFrom: nodeAddress, ID: hash})
 	// 2. 组装请求
 	request := append(CommandToBytes(CMD_GETDATA), data...)
 	// 3. 发送请求
@@ -61,7 +65,7 @@ func sendGetData(toAddress string, hash []byte) {
 // sendInv 向其他节点展示
 func sendInv(toAddress string, hashes [][]byte) {
 	// 1. 生成数据
-	data := utils.GobEncode(Inv{AddrFrom: nodeAddress, Hashes: hashes})
+	data := encodeMessage(&Inv{AddrFrom: nodeAddress, Hashes: hashes})
 	// 2. 组装请求
 	request := append(CommandToBytes(CMD_INV), data...)
 	// 3. 发送请求
@@ -71,9 +75,25 @@ func sendInv(toAddress string, hashes [][]byte) {
 // sendBlock 发送区块信息
 func sendBlock(toAddress string, block []byte)  {
 	// 1. 生成数据
-	data := utils.GobEncode(BlockData{AddrFrom: nodeAddress, Block: block})
+	data := encodeMessage(&BlockData{AddrFrom: nodeAddress, Block: block})
 	// 2. 组装请求
 	request := append(CommandToBytes(CMD_BLOCK), data...)
 	// 3. 发送请求
 	sendMessage(toAddress, request)
-}
\ No newline at end of file
+}
+// sendTx 发送交易信息
+func sendTx(toAddress string, tx []byte) {
+	// 1. 生成数据
+	data := encodeMessage(&TxData{AddrFrom: nodeAddress, Transaction: tx})
+	// 2. 组装请求
+	request := append(CommandToBytes(CMD_TX), data...)
+	// 3. 发送请求
+	sendMessage(toAddress, request)
+}
+
+// announceMempool 把交易池中的所有交易发送给指定节点（被依赖的交易先发送）
+func announceMempool(toAddress string, bc *core.BlockChain) {
+	for _, tx := range (&core.Mempool{Blockchain: bc}).Transactions() {
+		sendTx(toAddress, tx.Serialize())
+	}
+}
@@ -0,0 +1,17 @@
+package network
+
+// TxData 交易数据
+type TxData struct {
+	AddrFrom	string		// 节点地址
+	Transaction	[]byte		// 交易数据（规范编码）
+}
+
+func (t *TxData) write(w *messageWriter) {
+	w.writeBytes([]byte(t.AddrFrom))
+	w.writeBytes(t.Transaction)
+}
+
+func (t *TxData) read(r *messageReader) {
+	t.AddrFrom = string(r.readBytes())
+	t.Transaction = r.readBytes()
+}
@@ -5,4 +5,17 @@ type Version struct {
 	// Version		int		// 版本号
 	Height		int		// 当前节点的区块高度
 	AddrFrom	string	// 当前节点的地址
-}
\ No newline at end of file
+	Timestamp	int64	// 当前节点的时间（Unix 时间戳），用于计算网络调整时间
+}
+
+func (v *Version) write(w *messageWriter) {
+	w.writeInt64(int64(v.Height))
+	w.writeBytes([]byte(v.AddrFrom))
+	w.writeInt64(v.Timestamp)
+}
+
+func (v *Version) read(r *messageReader) {
+	v.Height = int(r.readInt64())
+	v.AddrFrom = string(r.readBytes())
+	v.Timestamp = r.readInt64()
+}
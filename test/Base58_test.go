@@ -0,0 +1,72 @@
+package test
+
+import (
+	"bkc/core"
+	"bkc/utils"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestBase58_Vectors(t *testing.T) {
+	for _, vector := range []struct {
+		hex     string
+		encoded string
+	}{
+		{"", ""},
+		{"00", "1"},
+		{"0000287fb4cd", "11233QC4"},
+		{"48656c6c6f20576f726c6421", "2NEpo7TZRRrLZSi2U"},
+		{"00eb15231dfceb60925886b67d065299925915aeb172c06647", "1NS17iag9jJgTHD1VXjvLCEnZuQ3rJDE9L"},
+	} {
+		input, _ := hex.DecodeString(vector.hex)
+		if encoded := string(utils.Base58Encode(input)); encoded != vector.encoded {
+			t.Fatalf("encode %s: got %s, expected %s", vector.hex, encoded, vector.encoded)
+		}
+		decoded, err := utils.Base58Decode([]byte(vector.encoded))
+		if nil != err || hex.EncodeToString(decoded) != vector.hex {
+			t.Fatalf("decode %s: got %x, %v", vector.encoded, decoded, err)
+		}
+	}
+	if _, err := utils.Base58Decode([]byte("1O0l")); !errors.Is(err, utils.ErrInvalidBase58) {
+		t.Fatalf("the invalid characters should fail: %v", err)
+	}
+}
+
+func TestBase58_Address(t *testing.T) {
+	defer core.SelectNetwork(core.MainNet)
+	hash160, _ := hex.DecodeString("010966776006953d5567439e5e39f86a0d273bee")
+	address := utils.Base58CheckEncode(0x00, hash160)
+	if address != "16UwLL9Risc3QfPqBUvKofHmBQ7wMtjvM" {
+		t.Fatalf("the address is %s", address)
+	}
+	if !core.IsValidForAddress([]byte(address)) {
+		t.Fatalf("the address [%s] should be valid", address)
+	}
+	for _, invalid := range []struct {
+		address string
+		err     error
+	}{
+		{"", utils.ErrInvalidFormat},
+		{"16UwLL9Risc3QfPqBUvKofHmBQ7wMtjvO", utils.ErrInvalidBase58},
+		{"16UwLL9Risc3QfPqBUvKofHmBQ7wMtjvN", utils.ErrChecksum},
+		{utils.Base58CheckEncode(0x00, hash160[:19]), core.ErrAddressLength},
+		{utils.Base58CheckEncode(0x6f, hash160), core.ErrAddressNetwork},
+	} {
+		_, _, err := core.DecodeAddress(invalid.address)
+		var addressErr *core.AddressError
+		if !errors.As(err, &addressErr) || !errors.Is(err, invalid.err) {
+			t.Fatalf("decode [%s]: got %v, expected %v", invalid.address, err, invalid.err)
+		}
+	}
+	// 测试网使用自己的版本前缀
+	if err := core.SelectNetwork(core.TestNet); nil != err {
+		t.Fatalf("select the testnet failed: %v", err)
+	}
+	if _, _, err := core.DecodeAddress(address); !errors.Is(err, core.ErrAddressNetwork) {
+		t.Fatalf("the mainnet address should be invalid on the testnet: %v", err)
+	}
+	if testnet := utils.Base58CheckEncode(0x6f, hash160); testnet[0] != 'm' && testnet[0] != 'n' {
+		t.Fatalf("the testnet address [%s] should start with m or n", testnet)
+	}
+}
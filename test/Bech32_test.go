@@ -0,0 +1,89 @@
+package test
+
+import (
+	"bkc/core"
+	"bkc/utils"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestBech32_Vectors(t *testing.T) {
+	for _, valid := range []string{
+		"A12UEL5L",
+		"a12uel5l",
+		"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw",
+		"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w",
+		"?1ezyfcl",
+	} {
+		hrp, data, err := utils.Bech32Decode(valid)
+		if nil != err {
+			t.Fatalf("decode [%s] failed: %v", valid, err)
+		}
+		encoded, err := utils.Bech32Encode(hrp, data)
+		if nil != err || encoded != strings.ToLower(valid) {
+			t.Fatalf("encode [%s]: got %s, %v", valid, encoded, err)
+		}
+	}
+	for _, invalid := range []struct {
+		input string
+		err   error
+	}{
+		{"pzry9x0s0muk", utils.ErrInvalidBech32},
+		{"1pzry9x0s0muk", utils.ErrInvalidBech32},
+		{"x1b4n0q5v", utils.ErrInvalidBech32},
+		{"li1dgmt3", utils.ErrInvalidBech32},
+		{"A1G7SGD8", utils.ErrBech32Checksum},
+		{"a12UEL5L", utils.ErrInvalidBech32},
+	} {
+		if _, _, err := utils.Bech32Decode(invalid.input); !errors.Is(err, invalid.err) {
+			t.Fatalf("decode [%s]: got %v, expected %v", invalid.input, err, invalid.err)
+		}
+	}
+}
+
+func TestBech32_Address(t *testing.T) {
+	defer core.SelectNetwork(core.MainNet)
+	wallet := core.NewWallet()
+	base58Address, bech32Address := string(wallet.GetAddress()), string(wallet.GetBech32Address())
+	if !strings.HasPrefix(bech32Address, core.ActiveParams.Bech32HRP+"1") {
+		t.Fatalf("the bech32 address [%s] has no prefix %s", bech32Address, core.ActiveParams.Bech32HRP)
+	}
+	// 两种形式对应同一个 hash160 与锁定脚本
+	for _, address := range []string{bech32Address, strings.ToUpper(bech32Address)} {
+		if !bytes.Equal(core.StringToHash160(address), core.Ripemd160Hash(wallet.PublicKey)) {
+			t.Fatalf("the hash160 of [%s] does not match", address)
+		}
+		if !bytes.Equal(core.NewTxOutput(1, address).ScriptPubKey, core.NewTxOutput(1, base58Address).ScriptPubKey) {
+			t.Fatalf("the outputs of [%s] and [%s] differ", address, base58Address)
+		}
+	}
+	if converted, err := core.Bech32Address(base58Address); nil != err || converted != bech32Address {
+		t.Fatalf("convert [%s]: got %s, %v", base58Address, converted, err)
+	}
+	wallets := &core.Wallets{Wallets: map[string]*core.Wallet{base58Address: wallet}}
+	if wallets.WalletForAddress(bech32Address) != wallet {
+		t.Fatalf("the wallet of [%s] is not found", bech32Address)
+	}
+	// 脚本哈希地址
+	script := []byte{core.OP_1}
+	scriptAddress, err := core.Bech32Address(core.ScriptHashAddress(script))
+	if nil != err || !core.IsScriptHashAddress(scriptAddress) {
+		t.Fatalf("the bech32 script hash address [%s] is invalid: %v", scriptAddress, err)
+	}
+	// 校验和错误与其他网络的地址
+	corrupted := bech32Address[:len(bech32Address)-1] + "q"
+	if corrupted == bech32Address {
+		corrupted = bech32Address[:len(bech32Address)-1] + "p"
+	}
+	if _, _, err := core.DecodeAddress(corrupted); !errors.Is(err, utils.ErrBech32Checksum) {
+		t.Fatalf("the corrupted address should fail: %v", err)
+	}
+	if err := core.SelectNetwork(core.TestNet); nil != err {
+		t.Fatalf("select the testnet failed: %v", err)
+	}
+	if _, _, err := core.DecodeAddress(bech32Address); !errors.Is(err, core.ErrAddressNetwork) {
+		t.Fatalf("the mainnet address should be invalid on the testnet: %v", err)
+	}
+}
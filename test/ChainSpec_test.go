@@ -0,0 +1,82 @@
+package test
+
+import (
+	"bkc/core"
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestChainSpec_Genesis(t *testing.T) {
+	defer func(params *core.ChainParams) { core.ActiveParams = params }(core.ActiveParams)
+	first, second := string(core.NewWallet().GetAddress()), string(core.NewWallet().GetAddress())
+	spec := &core.ChainSpec{
+		Name:           "spectest",
+		Magic:          "0b110907",
+		Port:           4000,
+		InitialSubsidy: 20,
+		Genesis: core.GenesisSpec{
+			Timestamp:   1700000000,
+			Bits:        "1f010000",
+			Allocations: []core.Allocation{{Address: first, Amount: 100}, {Address: second, Amount: 50}},
+		},
+	}
+	params, err := spec.Params()
+	if nil != err {
+		t.Fatalf("the chain spec is invalid: %v", err)
+	}
+	if params.Magic != [4]byte{0x0b, 0x11, 0x09, 0x07} || params.DefaultPort != 4000 || params.InitialSubsidy != 20 ||
+		params.GenesisBits != 0x1f010000 || params.MaxSupply != core.DefaultParams.MaxSupply {
+		t.Fatalf("the chain params do not match the chain spec: %+v", params)
+	}
+	// 不同节点按照同一个链规格生成的创世区块完全相同
+	var hashes [][]byte
+	for _, nodeId := range []string{"spectest1", "spectest2"} {
+		bc, err := core.CreateBlockChainFromSpec(spec, nodeId)
+		if nil != err {
+			t.Fatalf("create the blockchain from the chain spec failed: %v", err)
+		}
+		(&core.UTXOSet{Blockchain: bc}).ResetUTXOSet()
+		hashes = append(hashes, bc.TipHash())
+		bc.DB.Close()
+		defer os.Remove(fmt.Sprintf(core.DBName, nodeId))
+	}
+	if !bytes.Equal(hashes[0], hashes[1]) {
+		t.Fatalf("the genesis blocks differ: %x %x", hashes[0], hashes[1])
+	}
+	// 重新打开区块链时使用链规格中的链参数
+	core.ActiveParams = &core.DefaultParams
+	bc := core.BlockchainObject("spectest1")
+	defer bc.DB.Close()
+	if core.ActiveParams.InitialSubsidy != 20 {
+		t.Fatalf("the chain params are not loaded from the db")
+	}
+	utxoSet := &core.UTXOSet{Blockchain: bc}
+	for address, amount := range map[string]int{first: 100, second: 50} {
+		if balance := utxoSet.GetBalance(address); balance != amount {
+			t.Fatalf("the balance of [%s] is %d, expected %d", address, balance, amount)
+		}
+	}
+	// 链规格中给出的创世区块哈希必须一致
+	params.Genesis.Hash = fmt.Sprintf("%x", hashes[0])
+	if _, err := core.GenesisBlock(params); nil != err {
+		t.Fatalf("the genesis hash should match: %v", err)
+	}
+	params.Genesis.Timestamp++
+	if _, err := core.GenesisBlock(params); nil == err {
+		t.Fatalf("the genesis block with another timestamp should not match the hash")
+	}
+}
+
+func TestChainSpec_InvalidRetarget(t *testing.T) {
+	// 调整周期只有 1 个区块时期望用时为 0，无法计算新的难度
+	spec := &core.ChainSpec{RetargetInterval: 1}
+	if _, err := spec.Params(); nil == err {
+		t.Fatalf("the retarget interval 1 should be rejected")
+	}
+	spec = &core.ChainSpec{RetargetInterval: 2, TargetBlockTime: -1}
+	if _, err := spec.Params(); nil == err {
+		t.Fatalf("the negative target block time should be rejected")
+	}
+}
@@ -0,0 +1,77 @@
+package test
+
+import (
+	"bkc/core"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestConsensus_ProofOfAuthority(t *testing.T) {
+	nodeId := "poatest"
+	defer os.Remove(fmt.Sprintf("Wallets_%s.dat", nodeId))
+	defer func(params *core.ChainParams) { core.ActiveParams = params }(core.ActiveParams)
+	// 本节点持有第 1、3 个签名者的私钥，第 2 个签名者在其他节点上
+	local := []*core.Wallet{scriptTestWallet(), scriptTestWallet()}
+	remote := scriptTestWallet()
+	wallets := &core.Wallets{Wallets: map[string]*core.Wallet{}, Scripts: map[string][]byte{}}
+	for _, wallet := range local {
+		wallets.Wallets[string(wallet.GetAddress())] = wallet
+	}
+	wallets.SaveWallets(nodeId)
+	params := *core.ActiveParams
+	params.Consensus = core.ConsensusPoA
+	params.Signers = [][]byte{local[0].PublicKey, remote.PublicKey, local[1].PublicKey}
+	params.TargetBlockTime = 1
+	if err := core.CheckConsensusParams(params.Consensus, params.Signers); nil != err {
+		t.Fatalf("the consensus params are invalid: %v", err)
+	}
+	core.ActiveParams = &params
+	miner := string(local[0].GetAddress())
+	bc := setupBlockChain(t, nodeId, miner)
+
+	// 高度 2、3 分别由第 3、1 个签名者签名
+	for i := 0; i < 2; i++ {
+		block, err := bc.MineBlock(context.Background(), miner, 0, nil)
+		if nil != err {
+			t.Fatalf("seal the block failed: %v", err)
+		}
+		if prev := bc.GetHeader(block.PrevBlockHash); block.TimeStamp < prev.TimeStamp+params.TargetBlockTime {
+			t.Fatalf("the block at height %d is sealed too early", block.Height)
+		}
+	}
+	// 高度 4 轮到其他节点上的签名者
+	if _, err := bc.MineBlock(context.Background(), miner, 0, nil); !errors.Is(err, core.ErrNotInTurn) {
+		t.Fatalf("the block at height 4 should not be sealed locally, got %v", err)
+	}
+	// 不在轮次上的签名者签名的区块
+	block, err := bc.NewBlockTemplate(miner)
+	if nil != err {
+		t.Fatalf("create the block template failed: %v", err)
+	}
+	block.TimeStamp += params.TargetBlockTime
+	block.Version = 2
+	r, s, err := ecdsa.Sign(rand.Reader, &local[0].PrivateKey, block.SealHash())
+	if nil != err {
+		t.Fatalf("sign the block failed: %v", err)
+	}
+	block.Signature = make([]byte, 64)
+	r.FillBytes(block.Signature[:32])
+	s.FillBytes(block.Signature[32:])
+	block.Hash = block.BlockHeader.Hash()
+	if err := bc.AddBlock(block); !core.IsRejectReason(err, core.RejectBadSeal) {
+		t.Fatalf("the block signed out of turn should be rejected, got %v", err)
+	}
+	// 区块头编码包含封装签名
+	header, err := core.DecodeHeader(block.BlockHeader.Serialize())
+	if nil != err || string(header.Hash()) != string(block.Hash) {
+		t.Fatalf("the sealed header does not round trip: %v", err)
+	}
+	if height := bc.GetHeight(); height != 3 {
+		t.Fatalf("the height is %d, expected 3", height)
+	}
+}
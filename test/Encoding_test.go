@@ -0,0 +1,83 @@
+package test
+
+import (
+	"bkc/core"
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestEncoding_BlockRoundTrip(t *testing.T) {
+	coinbase := &core.Transaction{
+		Version: core.TxVersion,
+		Vins:    []*core.TxInput{{TxHash: []byte{}, Vout: -1, ScriptSig: []byte{2, 0, 0, 0, 0, 0, 0, 0}}},
+		Vouts:   []*core.TxOutput{{Value: 10, ScriptPubKey: core.PayToPubKeyHashScript(bytes.Repeat([]byte{0xaa}, 20))}},
+	}
+	coinbase.HashTransaction()
+	transfer := &core.Transaction{
+		Version: core.TxVersion,
+		Vins:    []*core.TxInput{{TxHash: coinbase.TxHash, Vout: 0, ScriptSig: []byte{1, 2, 3}}},
+		Vouts:   []*core.TxOutput{{Value: 7, ScriptPubKey: core.PayToPubKeyHashScript(bytes.Repeat([]byte{0xbb}, 20))}},
+	}
+	transfer.HashTransaction()
+	block := &core.Block{
+		BlockHeader: core.BlockHeader{
+			Version:       1,
+			PrevBlockHash: bytes.Repeat([]byte{0x11}, 32),
+			TimeStamp:     1600000000,
+			Bits:          0x207fffff,
+			Nonce:         42,
+			Height:        2,
+		},
+		Txs: []*core.Transaction{coinbase, transfer},
+	}
+	block.MerkleRoot = block.HashTransaction()
+	block.Hash = block.BlockHeader.Hash()
+
+	data, err := core.EncodeBlock(block)
+	if nil != err {
+		t.Fatalf("encode the block failed: %v", err)
+	}
+	decoded, err := core.DecodeBlock(data)
+	if nil != err {
+		t.Fatalf("decode the block failed: %v", err)
+	}
+	if !bytes.Equal(decoded.Hash, block.Hash) || !bytes.Equal(decoded.MerkleRoot, block.MerkleRoot) {
+		t.Fatalf("the decoded block hash %x does not match %x", decoded.Hash, block.Hash)
+	}
+	for i, tx := range decoded.Txs {
+		if !bytes.Equal(tx.TxHash, block.Txs[i].TxHash) {
+			t.Fatalf("the decoded tx %d hash %x does not match %x", i, tx.TxHash, block.Txs[i].TxHash)
+		}
+	}
+	if decoded.Txs[0].Vins[0].Vout != -1 || decoded.Txs[1].Vouts[0].Value != 7 {
+		t.Fatalf("the decoded transactions are different")
+	}
+	// 被截断或者带有多余数据的编码都是无效的
+	for _, bad := range [][]byte{data[:len(data)-1], append(append([]byte{}, data...), 0)} {
+		if _, err := core.DecodeBlock(bad); nil == err {
+			t.Fatalf("decode the malformed block should fail")
+		}
+	}
+}
+
+func TestEncoding_HeaderLayout(t *testing.T) {
+	header := &core.BlockHeader{
+		Version:       1,
+		PrevBlockHash: []byte{0xab},
+		MerkleRoot:    []byte{},
+		TimeStamp:     2,
+		Bits:          0x1d00ffff,
+		Nonce:         3,
+		Height:        4,
+	}
+	data, err := core.EncodeHeader(header)
+	if nil != err {
+		t.Fatalf("encode the header failed: %v", err)
+	}
+	expected := "01000000" + "01000000ab" + "00000000" + "0200000000000000" +
+		"ffff001d" + "0300000000000000" + "0400000000000000"
+	if hex.EncodeToString(data) != expected {
+		t.Fatalf("the header encoding is %x, expected %s", data, expected)
+	}
+}
@@ -0,0 +1,78 @@
+package test
+
+import (
+	"bkc/core"
+	"bytes"
+	"testing"
+)
+
+func TestLockTime_IsFinal(t *testing.T) {
+	const medianTime = 1600000000
+	cases := []struct {
+		lockTime uint32
+		sequence uint32
+		final    bool
+	}{
+		{0, 0, true},
+		// 区块高度：交易只能被打包进高度大于锁定时间的区块
+		{9, core.MaxSequence - 1, true},
+		{10, core.MaxSequence - 1, false},
+		// 所有输入的序列号都是 MaxSequence 时锁定时间不生效
+		{10, core.MaxSequence, true},
+		// 时间戳：与前一个区块的 median-time-past 比较
+		{medianTime - 1, core.MaxSequence - 1, true},
+		{medianTime, core.MaxSequence - 1, false},
+	}
+	for i, c := range cases {
+		tx := &core.Transaction{
+			Version:  core.TxVersion,
+			Vins:     []*core.TxInput{{TxHash: bytes.Repeat([]byte{1}, 32), Sequence: c.sequence}},
+			LockTime: c.lockTime,
+		}
+		if final := tx.IsFinal(10, medianTime); final != c.final {
+			t.Errorf("case %d: expected final %v, got %v", i, c.final, final)
+		}
+	}
+}
+
+func TestLockTime_Encoding(t *testing.T) {
+	sequence, err := core.RelativeLockSeconds(600)
+	if nil != err || sequence != core.SequenceLockTimeIsSeconds|2 {
+		t.Fatalf("600 seconds should round up to 2 units, got %08x %v", sequence, err)
+	}
+	tx := &core.Transaction{
+		Version:  core.TxVersion,
+		Vins:     []*core.TxInput{{TxHash: bytes.Repeat([]byte{1}, 32), Vout: 1, Sequence: sequence}},
+		Vouts:    []*core.TxOutput{{Value: 5, ScriptPubKey: []byte{core.OP_1}}},
+		LockTime: 100,
+	}
+	data := tx.Serialize()
+	decoded, err := core.DecodeTransaction(data)
+	if nil != err {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if decoded.LockTime != 100 || decoded.Vins[0].Sequence != sequence {
+		t.Fatalf("the lock time or sequence is lost: %d %08x", decoded.LockTime, decoded.Vins[0].Sequence)
+	}
+	// 锁定时间与序列号参与签名哈希
+	other := *decoded
+	other.LockTime = 101
+	if bytes.Equal(tx.SignatureHash(0, nil), other.SignatureHash(0, nil)) {
+		t.Fatalf("the signature hash does not commit to the lock time")
+	}
+
+	// 第 2 版交易没有序列号与锁定时间，重新编码得到相同的数据
+	tx.Version, tx.LockTime, tx.Vins[0].Sequence = 2, 0, core.MaxSequence
+	v2 := tx.Serialize()
+	if len(v2) != len(data)-8 {
+		t.Fatalf("the version 2 encoding should not contain the sequence and lock time")
+	}
+	decoded, err = core.DecodeTransaction(v2)
+	if nil != err || decoded.Vins[0].Sequence != core.MaxSequence || !bytes.Equal(decoded.Serialize(), v2) {
+		t.Fatalf("the version 2 transaction does not round trip: %v", err)
+	}
+	tx.LockTime = 1
+	if _, err := core.EncodeTransaction(tx); nil == err {
+		t.Fatalf("a version 2 transaction with a lock time should not encode")
+	}
+}
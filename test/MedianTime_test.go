@@ -0,0 +1,67 @@
+package test
+
+import (
+	"bkc/core"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMedianTimeSource(t *testing.T) {
+	source := core.NewMedianTimeSource()
+	now := time.Now().Unix()
+	// 样本不足时不调整
+	for i := 0; i < 3; i++ {
+		source.AddTimeSample(fmt.Sprintf("node%d", i), now+100)
+	}
+	if offset := source.Offset(); offset != 0 {
+		t.Fatalf("the offset with too few samples is %d, expected 0", offset)
+	}
+	// 同一个节点只记录一次
+	source.AddTimeSample("node0", now+100)
+	if offset := source.Offset(); offset != 0 {
+		t.Fatalf("the duplicated sample should be ignored, the offset is %d", offset)
+	}
+	source.AddTimeSample("node3", now+100)
+	if offset := source.Offset(); offset < 99 || offset > 101 {
+		t.Fatalf("the offset is %d, expected 100", offset)
+	}
+	// 偏差过大时不调整
+	for i := 4; i < 12; i++ {
+		source.AddTimeSample(fmt.Sprintf("node%d", i), now+24*60*60)
+	}
+	if offset := source.Offset(); offset != 0 {
+		t.Fatalf("the offset is %d, the large offset should be ignored", offset)
+	}
+}
+
+func TestBlockTimestamp(t *testing.T) {
+	nodeId := "timestamptest"
+	miner := setupWallets(t, nodeId, 1)[0]
+	bc := setupBlockChain(t, nodeId, miner)
+	// 快速挖出多个区块，区块模板的时间戳会自动满足 median-time-past
+	for i := 0; i < 3; i++ {
+		if _, err := bc.MineBlock(context.Background(), miner, 0, nil); nil != err {
+			t.Fatalf("mine the block failed: %v", err)
+		}
+	}
+	tip := bc.GetHeader(bc.TipHash())
+	cases := map[int64]core.RejectReason{
+		bc.CalcPastMedianTime(tip):                                    core.RejectTimeTooOld,
+		time.Now().Unix() + core.ActiveParams.MaxFutureBlockTime + 60: core.RejectTimeTooNew,
+	}
+	for timestamp, reason := range cases {
+		block, err := bc.NewBlockTemplate(miner)
+		if nil != err {
+			t.Fatalf("create the block template failed: %v", err)
+		}
+		block.TimeStamp = timestamp
+		pow := core.NewProofOfWork(block)
+		pow.Workers = 1
+		block.Hash, block.Nonce, _ = pow.Run(context.Background())
+		if err := bc.AddBlock(block); !core.IsRejectReason(err, reason) {
+			t.Fatalf("the block with timestamp %d should be rejected (%s), got %v", timestamp, reason, err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package test
+
+import (
+	"bkc/core"
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestMempool_AcceptTransaction(t *testing.T) {
+	nodeId := "mempooltest"
+	addresses := setupWallets(t, nodeId, 2)
+	from, to := addresses[0], addresses[1]
+	bc := setupBlockChain(t, nodeId, from)
+	utxoSet := &core.UTXOSet{Blockchain: bc}
+	mempool := &core.Mempool{Blockchain: bc}
+
+	tx1 := core.NewSimpleTransaction(from, to, 3, 1, bc, nil, nodeId)
+	if err := mempool.AcceptTransaction(tx1); nil != err {
+		t.Fatalf("accept the tx failed: %v", err)
+	}
+	if err := mempool.AcceptTransaction(tx1); !core.IsRejectReason(err, core.RejectDuplicate) {
+		t.Fatalf("accept the same tx twice should fail, got %v", err)
+	}
+	// 不知道 tx1 的存在，再次花费同一个输出
+	conflict := core.NewSimpleTransaction(from, to, 2, 0, bc, nil, nodeId)
+	if err := mempool.AcceptTransaction(conflict); !core.IsRejectReason(err, core.RejectConflict) {
+		t.Fatalf("the conflicting tx should be rejected, got %v", err)
+	}
+	// 花费 tx1 的找零，形成依赖链
+	tx2 := core.NewSimpleTransaction(from, to, 2, 0, bc, []*core.Transaction{tx1}, nodeId)
+	if err := mempool.AcceptTransaction(tx2); nil != err {
+		t.Fatalf("accept the child tx failed: %v", err)
+	}
+	entry := mempool.GetEntry(tx2.TxHash)
+	if nil == entry || len(entry.Depends) != 1 || !bytes.Equal(entry.Depends[0], tx1.TxHash) {
+		t.Fatalf("the child tx should depend on the parent tx")
+	}
+	if entry := mempool.GetEntry(tx1.TxHash); len(entry.SpentBy) != 1 || !bytes.Equal(entry.SpentBy[0], tx2.TxHash) {
+		t.Fatalf("the parent tx should be spent by the child tx")
+	} else if entry.Fee != 1 {
+		t.Fatalf("the fee of the parent tx is %d, expected 1", entry.Fee)
+	}
+	hashes := mempool.GetRawMempool()
+	if len(hashes) != 2 || !bytes.Equal(hashes[0], tx1.TxHash) {
+		t.Fatalf("the mempool should list the parent before the child")
+	}
+
+	// 打包之后交易池被清空，手续费支付给矿工
+	if _, err := bc.MineBlock(context.Background(), from, 0, nil); nil != err {
+		t.Fatalf("mine the mempool failed: %v", err)
+	}
+	if len(mempool.GetRawMempool()) != 0 {
+		t.Fatalf("the mined transactions should be removed from the mempool")
+	}
+	if balance := utxoSet.GetBalance(to); balance != 5 {
+		t.Fatalf("the balance of the receiver is %d, expected 5", balance)
+	}
+	if balance := utxoSet.GetBalance(from); balance != 15 {
+		t.Fatalf("the balance of the miner is %d, expected 15", balance)
+	}
+}
@@ -0,0 +1,89 @@
+package test
+
+import (
+	"bkc/core"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+)
+
+// merkleRoot 按照定义递归计算 Merkle 根，作为对照
+func merkleRoot(level [][]byte) []byte {
+	if len(level) == 1 {
+		return level[0]
+	}
+	var parents [][]byte
+	for i := 0; i < len(level); i += 2 {
+		right := level[len(level)-1]
+		if i+1 < len(level) {
+			right = level[i+1]
+		}
+		hash := sha256.Sum256(append(append([]byte{}, level[i]...), right...))
+		parents = append(parents, hash[:])
+	}
+	return merkleRoot(parents)
+}
+
+func txHashes(n int) [][]byte {
+	var hashes [][]byte
+	for i := 0; i < n; i++ {
+		hash := sha256.Sum256([]byte(fmt.Sprintf("tx-%d", i)))
+		hashes = append(hashes, hash[:])
+	}
+	return hashes
+}
+
+func TestNewMerkleTree(t *testing.T) {
+	for n := 1; n <= 17; n++ {
+		hashes := txHashes(n)
+		var leaves [][]byte
+		for _, hash := range hashes {
+			leaf := sha256.Sum256(hash)
+			leaves = append(leaves, leaf[:])
+		}
+		mTree := core.NewMerkleTree(hashes)
+		if !bytes.Equal(mTree.RootNode.Data, merkleRoot(leaves)) {
+			t.Fatalf("wrong merkle root for %d transactions", n)
+		}
+		if mTree.Mutated {
+			t.Fatalf("the tree of %d distinct transactions is marked as mutated", n)
+		}
+	}
+}
+
+func TestMerkleTree_Mutated(t *testing.T) {
+	hashes := txHashes(3)
+	// 复制最后一笔交易得到的树根相同，但必须被识别出来
+	mutated := core.NewMerkleTree(append(hashes, hashes[2]))
+	if !bytes.Equal(core.NewMerkleTree(hashes).RootNode.Data, mutated.RootNode.Data) {
+		t.Fatalf("the duplicated tree should have the same root")
+	}
+	if !mutated.Mutated {
+		t.Fatalf("the duplicated tree is not marked as mutated")
+	}
+}
+
+func TestMerkleTree_Proof(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		hashes := txHashes(n)
+		mTree := core.NewMerkleTree(hashes)
+		root := mTree.RootNode.Data
+		for _, hash := range hashes {
+			proof, err := mTree.Proof(hash)
+			if nil != err {
+				t.Fatalf("proof failed: %v", err)
+			}
+			if !core.VerifyProof(root, hash, proof) {
+				t.Fatalf("the proof of tx %x in %d transactions is invalid", hash, n)
+			}
+			// 证明不能用于其他交易
+			if core.VerifyProof(root, txHashes(n + 1)[n], proof) {
+				t.Fatalf("the proof is valid for a wrong transaction")
+			}
+		}
+	}
+	if _, err := core.NewMerkleTree(txHashes(4)).Proof([]byte("missing")); nil == err {
+		t.Fatalf("proof of a missing transaction should fail")
+	}
+}
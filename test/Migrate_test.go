@@ -0,0 +1,160 @@
+package test
+
+import (
+	"bkc/core"
+	"bytes"
+	"crypto/rand"
+	"encoding/gob"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"os"
+	"testing"
+)
+
+// 旧版本（gob 编码）的区块与交易，字段名与旧版本相同
+type migrateTestBlock struct {
+	TimeStamp     int64
+	Hash          []byte
+	PrevBlockHash []byte
+	Height        int64
+	Nonce         int64
+	Txs           []*migrateTestTx
+}
+
+type migrateTestTx struct {
+	TxHash []byte
+	Vins   []*migrateTestTxInput
+	Vouts  []*migrateTestTxOutput
+}
+
+type migrateTestTxInput struct {
+	TxHash    []byte
+	Vout      int
+	Signature []byte
+	PublicKey []byte
+}
+
+type migrateTestTxOutput struct {
+	Value         int
+	Ripemd160Hash []byte
+}
+
+// migrateTestRandom 随机字节，模拟旧版本中加入时间戳生成的交易哈希与区块哈希
+func migrateTestRandom(n int) []byte {
+	data := make([]byte, n)
+	rand.Read(data)
+	return data
+}
+
+// migrateTestWriteLegacyDB 把旧版本的区块写入 nodeId 对应的数据库
+func migrateTestWriteLegacyDB(t *testing.T, nodeId string, blocks []*migrateTestBlock) {
+	db, err := bolt.Open(fmt.Sprintf(core.DBName, nodeId), 0600, nil)
+	if nil != err {
+		t.Fatalf("open the legacy db failed: %v", err)
+	}
+	defer db.Close()
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(core.BlockTableName))
+		if nil != err {
+			return err
+		}
+		for _, block := range blocks {
+			var buffer bytes.Buffer
+			if err := gob.NewEncoder(&buffer).Encode(block); nil != err {
+				return err
+			}
+			if err := b.Put(block.Hash, buffer.Bytes()); nil != err {
+				return err
+			}
+		}
+		return b.Put([]byte("1"), blocks[len(blocks)-1].Hash)
+	})
+	if nil != err {
+		t.Fatalf("write the legacy db failed: %v", err)
+	}
+}
+
+func TestMigrateBlockChain(t *testing.T) {
+	// 迁移不需要任何私钥，钱包只用于生成地址
+	alice, bob := core.NewWallet(), core.NewWallet()
+	coinbase := func(to *core.Wallet) *migrateTestTx {
+		return &migrateTestTx{
+			TxHash: migrateTestRandom(32),
+			Vins:   []*migrateTestTxInput{{TxHash: []byte{}, Vout: -1}},
+			Vouts:  []*migrateTestTxOutput{{Value: 10, Ripemd160Hash: core.Ripemd160Hash(to.PublicKey)}},
+		}
+	}
+	genesis := &migrateTestBlock{TimeStamp: 1600000000, Hash: migrateTestRandom(32), Height: 1,
+		Txs: []*migrateTestTx{coinbase(alice)}}
+	transfer := &migrateTestTx{
+		TxHash: migrateTestRandom(32),
+		Vins: []*migrateTestTxInput{{TxHash: genesis.Txs[0].TxHash, Vout: 0,
+			Signature: migrateTestRandom(64), PublicKey: alice.PublicKey}},
+		Vouts: []*migrateTestTxOutput{
+			{Value: 4, Ripemd160Hash: core.Ripemd160Hash(bob.PublicKey)},
+			{Value: 6, Ripemd160Hash: core.Ripemd160Hash(alice.PublicKey)},
+		},
+	}
+	// 旧版本在每笔转账之后添加一笔 coinbase 交易，并且没有时间戳规则，时间戳可以早于前一个区块
+	second := &migrateTestBlock{TimeStamp: 1599999990, Hash: migrateTestRandom(32), PrevBlockHash: genesis.Hash, Height: 2,
+		Txs: []*migrateTestTx{transfer, coinbase(alice)}}
+	// send 一次发送两笔转账时，区块中有两笔 coinbase 交易，第二笔转账花费第一笔转账的找零
+	send := func(prev *migrateTestTx, bobValue int) *migrateTestTx {
+		change := prev.Vouts[len(prev.Vouts)-1].Value - bobValue
+		return &migrateTestTx{
+			TxHash: migrateTestRandom(32),
+			Vins: []*migrateTestTxInput{{TxHash: prev.TxHash, Vout: len(prev.Vouts) - 1,
+				Signature: migrateTestRandom(64), PublicKey: alice.PublicKey}},
+			Vouts: []*migrateTestTxOutput{
+				{Value: bobValue, Ripemd160Hash: core.Ripemd160Hash(bob.PublicKey)},
+				{Value: change, Ripemd160Hash: core.Ripemd160Hash(alice.PublicKey)},
+			},
+		}
+	}
+	first := send(transfer, 1)
+	third := &migrateTestBlock{TimeStamp: 1600000010, Hash: migrateTestRandom(32), PrevBlockHash: second.Hash, Height: 3,
+		Txs: []*migrateTestTx{first, coinbase(alice), send(first, 2), coinbase(alice)}}
+
+	// 两个节点迁移同一个旧数据库，得到完全相同的区块链
+	var tips [][]byte
+	for _, nodeId := range []string{"migratetest1", "migratetest2"} {
+		defer os.Remove(fmt.Sprintf(core.DBName, nodeId) + ".legacy")
+		defer os.Remove(fmt.Sprintf(core.DBName, nodeId))
+		migrateTestWriteLegacyDB(t, nodeId, []*migrateTestBlock{genesis, second, third})
+		if err := core.MigrateBlockChain(nodeId); nil != err {
+			t.Fatalf("migrate the legacy db failed: %v", err)
+		}
+		bc := core.BlockchainObject(nodeId)
+		tips = append(tips, bc.TipHash())
+		// 保留旧的交易哈希与时间戳
+		if tx := bc.FindTransaction(transfer.TxHash); !bytes.Equal(tx.TxHash, transfer.TxHash) {
+			bc.DB.Close()
+			t.Fatalf("the legacy txid is not kept")
+		}
+		if header := bc.GetHeader(bc.TipHash()); header.TimeStamp != third.TimeStamp || header.Height != 3 {
+			bc.DB.Close()
+			t.Fatalf("the legacy block time or height is not kept: %+v", header)
+		}
+		utxoSet := &core.UTXOSet{Blockchain: bc}
+		if a, b := utxoSet.GetBalance(string(alice.GetAddress())), utxoSet.GetBalance(string(bob.GetAddress())); a != 33 || b != 7 {
+			bc.DB.Close()
+			t.Fatalf("the balances after the migration are %d and %d, expected 33 and 7", a, b)
+		}
+		// 从网络收到的区块不能包含迁移交易
+		block, err := core.DecodeBlock(bc.GetBlock(bc.TipHash()))
+		if nil != err {
+			bc.DB.Close()
+			t.Fatalf("decode the migrated block failed: %v", err)
+		}
+		block.PrevBlockHash, block.Height = bc.TipHash(), block.Height+1
+		block.Hash = block.BlockHeader.Hash()
+		if err := bc.AddBlock(block); !core.IsRejectReason(err, core.RejectMalformed) {
+			bc.DB.Close()
+			t.Fatalf("the block with migrated transactions should be rejected, got %v", err)
+		}
+		bc.DB.Close()
+	}
+	if !bytes.Equal(tips[0], tips[1]) {
+		t.Fatalf("the migration is not deterministic: %x %x", tips[0], tips[1])
+	}
+}
@@ -0,0 +1,49 @@
+package test
+
+import (
+	"bkc/core"
+	"testing"
+)
+
+func TestNetworks_Separation(t *testing.T) {
+	defer core.SelectNetwork(core.MainNet)
+	wallet := scriptTestWallet()
+	tx := scriptTestTx()
+	lock := core.PayToPubKeyHashScript(core.Ripemd160Hash(wallet.PublicKey))
+	if err := core.SelectNetwork(core.TestNet); nil != err {
+		t.Fatalf("select the testnet failed: %v", err)
+	}
+	testnetAddress := wallet.GetAddress()
+	signature := scriptTestSign(t, wallet, tx, 0, lock)
+	if err := core.VerifyScript(core.SignatureScript(signature, wallet.PublicKey), lock, tx, 0); nil != err {
+		t.Fatalf("verify the testnet signature failed: %v", err)
+	}
+	if err := core.SelectNetwork(core.MainNet); nil != err {
+		t.Fatalf("select the mainnet failed: %v", err)
+	}
+	// 测试网的地址与签名在主网上无效
+	if core.IsValidForAddress(testnetAddress) {
+		t.Fatalf("the testnet address [%s] should be invalid on the mainnet", testnetAddress)
+	}
+	if !core.IsValidForAddress(wallet.GetAddress()) {
+		t.Fatalf("the mainnet address [%s] should be valid", wallet.GetAddress())
+	}
+	if err := core.VerifyScript(core.SignatureScript(signature, wallet.PublicKey), lock, tx, 0); nil == err {
+		t.Fatalf("the testnet signature should be invalid on the mainnet")
+	}
+	if err := core.SelectNetwork("unknown"); nil == err {
+		t.Fatalf("the unknown network should fail")
+	}
+	// 第 5 版之前的交易签名哈希不包含网络魔数，之前签名的交易在任何网络上仍然有效
+	tx.Version = 4
+	if err := core.SelectNetwork(core.TestNet); nil != err {
+		t.Fatalf("select the testnet failed: %v", err)
+	}
+	signature = scriptTestSign(t, wallet, tx, 0, lock)
+	if err := core.SelectNetwork(core.MainNet); nil != err {
+		t.Fatalf("select the mainnet failed: %v", err)
+	}
+	if err := core.VerifyScript(core.SignatureScript(signature, wallet.PublicKey), lock, tx, 0); nil != err {
+		t.Fatalf("the version 4 signature should not depend on the network: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package test
+
+import (
+	"bkc/core"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestProofOfWork_Cancel(t *testing.T) {
+	// 目标值几乎不可能满足，只能通过 ctx 停止
+	block := &core.Block{
+		BlockHeader: core.BlockHeader{
+			Version:   1,
+			TimeStamp: time.Now().Unix(),
+			Bits:      0x03000001,
+			Height:    1,
+		},
+	}
+	pow := core.NewProofOfWork(block)
+	var reported *core.MiningProgress
+	pow.Progress = func(progress *core.MiningProgress) {
+		reported = progress
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	hash, _, err := pow.Run(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || nil != hash {
+		t.Fatalf("the canceled pow should return the ctx error, got %v", err)
+	}
+	if nil == reported || reported.Hashes == 0 {
+		t.Fatalf("the progress was not reported")
+	}
+}
+
+// benchmarkBlock 生成用于性能测试的区块，平均需要尝试约 2^16 次哈希
+func benchmarkBlock(height int64) *core.Block {
+	coinbase := core.NewCoinbaseTransaction("1caq3M2hhrw5MwSXSuPxcA9PChcxSMdCV8", height, 0, 0)
+	block := &core.Block{
+		BlockHeader: core.BlockHeader{
+			Version:       1,
+			PrevBlockHash: make([]byte, 32),
+			TimeStamp:     1600000000,
+			Bits:          0x1f00ffff,
+			Height:        height,
+		},
+		Txs: []*core.Transaction{coinbase},
+	}
+	block.MerkleRoot = block.HashTransaction()
+	return block
+}
+
+// BenchmarkProofOfWork_Legacy 原来的实现：每个 nonce 都重新序列化整个区块头，单个 goroutine
+func BenchmarkProofOfWork_Legacy(b *testing.B) {
+	block := benchmarkBlock(1)
+	target := core.CompactToBig(block.Bits)
+	var hashInt big.Int
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		header := block.BlockHeader
+		header.Nonce = int64(i)
+		hash := sha256.Sum256(header.Serialize())
+		hashInt.SetBytes(hash[:])
+		_ = target.Cmp(&hashInt) == 1
+	}
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "hashes/s")
+}
+
+func benchmarkProofOfWork(b *testing.B, workers int) {
+	var hashes int64
+	for i := 0; i < b.N; i++ {
+		pow := core.NewProofOfWork(benchmarkBlock(int64(i + 1)))
+		pow.Workers = workers
+		pow.Progress = func(progress *core.MiningProgress) {
+			hashes += progress.Hashes
+		}
+		if _, _, err := pow.Run(context.Background()); nil != err {
+			b.Fatalf("mine the block failed: %v", err)
+		}
+	}
+	b.ReportMetric(float64(hashes)/b.Elapsed().Seconds(), "hashes/s")
+}
+
+// BenchmarkProofOfWork_SingleWorker 只修改 nonce 字节，单个 goroutine
+func BenchmarkProofOfWork_SingleWorker(b *testing.B) {
+	benchmarkProofOfWork(b, 1)
+}
+
+// BenchmarkProofOfWork_AllWorkers 只修改 nonce 字节，每个 CPU 核心一个 goroutine
+func BenchmarkProofOfWork_AllWorkers(b *testing.B) {
+	benchmarkProofOfWork(b, runtime.NumCPU())
+}
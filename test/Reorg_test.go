@@ -0,0 +1,125 @@
+package test
+
+import (
+	"bkc/core"
+	"bytes"
+	"context"
+	"testing"
+)
+
+// reorgTestBlock 在 prev 之后封装一个包含 coinbase 与 txs 的区块（txs 不支付手续费），extraNonce 用于区分不同分支上相同高度的区块
+func reorgTestBlock(t *testing.T, bc *core.BlockChain, prev *core.BlockHeader, miner string, extraNonce int64,
+	txs ...*core.Transaction) *core.Block {
+	bits, err := bc.Engine().NextBits(bc, prev)
+	if nil != err {
+		t.Fatalf("calculate the bits failed: %v", err)
+	}
+	block := &core.Block{
+		BlockHeader: core.BlockHeader{
+			Version:       prev.Version,
+			PrevBlockHash: prev.Hash(),
+			TimeStamp:     prev.TimeStamp + 1,
+			Bits:          bits,
+			Height:        prev.Height + 1,
+		},
+		Txs: append([]*core.Transaction{core.NewCoinbaseTransaction(miner, prev.Height+1, extraNonce, 0)}, txs...),
+	}
+	block.MerkleRoot = block.HashTransaction()
+	if err := bc.Engine().Seal(context.Background(), prev, block, &core.SealOptions{}); nil != err {
+		t.Fatalf("seal the block failed: %v", err)
+	}
+	return block
+}
+
+func TestReorganize(t *testing.T) {
+	nodeId := "reorgtest"
+	miner := setupWallets(t, nodeId, 1)[0]
+	bc := setupBlockChain(t, nodeId, miner)
+	genesis := bc.GetHeader(bc.TipHash())
+	mainBlock, err := bc.MineBlock(context.Background(), miner, 0, nil)
+	if nil != err {
+		t.Fatalf("mine the block failed: %v", err)
+	}
+	// 与主链工作量相同的侧链区块不改变最新区块
+	side := reorgTestBlock(t, bc, genesis, miner, 1)
+	if err := bc.AddBlock(side); nil != err {
+		t.Fatalf("add the side chain block failed: %v", err)
+	}
+	if !bytes.Equal(bc.TipHash(), mainBlock.Hash) {
+		t.Fatalf("the side chain with equal work should not become the main chain")
+	}
+	// 侧链的累计工作量超过主链之后发生链重组
+	sideTip := reorgTestBlock(t, bc, &side.BlockHeader, miner, 1)
+	if err := bc.AddBlock(sideTip); nil != err {
+		t.Fatalf("add the heavier side chain block failed: %v", err)
+	}
+	if !bytes.Equal(bc.TipHash(), sideTip.Hash) || bc.GetHeight() != 3 {
+		t.Fatalf("the chain should reorganize to the heavier branch, the height is %d", bc.GetHeight())
+	}
+	// UTXO 集合只包含新主链上的 coinbase 输出
+	utxoSet := &core.UTXOSet{Blockchain: bc}
+	if balance := utxoSet.GetBalance(miner); balance != core.BlockSubsidy(1)+core.BlockSubsidy(2)+core.BlockSubsidy(3) {
+		t.Fatalf("the balance after the reorganization is %d", balance)
+	}
+}
+
+func TestRejectForeignGenesis(t *testing.T) {
+	nodeId := "foreigngenesistest"
+	miner := setupWallets(t, nodeId, 1)[0]
+	bc := setupBlockChain(t, nodeId, miner)
+	tip := bc.TipHash()
+	// 其他链的创世区块，以及在它之后工作量更大的分支都不能被接受
+	foreign := core.CreateGenesisBlock([]*core.Transaction{core.NewCoinbaseTransaction(miner, 1, 1, 0)})
+	if err := bc.AddBlock(foreign); !core.IsRejectReason(err, core.RejectBadGenesis) {
+		t.Fatalf("the foreign genesis block should be rejected, got %v", err)
+	}
+	next := reorgTestBlock(t, bc, &foreign.BlockHeader, miner, 1)
+	if err := bc.AddBlock(next); nil == err {
+		t.Fatalf("the block after the foreign genesis block should be rejected")
+	}
+	if !bytes.Equal(bc.TipHash(), tip) {
+		t.Fatalf("the tip should not change")
+	}
+}
+
+func TestReorganizeMempool(t *testing.T) {
+	nodeId := "reorgmempooltest"
+	addresses := setupWallets(t, nodeId, 2)
+	from, to := addresses[0], addresses[1]
+	bc := setupBlockChain(t, nodeId, from)
+	mempool := &core.Mempool{Blockchain: bc}
+	genesis := bc.GetHeader(bc.TipHash())
+	// 侧链上花费同一个输出的交易
+	conflict := core.NewSimpleTransaction(from, to, 2, 0, bc, nil, nodeId)
+	// 主链打包 tx1，交易池中的 tx2 花费 tx1 的找零
+	tx1 := core.NewSimpleTransaction(from, to, 3, 0, bc, nil, nodeId)
+	if err := mempool.AcceptTransaction(tx1); nil != err {
+		t.Fatalf("accept the tx failed: %v", err)
+	}
+	if _, err := bc.MineBlock(context.Background(), from, 0, nil); nil != err {
+		t.Fatalf("mine the block failed: %v", err)
+	}
+	tx2 := core.NewSimpleTransaction(from, to, 2, 0, bc, nil, nodeId)
+	if err := mempool.AcceptTransaction(tx2); nil != err {
+		t.Fatalf("accept the child tx failed: %v", err)
+	}
+	// 侧链双花 tx1 的输入并且工作量更大，tx1 无法放回交易池，tx2 的输入也不再存在
+	side := reorgTestBlock(t, bc, genesis, from, 1, conflict)
+	if err := bc.AddBlock(side); nil != err {
+		t.Fatalf("add the side chain block failed: %v", err)
+	}
+	sideTip := reorgTestBlock(t, bc, &side.BlockHeader, from, 1)
+	if err := bc.AddBlock(sideTip); nil != err {
+		t.Fatalf("add the heavier side chain block failed: %v", err)
+	}
+	if !bytes.Equal(bc.TipHash(), sideTip.Hash) {
+		t.Fatalf("the chain should reorganize to the heavier branch")
+	}
+	if hashes := mempool.GetRawMempool(); len(hashes) != 0 {
+		t.Fatalf("the txs spending the double spent output should be removed, %d left", len(hashes))
+	}
+	// 交易池不再包含无法打包的交易，可以继续挖矿
+	if _, err := bc.MineBlock(context.Background(), from, 0, nil); nil != err {
+		t.Fatalf("mine the block after the reorganization failed: %v", err)
+	}
+}
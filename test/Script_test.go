@@ -0,0 +1,180 @@
+package test
+
+import (
+	"bkc/core"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+// scriptTestWallet 生成公钥为 33 字节压缩公钥的钱包
+func scriptTestWallet() *core.Wallet {
+	return core.NewWallet()
+}
+
+// scriptTestSign 为交易的第 index 个输入签名，r、s 各占 32 字节，s 为低值
+func scriptTestSign(t *testing.T, wallet *core.Wallet, tx *core.Transaction, index int, script []byte) []byte {
+	r, s, err := ecdsa.Sign(rand.Reader, &wallet.PrivateKey, tx.SignatureHash(index, script))
+	if nil != err {
+		t.Fatalf("sign failed: %v", err)
+	}
+	if n := wallet.PrivateKey.Curve.Params().N; s.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		s.Sub(n, s)
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return signature
+}
+
+func scriptTestTx() *core.Transaction {
+	return &core.Transaction{
+		Version: core.TxVersion,
+		Vins:    []*core.TxInput{{TxHash: bytes.Repeat([]byte{1}, 32), Vout: 0}},
+		Vouts:   []*core.TxOutput{{Value: 1, ScriptPubKey: core.PayToPubKeyHashScript(bytes.Repeat([]byte{2}, 20))}},
+	}
+}
+
+func TestScript_PayToPubKeyHash(t *testing.T) {
+	wallet := scriptTestWallet()
+	other := scriptTestWallet()
+	tx := scriptTestTx()
+	lock := core.PayToPubKeyHashScript(core.Ripemd160Hash(wallet.PublicKey))
+	if !bytes.Equal(core.ExtractPubKeyHash(lock), core.Ripemd160Hash(wallet.PublicKey)) {
+		t.Fatalf("extract the pubkey hash from %s failed", core.DisasmScript(lock))
+	}
+	signature := scriptTestSign(t, wallet, tx, 0, lock)
+	if err := core.VerifyScript(core.SignatureScript(signature, wallet.PublicKey), lock, tx, 0); nil != err {
+		t.Fatalf("verify the p2pkh script failed: %v", err)
+	}
+	// 公钥与公钥哈希不一致
+	if err := core.VerifyScript(core.SignatureScript(signature, other.PublicKey), lock, tx, 0); nil == err {
+		t.Fatalf("the wrong pubkey should fail")
+	}
+	// 签名与交易内容不一致
+	tx.Vouts[0].Value = 2
+	if err := core.VerifyScript(core.SignatureScript(signature, wallet.PublicKey), lock, tx, 0); nil == err {
+		t.Fatalf("the modified transaction should fail")
+	}
+}
+
+func TestScript_CheckMultiSig(t *testing.T) {
+	wallets := []*core.Wallet{scriptTestWallet(), scriptTestWallet(), scriptTestWallet()}
+	tx := scriptTestTx()
+	builder := core.NewScriptBuilder().AddInt(2)
+	for _, wallet := range wallets {
+		builder.AddData(wallet.PublicKey)
+	}
+	lock := builder.AddInt(3).AddOp(core.OP_CHECKMULTISIG).Script()
+	sig0 := scriptTestSign(t, wallets[0], tx, 0, lock)
+	sig2 := scriptTestSign(t, wallets[2], tx, 0, lock)
+	cases := []struct {
+		unlock [][]byte
+		valid  bool
+	}{
+		{[][]byte{sig0, sig2}, true},
+		// 签名顺序必须与公钥顺序一致
+		{[][]byte{sig2, sig0}, false},
+		{[][]byte{sig0, sig0}, false},
+	}
+	for i, c := range cases {
+		unlock := core.NewScriptBuilder()
+		for _, sig := range c.unlock {
+			unlock.AddData(sig)
+		}
+		err := core.VerifyScript(unlock.Script(), lock, tx, 0)
+		if c.valid != (nil == err) {
+			t.Fatalf("case %d: expected valid %v, got %v", i, c.valid, err)
+		}
+	}
+}
+
+func TestScript_Conditionals(t *testing.T) {
+	tx := scriptTestTx()
+	// OP_IF 2 OP_ELSE 3 OP_ENDIF 3 OP_EQUAL
+	lock := core.NewScriptBuilder().AddOp(core.OP_IF).AddInt(2).AddOp(core.OP_ELSE).AddInt(3).AddOp(core.OP_ENDIF).
+		AddInt(3).AddOp(core.OP_EQUAL).Script()
+	if err := core.VerifyScript(core.NewScriptBuilder().AddInt(0).Script(), lock, tx, 0); nil != err {
+		t.Fatalf("the else branch should succeed: %v", err)
+	}
+	if err := core.VerifyScript(core.NewScriptBuilder().AddInt(1).Script(), lock, tx, 0); err != core.ErrScriptFalse {
+		t.Fatalf("the if branch should evaluate to false, got %v", err)
+	}
+	// 分支不完整
+	unbalanced := core.NewScriptBuilder().AddOp(core.OP_IF).AddInt(1).Script()
+	if err := core.VerifyScript(core.NewScriptBuilder().AddInt(1).Script(), unbalanced, tx, 0); nil == err {
+		t.Fatalf("the unbalanced conditional should fail")
+	}
+	// 解锁脚本只能推入数据
+	nonPush := core.NewScriptBuilder().AddInt(1).AddOp(core.OP_DUP).Script()
+	if err := core.VerifyScript(nonPush, core.NewScriptBuilder().AddOp(core.OP_DROP).Script(), tx, 0); nil == err {
+		t.Fatalf("the non push-only signature script should fail")
+	}
+	// OP_RETURN 不能被花费
+	if err := core.VerifyScript(nil, core.NewScriptBuilder().AddOp(core.OP_RETURN).Script(), tx, 0); nil == err {
+		t.Fatalf("OP_RETURN should fail")
+	}
+}
+
+func TestScript_PayToScriptHashMultiSig(t *testing.T) {
+	wallets := &core.Wallets{Wallets: map[string]*core.Wallet{}}
+	var pubKeys [][]byte
+	for i := 0; i < 3; i++ {
+		wallet := scriptTestWallet()
+		pubKeys = append(pubKeys, wallet.PublicKey)
+		if i != 1 {
+			wallets.Wallets[string(wallet.GetAddress())] = wallet
+		}
+	}
+	redeemScript, err := core.MultiSigScript(2, pubKeys)
+	if nil != err {
+		t.Fatalf("create the multisig script failed: %v", err)
+	}
+	address := core.ScriptHashAddress(redeemScript)
+	if !core.IsScriptHashAddress(address) || !core.IsValidForAddress([]byte(address)) {
+		t.Fatalf("the multisig address [%s] is invalid", address)
+	}
+	lock := core.PayToAddressScript(address)
+	if !bytes.Equal(core.ExtractScriptHash(lock), core.Ripemd160Hash(redeemScript)) {
+		t.Fatalf("the lock script %s does not commit to the redeem script", core.DisasmScript(lock))
+	}
+	tx := scriptTestTx()
+	unlock := core.NewScriptBuilder().AddData(nil).AddData(nil).AddData(nil).AddData(redeemScript).Script()
+	tx.Vins[0].ScriptSig = unlock
+	// 只有一个私钥时签名数量不足
+	signer := &core.Wallets{Wallets: map[string]*core.Wallet{}}
+	for address, wallet := range wallets.Wallets {
+		signer.Wallets[address] = wallet
+		break
+	}
+	if added, err := tx.SignMultiSig(signer); nil != err || added != 1 {
+		t.Fatalf("sign with one key: added %d, %v", added, err)
+	}
+	if err := tx.FinalizeMultiSig(); nil == err {
+		t.Fatalf("finalize with one signature should fail")
+	}
+	if err := core.VerifyScript(tx.Vins[0].ScriptSig, lock, tx, 0); nil == err {
+		t.Fatalf("the partially signed input should fail")
+	}
+	if added, err := tx.SignMultiSig(wallets); nil != err || added != 1 {
+		t.Fatalf("sign with the other key: added %d, %v", added, err)
+	}
+	if have, need, err := tx.MultiSigProgress(); nil != err || have != 2 || need != 2 {
+		t.Fatalf("expected 2/2 signatures, got %d/%d %v", have, need, err)
+	}
+	if err := tx.FinalizeMultiSig(); nil != err {
+		t.Fatalf("finalize failed: %v", err)
+	}
+	if err := core.VerifyScript(tx.Vins[0].ScriptSig, lock, tx, 0); nil != err {
+		t.Fatalf("verify the multisig input failed: %v", err)
+	}
+	// 赎回脚本替换为 1-of-3 时哈希不一致
+	weaker, _ := core.MultiSigScript(1, pubKeys)
+	data, _ := core.PushedData(tx.Vins[0].ScriptSig)
+	forged := core.NewScriptBuilder().AddData(data[0]).AddData(weaker).Script()
+	if err := core.VerifyScript(forged, lock, tx, 0); nil == err {
+		t.Fatalf("the substituted redeem script should fail")
+	}
+}
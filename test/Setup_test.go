@@ -0,0 +1,34 @@
+package test
+
+import (
+	"bkc/core"
+	"fmt"
+	"os"
+	"testing"
+)
+
+// 测试共用的区块链与钱包初始化
+
+// setupWallets 在节点的钱包文件中创建 count 个钱包并返回它们的地址，测试结束时删除钱包文件
+func setupWallets(t *testing.T, nodeId string, count int) []string {
+	t.Cleanup(func() { os.Remove(fmt.Sprintf("Wallets_%s.dat", nodeId)) })
+	wallets := core.NewWallets(nodeId)
+	for i := 0; i < count; i++ {
+		wallets.CreateWallet(nodeId)
+	}
+	var addresses []string
+	for address := range wallets.Wallets {
+		addresses = append(addresses, address)
+	}
+	return addresses
+}
+
+// setupBlockChain 创建节点的区块链（创世区块奖励给 address）并重建 UTXO 集合，
+// 测试结束时关闭并删除数据库
+func setupBlockChain(t *testing.T, nodeId string, address string) *core.BlockChain {
+	t.Cleanup(func() { os.Remove(fmt.Sprintf(core.DBName, nodeId)) })
+	bc := core.CreateBlockChain(address, nodeId)
+	t.Cleanup(func() { bc.DB.Close() })
+	(&core.UTXOSet{Blockchain: bc}).ResetUTXOSet()
+	return bc
+}
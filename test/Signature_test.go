@@ -0,0 +1,139 @@
+package test
+
+import (
+	"bkc/core"
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+// signatureTestLegacyPubKey 旧版本钱包的公钥：x + y，各占 32 字节
+func signatureTestLegacyPubKey(wallet *core.Wallet) []byte {
+	pubKey := make([]byte, 64)
+	wallet.PrivateKey.X.FillBytes(pubKey[:32])
+	wallet.PrivateKey.Y.FillBytes(pubKey[32:])
+	return pubKey
+}
+
+func TestSignature_StrictEncoding(t *testing.T) {
+	wallet := scriptTestWallet()
+	if len(wallet.PublicKey) != 33 || (wallet.PublicKey[0] != 0x02 && wallet.PublicKey[0] != 0x03) {
+		t.Fatalf("the pubkey [%x] is not compressed", wallet.PublicKey)
+	}
+	tx := scriptTestTx()
+	lock := core.PayToPubKeyHashScript(core.Ripemd160Hash(wallet.PublicKey))
+	signature := scriptTestSign(t, wallet, tx, 0, lock)
+	if !core.IsCanonicalSignature(signature) {
+		t.Fatalf("the signature [%x] should be canonical", signature)
+	}
+	if err := core.VerifyScript(core.SignatureScript(signature, wallet.PublicKey), lock, tx, 0); nil != err {
+		t.Fatalf("verify the canonical signature failed: %v", err)
+	}
+	// 高 s 值的签名同样可以通过 ECDSA 验证，但不是严格编码
+	n := wallet.PrivateKey.Curve.Params().N
+	highS := append([]byte{}, signature...)
+	new(big.Int).Sub(n, new(big.Int).SetBytes(signature[32:])).FillBytes(highS[32:])
+	// 去掉 r 开头的 0（或者补上多余的 0）得到的签名长度不是 64 字节
+	padded := append([]byte{0}, signature...)
+	for _, invalid := range [][]byte{highS, padded, signature[:63]} {
+		if core.IsCanonicalSignature(invalid) {
+			t.Fatalf("the signature [%x] should not be canonical", invalid)
+		}
+		if err := core.VerifyScript(core.SignatureScript(invalid, wallet.PublicKey), lock, tx, 0); nil == err {
+			t.Fatalf("the non-canonical signature [%x] should fail", invalid)
+		}
+	}
+	// 非法的压缩公钥前缀
+	badPubKey := append([]byte{0x04}, wallet.PublicKey[1:]...)
+	badLock := core.PayToPubKeyHashScript(core.Ripemd160Hash(badPubKey))
+	if err := core.VerifyScript(core.SignatureScript(signature, badPubKey), badLock, tx, 0); nil == err {
+		t.Fatalf("the pubkey with prefix 0x04 should fail")
+	}
+	// 第 3 版交易仍然按照原来的规则验证，已有区块中的高 s 值签名仍然有效
+	tx.Version = 3
+	legacyPubKey := signatureTestLegacyPubKey(wallet)
+	legacyLock := core.PayToPubKeyHashScript(core.Ripemd160Hash(legacyPubKey))
+	legacySignature := scriptTestSign(t, wallet, tx, 0, legacyLock)
+	new(big.Int).Sub(n, new(big.Int).SetBytes(legacySignature[32:])).FillBytes(legacySignature[32:])
+	if err := core.VerifyScript(core.SignatureScript(legacySignature, legacyPubKey), legacyLock, tx, 0); nil != err {
+		t.Fatalf("the version 3 transaction should use the legacy rules: %v", err)
+	}
+}
+
+func TestSignature_LegacyWallet(t *testing.T) {
+	wallet := scriptTestWallet()
+	legacyPubKey := signatureTestLegacyPubKey(wallet)
+	// 旧版本钱包文件中的 64 字节公钥保留，地址不变
+	legacy := &core.Wallet{PrivateKey: wallet.PrivateKey, PublicKey: legacyPubKey}
+	content, err := legacy.GobEncode()
+	if nil != err {
+		t.Fatalf("encode the wallet failed: %v", err)
+	}
+	var decoded core.Wallet
+	if err := decoded.GobDecode(content); nil != err || !bytes.Equal(decoded.PublicKey, legacyPubKey) {
+		t.Fatalf("the legacy pubkey is not kept: %x %v", decoded.PublicKey, err)
+	}
+	// 与私钥不对应的公钥改为压缩公钥
+	mismatched := &core.Wallet{PrivateKey: wallet.PrivateKey, PublicKey: legacyPubKey[1:]}
+	if legacyPubKey[0] == 0 {
+		mismatched.PublicKey = legacyPubKey[2:]
+	}
+	if content, err = mismatched.GobEncode(); nil != err {
+		t.Fatalf("encode the wallet failed: %v", err)
+	}
+	if err := decoded.GobDecode(content); nil != err || !bytes.Equal(decoded.PublicKey, wallet.PublicKey) {
+		t.Fatalf("the mismatched pubkey should be compressed: %x %v", decoded.PublicKey, err)
+	}
+	// 旧地址上的输出使用旧公钥解锁，压缩公钥地址上的输出使用压缩公钥解锁
+	prevTx := core.Transaction{
+		Version: core.TxVersion,
+		Vins:    []*core.TxInput{{TxHash: []byte{}, Vout: -1}},
+		Vouts: []*core.TxOutput{
+			{Value: 5, ScriptPubKey: core.PayToPubKeyHashScript(core.Ripemd160Hash(legacyPubKey))},
+			{Value: 5, ScriptPubKey: core.PayToPubKeyHashScript(core.Ripemd160Hash(wallet.PublicKey))},
+		},
+	}
+	prevTx.HashTransaction()
+	tx := scriptTestTx()
+	tx.Vins = []*core.TxInput{{TxHash: prevTx.TxHash, Vout: 0}, {TxHash: prevTx.TxHash, Vout: 1}}
+	prevTxs := map[string]core.Transaction{hex.EncodeToString(prevTx.TxHash): prevTx}
+	tx.Sign(wallet.PrivateKey, prevTxs)
+	if err := tx.VerifyScripts(prevTxs); nil != err {
+		t.Fatalf("spend the legacy and compressed outputs failed: %v", err)
+	}
+}
+
+func TestSignature_UnpaddedLegacyWallet(t *testing.T) {
+	// 更早版本的钱包省略了坐标开头的 0，找到一个 63 字节的旧公钥
+	var wallet *core.Wallet
+	var shortPubKey []byte
+	for len(shortPubKey) != 63 {
+		wallet = scriptTestWallet()
+		shortPubKey = append(wallet.PrivateKey.X.Bytes(), wallet.PrivateKey.Y.Bytes()...)
+	}
+	// 钱包文件中的旧公钥保留，地址不变
+	content, err := (&core.Wallet{PrivateKey: wallet.PrivateKey, PublicKey: shortPubKey}).GobEncode()
+	if nil != err {
+		t.Fatalf("encode the wallet failed: %v", err)
+	}
+	var decoded core.Wallet
+	if err := decoded.GobDecode(content); nil != err || !bytes.Equal(decoded.PublicKey, shortPubKey) {
+		t.Fatalf("the unpadded legacy pubkey is not kept: %x %v", decoded.PublicKey, err)
+	}
+	// 严格编码的交易仍然可以花费旧地址上的输出
+	lock := core.PayToPubKeyHashScript(core.Ripemd160Hash(shortPubKey))
+	prevTx := core.Transaction{
+		Version: core.TxVersion,
+		Vins:    []*core.TxInput{{TxHash: []byte{}, Vout: -1}},
+		Vouts:   []*core.TxOutput{{Value: 5, ScriptPubKey: lock}},
+	}
+	prevTx.HashTransaction()
+	tx := scriptTestTx()
+	tx.Vins = []*core.TxInput{{TxHash: prevTx.TxHash, Vout: 0}}
+	prevTxs := map[string]core.Transaction{hex.EncodeToString(prevTx.TxHash): prevTx}
+	tx.Sign(decoded.PrivateKey, prevTxs)
+	if err := tx.VerifyScripts(prevTxs); nil != err {
+		t.Fatalf("spend the unpadded legacy output failed: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package test
+
+import (
+	"bkc/core"
+	"bytes"
+	"context"
+	"github.com/boltdb/bolt"
+	"testing"
+)
+
+// storageTestChain 创建区块链并挖出一个包含转账交易的区块
+func storageTestChain(t *testing.T, nodeId string) (*core.BlockChain, *core.Block) {
+	addresses := setupWallets(t, nodeId, 2)
+	from, to := addresses[0], addresses[1]
+	bc := setupBlockChain(t, nodeId, from)
+	tx := core.NewSimpleTransaction(from, to, 3, 1, bc, nil, nodeId)
+	if err := (&core.Mempool{Blockchain: bc}).AcceptTransaction(tx); nil != err {
+		t.Fatalf("accept the transaction failed: %v", err)
+	}
+	block, err := bc.MineBlock(context.Background(), from, 0, nil)
+	if nil != err {
+		t.Fatalf("mine the block failed: %v", err)
+	}
+	if len(block.Txs) != 2 {
+		t.Fatalf("the block contains %d transactions, expected 2", len(block.Txs))
+	}
+	return bc, block
+}
+
+func TestStorage_Headers(t *testing.T) {
+	bc, block := storageTestChain(t, "headerstoragetest")
+	// 区块头表中保存了每个区块的区块头
+	for _, hash := range bc.GetBlockHashes() {
+		header := bc.GetHeader(hash)
+		if nil == header {
+			t.Fatalf("the header of [%x] is not found", hash)
+		}
+		if !bytes.Equal(header.Hash(), hash) {
+			t.Fatalf("the header hash %x does not match the block hash %x", header.Hash(), hash)
+		}
+	}
+	header := bc.GetHeader(block.Hash)
+	if !bytes.Equal(header.Serialize(), block.BlockHeader.Serialize()) {
+		t.Fatalf("the stored header %+v does not match the block header %+v", header, block.BlockHeader)
+	}
+	if !bytes.Equal(header.MerkleRoot, block.HashTransaction()) {
+		t.Fatalf("the stored merkle root %x does not commit to the transactions", header.MerkleRoot)
+	}
+	if nil != bc.GetHeader(bytes.Repeat([]byte{0x11}, 32)) {
+		t.Fatalf("the unknown header should be nil")
+	}
+	// 区块头表只保存区块头，删除区块数据之后仍然能够读取区块头
+	err := bc.DB.Update(func(tx *bolt.Tx) error {
+		headerBytes := tx.Bucket([]byte(core.HeaderTableName)).Get(block.Hash)
+		if _, err := core.DecodeHeader(headerBytes); nil != err {
+			t.Fatalf("the headers table should only contain the header: %v", err)
+		}
+		return tx.Bucket([]byte(core.BlockTableName)).Delete(block.Hash)
+	})
+	if nil != err {
+		t.Fatalf("delete the block failed: %v", err)
+	}
+	if nil != bc.GetBlock(block.Hash) {
+		t.Fatalf("the block should be deleted")
+	}
+	if header := bc.GetHeader(block.Hash); nil == header || !bytes.Equal(header.Hash(), block.Hash) {
+		t.Fatalf("the header should be loaded without the block")
+	}
+}
+
+func TestStorage_TxIds(t *testing.T) {
+	bc, block := storageTestChain(t, "txidstoragetest")
+	data := bc.GetBlock(block.Hash)
+	stored, err := core.DecodeBlock(data)
+	if nil != err {
+		t.Fatalf("decode the stored block failed: %v", err)
+	}
+	// 保存的区块重新编码之后与原来的数据完全一致
+	encoded, err := core.EncodeBlock(stored)
+	if nil != err {
+		t.Fatalf("encode the stored block failed: %v", err)
+	}
+	if !bytes.Equal(encoded, data) {
+		t.Fatalf("the stored block does not round-trip through the encoding")
+	}
+	if !bytes.Equal(stored.Hash, block.Hash) || len(stored.Txs) != len(block.Txs) {
+		t.Fatalf("the stored block does not match the mined block")
+	}
+	// 交易哈希只由交易内容决定，任何节点都可以从保存的交易重新计算
+	for i, tx := range stored.Txs {
+		if !bytes.Equal(tx.TxHash, tx.Hash()) {
+			t.Fatalf("the stored txid %x does not match the contents %x", tx.TxHash, tx.Hash())
+		}
+		if !bytes.Equal(tx.TxHash, block.Txs[i].TxHash) {
+			t.Fatalf("the stored txid %x does not match the mined txid %x", tx.TxHash, block.Txs[i].TxHash)
+		}
+		txBytes, err := core.EncodeTransaction(tx)
+		if nil != err {
+			t.Fatalf("encode the transaction failed: %v", err)
+		}
+		decoded, err := core.DecodeTransaction(txBytes)
+		if nil != err || !bytes.Equal(decoded.TxHash, tx.TxHash) {
+			t.Fatalf("the transaction [%x] does not round-trip through the encoding: %v", tx.TxHash, err)
+		}
+	}
+	if !bytes.Equal(stored.MerkleRoot, stored.HashTransaction()) {
+		t.Fatalf("the merkle root of the stored block does not match the txids")
+	}
+	// 保存的区块的交易仍然可以通过 UTXO 集合找到
+	utxoSet := &core.UTXOSet{Blockchain: bc}
+	for _, tx := range stored.Txs {
+		if nil == utxoSet.FindUTXO(tx.TxHash, 0) {
+			t.Fatalf("the output of the stored tx [%x] is not in the utxo set", tx.TxHash)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package test
+
+import (
+	"bkc/core"
+	"context"
+	"testing"
+)
+
+func TestBlockSubsidy(t *testing.T) {
+	params := core.ActiveParams
+	interval := params.SubsidyHalvingInterval
+	cases := map[int64]int{
+		0:               0,
+		1:               params.InitialSubsidy,
+		interval:        params.InitialSubsidy,
+		interval + 1:    params.InitialSubsidy / 2,
+		2*interval + 1:  params.InitialSubsidy / 4,
+		64*interval + 1: 0,
+	}
+	for height, expected := range cases {
+		if subsidy := core.BlockSubsidy(height); subsidy != expected {
+			t.Errorf("the subsidy at height %d is %d, expected %d", height, subsidy, expected)
+		}
+	}
+	// 累计发行量不能超过上限
+	supply := 0
+	for height := int64(1); height <= 10*interval; height++ {
+		supply += core.BlockSubsidy(height)
+	}
+	if supply > params.MaxSupply {
+		t.Fatalf("the total supply %d exceeds the max supply %d", supply, params.MaxSupply)
+	}
+}
+
+func TestCoinbaseMaturity(t *testing.T) {
+	nodeId := "maturitytest"
+	addresses := setupWallets(t, nodeId, 2)
+	miner, other := addresses[0], addresses[1]
+	bc := setupBlockChain(t, nodeId, other)
+	block, err := bc.MineBlock(context.Background(), miner, 0, nil)
+	if nil != err {
+		t.Fatalf("mine the block failed: %v", err)
+	}
+	coinbase := block.Txs[0]
+
+	// 直接花费刚刚生成的 coinbase 输出
+	wallet := core.NewWallets(nodeId).Wallets[miner]
+	tx := &core.Transaction{
+		Version: core.TxVersion,
+		Vins:    []*core.TxInput{{TxHash: coinbase.TxHash, Vout: 0}},
+		Vouts:   []*core.TxOutput{core.NewTxOutput(coinbase.Vouts[0].Value, other)},
+	}
+	bc.SignTransaction(tx, wallet.PrivateKey, nil)
+	tx.HashTransaction()
+	mempool := &core.Mempool{Blockchain: bc}
+	if err := mempool.AcceptTransaction(tx); !core.IsRejectReason(err, core.RejectImmatureCoinbase) {
+		t.Fatalf("spending an immature coinbase should be rejected, got %v", err)
+	}
+	// 经过 CoinbaseMaturity 个区块之后可以花费
+	for i := int64(1); i < core.ActiveParams.CoinbaseMaturity; i++ {
+		if _, err := bc.MineBlock(context.Background(), other, 0, nil); nil != err {
+			t.Fatalf("mine the block failed: %v", err)
+		}
+	}
+	if err := mempool.AcceptTransaction(tx); nil != err {
+		t.Fatalf("spending a mature coinbase failed: %v", err)
+	}
+}
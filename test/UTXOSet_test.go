@@ -0,0 +1,109 @@
+package test
+
+import (
+	"bkc/core"
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// utxoSnapshot 将 UTXO 集合转换为有序的字符串列表，便于比较
+func utxoSnapshot(utxoSet *core.UTXOSet) []string {
+	var snapshot []string
+	for _, utxo := range utxoSet.FindAllUTXO() {
+		snapshot = append(snapshot, fmt.Sprintf("%x:%d:%d:%x",
+			utxo.TxHash, utxo.Index, utxo.Output.Value, utxo.Output.ScriptPubKey))
+	}
+	sort.Strings(snapshot)
+	return snapshot
+}
+
+func equalSnapshot(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestUTXOSet_DisconnectBlock(t *testing.T) {
+	nodeId := "utxotest"
+	addresses := setupWallets(t, nodeId, 2)
+	bc := setupBlockChain(t, nodeId, addresses[0])
+	utxoSet := &core.UTXOSet{Blockchain: bc}
+	before := utxoSnapshot(utxoSet)
+
+	// 连接一个包含转账交易的区块
+	if _, err := bc.SendTransactions([]string{addresses[0], addresses[0]}, []string{addresses[1], addresses[1]},
+		[]string{"3", "2"}, 0, 0, nil, nodeId); nil != err {
+		t.Fatalf("submit the transactions failed: %v", err)
+	}
+	if _, err := bc.MineBlock(context.Background(), addresses[0], 0, nil); nil != err {
+		t.Fatalf("mine the block failed: %v", err)
+	}
+	after := utxoSnapshot(utxoSet)
+	if equalSnapshot(before, after) {
+		t.Fatalf("the utxo set is not changed after connecting a block")
+	}
+	block, _ := bc.Iterator().PreBlock()
+
+	// 断开区块之后应该与连接之前完全相同
+	if err := utxoSet.DisconnectBlock(block); nil != err {
+		t.Fatalf("disconnect the block failed: %v", err)
+	}
+	if got := utxoSnapshot(utxoSet); !equalSnapshot(before, got) {
+		t.Fatalf("utxo set after disconnect:\n%v\nwant:\n%v", got, before)
+	}
+	// 撤销记录已经被删除，不能重复断开
+	if err := utxoSet.DisconnectBlock(block); nil == err {
+		t.Fatalf("disconnect the block twice should fail")
+	}
+
+	// 重新连接之后应该与第一次连接之后完全相同
+	utxoSet.ConnectBlock(block)
+	if got := utxoSnapshot(utxoSet); !equalSnapshot(after, got) {
+		t.Fatalf("utxo set after reconnect:\n%v\nwant:\n%v", got, after)
+	}
+}
+
+func TestUTXOSet_DataOutput(t *testing.T) {
+	nodeId := "utxodatatest"
+	addresses := setupWallets(t, nodeId, 2)
+	bc := setupBlockChain(t, nodeId, addresses[0])
+	utxoSet := &core.UTXOSet{Blockchain: bc}
+
+	// 数据超过上限时不能生成交易
+	tooLarge := make([]byte, core.ActiveParams.MaxDataCarrierSize+1)
+	if _, err := bc.SendTransactions([]string{addresses[0]}, []string{addresses[1]}, []string{"3"}, 0, 0, tooLarge,
+		nodeId); nil == err {
+		t.Fatalf("the data larger than %d bytes should be rejected", core.ActiveParams.MaxDataCarrierSize)
+	}
+	txs, err := bc.SendTransactions([]string{addresses[0]}, []string{addresses[1]}, []string{"3"}, 0, 0,
+		[]byte("document hash"), nodeId)
+	if nil != err {
+		t.Fatalf("submit the transaction failed: %v", err)
+	}
+	if data, ok := core.ExtractNullData(txs[0].Vouts[1].ScriptPubKey); !ok || string(data) != "document hash" {
+		t.Fatalf("the second output is not the data output: %s", core.DisasmScript(txs[0].Vouts[1].ScriptPubKey))
+	}
+	if _, err := bc.MineBlock(context.Background(), addresses[0], 0, nil); nil != err {
+		t.Fatalf("mine the block failed: %v", err)
+	}
+	// 数据输出不会进入 UTXO 集合，重建 UTXO 集合之后也一样
+	if nil != utxoSet.FindUTXO(txs[0].TxHash, 1) {
+		t.Fatalf("the data output is in the utxo set")
+	}
+	connected := utxoSnapshot(utxoSet)
+	utxoSet.ResetUTXOSet()
+	if got := utxoSnapshot(utxoSet); !equalSnapshot(connected, got) {
+		t.Fatalf("utxo set after reset:\n%v\nwant:\n%v", got, connected)
+	}
+	if len(connected) != 3 {
+		t.Fatalf("expected the payment, the change and the coinbase outputs, got %v", connected)
+	}
+}
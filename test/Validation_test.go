@@ -0,0 +1,207 @@
+package test
+
+import (
+	"bkc/core"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"github.com/boltdb/bolt"
+	"testing"
+	"time"
+)
+
+// validationTestHash 生成一个不属于任何区块或交易的哈希
+func validationTestHash(data string) []byte {
+	hash := sha256.Sum256([]byte(data))
+	return hash[:]
+}
+
+// validationTestTx 生成一笔花费 (txHash, vout) 的交易，不包含签名
+func validationTestTx(txHash []byte, vout int, value int, lockTime uint32, sequence uint32, address string) *core.Transaction {
+	tx := &core.Transaction{
+		Version:  core.TxVersion,
+		Vins:     []*core.TxInput{{TxHash: txHash, Vout: vout, Sequence: sequence}},
+		Vouts:    []*core.TxOutput{core.NewTxOutput(value, address)},
+		LockTime: lockTime,
+	}
+	tx.HashTransaction()
+	return tx
+}
+
+func TestCheckBlock_RejectReasons(t *testing.T) {
+	nodeId := "rejectreasontest"
+	miner := setupWallets(t, nodeId, 1)[0]
+	bc := setupBlockChain(t, nodeId, miner)
+	// spend 花费创世区块的 coinbase 输出（创世区块的 coinbase 不需要成熟）
+	spend := func(amount int) *core.Transaction {
+		return core.NewSimpleTransaction(miner, miner, amount, 0, bc, nil, nodeId)
+	}
+	// withTxs 在 coinbase 之后添加交易，并重新计算 Merkle 根
+	withTxs := func(txs ...*core.Transaction) func(block *core.Block) {
+		return func(block *core.Block) {
+			block.Txs = append(block.Txs[:1], txs...)
+			block.MerkleRoot = block.HashTransaction()
+		}
+	}
+	// withCoinbase 替换 coinbase 交易，并重新计算 Merkle 根
+	withCoinbase := func(coinbase *core.Transaction) func(block *core.Block) {
+		return func(block *core.Block) {
+			block.Txs[0] = coinbase
+			block.MerkleRoot = block.HashTransaction()
+		}
+	}
+	cases := []struct {
+		name   string
+		mutate func(block *core.Block) // 封装之前修改区块
+		after  func(block *core.Block) // 封装之后修改区块
+		reason core.RejectReason
+	}{
+		{name: "malformed", mutate: func(block *core.Block) { block.Txs[0].Vouts = nil }, reason: core.RejectMalformed},
+		{name: "negative output", mutate: func(block *core.Block) {
+			block.Txs[0].Vouts[0].Value = -1
+			block.Txs[0].HashTransaction()
+			block.MerkleRoot = block.HashTransaction()
+		}, reason: core.RejectBadTxValue},
+		{name: "bad txid", mutate: func(block *core.Block) {
+			block.Txs[0].TxHash = validationTestHash("txid")
+			block.MerkleRoot = block.HashTransaction()
+		}, reason: core.RejectBadTxHash},
+		{name: "no coinbase", mutate: func(block *core.Block) {
+			block.Txs = []*core.Transaction{spend(1)}
+			block.MerkleRoot = block.HashTransaction()
+		}, reason: core.RejectBadCoinbase},
+		{name: "coinbase height", mutate: func(block *core.Block) {
+			withCoinbase(core.NewCoinbaseTransaction(miner, block.Height+1, 0, 0))(block)
+		}, reason: core.RejectBadCoinbase},
+		{name: "bad merkle root", mutate: func(block *core.Block) { block.MerkleRoot = validationTestHash("merkle") },
+			reason: core.RejectBadMerkleRoot},
+		{name: "mutated merkle tree", mutate: func(block *core.Block) {
+			tx := validationTestTx(validationTestHash("duplicate"), 0, 1, 0, core.MaxSequence, miner)
+			withTxs(spend(1), tx, tx)(block)
+		}, reason: core.RejectBadMerkleRoot},
+		{name: "bad genesis", mutate: func(block *core.Block) { block.PrevBlockHash = nil }, reason: core.RejectBadGenesis},
+		{name: "bad pow", after: func(block *core.Block) {
+			// 找到一个哈希高于目标值的 nonce
+			for core.NewProofOfWork(block).Validate() {
+				block.Nonce++
+				block.Hash = block.BlockHeader.Hash()
+			}
+		}, reason: core.RejectInvalidPow},
+		{name: "bad block hash", after: func(block *core.Block) { block.Hash = validationTestHash("block") },
+			reason: core.RejectBadHash},
+		{name: "orphan", mutate: func(block *core.Block) { block.PrevBlockHash = validationTestHash("prev") },
+			reason: core.RejectOrphan},
+		{name: "bad height", mutate: func(block *core.Block) {
+			block.Height++
+			withCoinbase(core.NewCoinbaseTransaction(miner, block.Height, 0, 0))(block)
+		}, reason: core.RejectBadHeight},
+		{name: "bad bits", mutate: func(block *core.Block) { block.Bits-- }, reason: core.RejectBadBits},
+		{name: "time too old", mutate: func(block *core.Block) {
+			block.TimeStamp = bc.CalcPastMedianTime(bc.GetHeader(bc.TipHash()))
+		}, reason: core.RejectTimeTooOld},
+		{name: "time too new", mutate: func(block *core.Block) {
+			block.TimeStamp = time.Now().Unix() + core.ActiveParams.MaxFutureBlockTime + 60
+		}, reason: core.RejectTimeTooNew},
+		{name: "nonfinal", mutate: func(block *core.Block) {
+			withTxs(validationTestTx(validationTestHash("nonfinal"), 0, 1, 1000, 0, miner))(block)
+		}, reason: core.RejectNonFinal},
+		{name: "missing inputs", mutate: func(block *core.Block) {
+			withTxs(validationTestTx(validationTestHash("missing"), 0, 1, 0, core.MaxSequence, miner))(block)
+		}, reason: core.RejectMissingInput},
+		{name: "double spend", mutate: func(block *core.Block) { withTxs(spend(1), spend(2))(block) },
+			reason: core.RejectDoubleSpend},
+		{name: "immature coinbase", mutate: func(block *core.Block) {
+			withTxs(validationTestTx(block.Txs[0].TxHash, 0, 1, 0, core.MaxSequence, miner))(block)
+		}, reason: core.RejectImmatureCoinbase},
+		{name: "bad tx value", mutate: func(block *core.Block) {
+			// 没有手续费的交易，输出总额等于输入总额
+			tx := spend(1)
+			tx.Vouts[0].Value++
+			tx.HashTransaction()
+			withTxs(tx)(block)
+		}, reason: core.RejectBadTxValue},
+		{name: "bad signature", mutate: func(block *core.Block) {
+			tx := spend(2)
+			tx.Vouts[0].Value--
+			tx.HashTransaction()
+			withTxs(tx)(block)
+		}, reason: core.RejectBadSignature},
+		{name: "bad coinbase value", mutate: func(block *core.Block) {
+			withCoinbase(core.NewCoinbaseTransaction(miner, block.Height, 0, 1))(block)
+		}, reason: core.RejectBadCoinbaseValue},
+	}
+	for _, c := range cases {
+		block, err := bc.NewBlockTemplate(miner)
+		if nil != err {
+			t.Fatalf("create the block template failed: %v", err)
+		}
+		if nil != c.mutate {
+			c.mutate(block)
+		}
+		pow := core.NewProofOfWork(block)
+		pow.Workers = 1
+		block.Hash, block.Nonce, _ = pow.Run(context.Background())
+		if nil != c.after {
+			c.after(block)
+		}
+		var rejectErr *core.BlockRejectError
+		if err := bc.AddBlock(block); !errors.As(err, &rejectErr) || rejectErr.Reason != c.reason {
+			t.Fatalf("the block (%s) should be rejected (%s), got %v", c.name, c.reason, err)
+		}
+	}
+	// 已经保存的区块
+	block, err := bc.MineBlock(context.Background(), miner, 0, nil)
+	if nil != err {
+		t.Fatalf("mine the block failed: %v", err)
+	}
+	var rejectErr *core.BlockRejectError
+	if err := bc.AddBlock(block); !errors.As(err, &rejectErr) || rejectErr.Reason != core.RejectDuplicate {
+		t.Fatalf("the existing block should be rejected (%s), got %v", core.RejectDuplicate, err)
+	}
+}
+
+func TestCheckBlock_MissingAncestor(t *testing.T) {
+	defer func(params *core.ChainParams) { core.ActiveParams = params }(core.ActiveParams)
+	params := *core.ActiveParams
+	params.RetargetInterval = 2
+	core.ActiveParams = &params
+	nodeId := "missingancestortest"
+	miner := setupWallets(t, nodeId, 1)[0]
+	bc := setupBlockChain(t, nodeId, miner)
+	genesisHash := bc.TipHash()
+	prev, err := bc.MineBlock(context.Background(), miner, 0, nil)
+	if nil != err {
+		t.Fatalf("mine the block failed: %v", err)
+	}
+	// 删除创世区块之后无法计算高度 3 的难度（调整周期内的第一个区块缺失）
+	err = bc.DB.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket([]byte(core.HeaderTableName)).Delete(genesisHash); nil != err {
+			return err
+		}
+		return tx.Bucket([]byte(core.BlockTableName)).Delete(genesisHash)
+	})
+	if nil != err {
+		t.Fatalf("delete the genesis block failed: %v", err)
+	}
+	if _, err := bc.NewBlockTemplate(miner); nil == err {
+		t.Fatalf("the block template without the ancestors should fail")
+	}
+	block := &core.Block{
+		BlockHeader: core.BlockHeader{
+			Version:       prev.Version,
+			PrevBlockHash: prev.Hash,
+			TimeStamp:     prev.TimeStamp + 1,
+			Bits:          prev.Bits,
+			Height:        prev.Height + 1,
+		},
+		Txs: []*core.Transaction{core.NewCoinbaseTransaction(miner, prev.Height+1, 0, 0)},
+	}
+	block.MerkleRoot = block.HashTransaction()
+	pow := core.NewProofOfWork(block)
+	pow.Workers = 1
+	block.Hash, block.Nonce, _ = pow.Run(context.Background())
+	// 区块被拒绝，节点继续运行
+	if err := bc.AddBlock(block); !core.IsRejectReason(err, core.RejectBadBits) {
+		t.Fatalf("the block should be rejected (%s), got %v", core.RejectBadBits, err)
+	}
+}
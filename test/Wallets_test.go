@@ -2,12 +2,74 @@ package test
 
 import (
 	"bkc/core"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/gob"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"testing"
 )
 
 func TestWallets_CreateWallet(t *testing.T) {
-	wallets := core.NewWallets()
-	wallets.CreateWallet()
+	defer os.Remove("Wallets_test.dat")
+	wallets := core.NewWallets("test")
+	wallets.CreateWallet("test")
 	fmt.Printf("wallets:%v\n", wallets.Wallets)
-}
\ No newline at end of file
+}
+
+// walletsTestCurve 旧版本钱包文件中注册的椭圆曲线类型
+type walletsTestCurve struct {
+	*elliptic.CurveParams
+}
+
+// 旧版本（gob 直接编码 ecdsa.PrivateKey）的钱包文件结构
+type walletsTestLegacyWallet struct {
+	PrivateKey ecdsa.PrivateKey
+	PublicKey  []byte
+}
+
+type walletsTestLegacyWallets struct {
+	Wallets map[string]*walletsTestLegacyWallet
+}
+
+func TestWallets_LegacyFile(t *testing.T) {
+	nodeId := "legacywallettest"
+	walletFile := fmt.Sprintf("Wallets_%s.dat", nodeId)
+	defer os.Remove(walletFile)
+	wallet := core.NewWallet()
+	// 旧版本钱包的公钥为 x + y
+	legacyPubKey := signatureTestLegacyPubKey(wallet)
+	privateKey := wallet.PrivateKey
+	privateKey.Curve = walletsTestCurve{elliptic.P256().Params()}
+	gob.RegisterName("crypto/elliptic.p256Curve", walletsTestCurve{})
+	legacy := walletsTestLegacyWallets{Wallets: map[string]*walletsTestLegacyWallet{
+		"legacy": {PrivateKey: privateKey, PublicKey: legacyPubKey},
+	}}
+	var content bytes.Buffer
+	if err := gob.NewEncoder(&content).Encode(&legacy); nil != err {
+		t.Fatalf("encode the legacy wallets failed: %v", err)
+	}
+	if err := ioutil.WriteFile(walletFile, content.Bytes(), 0644); nil != err {
+		t.Fatalf("write the legacy wallet file failed: %v", err)
+	}
+	// 旧版本的钱包文件仍然可以读取，地址由旧公钥计算
+	wallets := core.NewWallets(nodeId)
+	if len(wallets.Wallets) != 1 {
+		t.Fatalf("the legacy wallet file has %d wallets, expected 1", len(wallets.Wallets))
+	}
+	for address, decoded := range wallets.Wallets {
+		if decoded.PrivateKey.D.Cmp(wallet.PrivateKey.D) != 0 || !bytes.Equal(decoded.PublicKey, legacyPubKey) {
+			t.Fatalf("the legacy wallet is not restored")
+		}
+		if address != string(decoded.GetAddress()) {
+			t.Fatalf("the legacy wallet is keyed by [%s], expected [%s]", address, decoded.GetAddress())
+		}
+	}
+	// 保存之后使用新的格式，再次读取得到相同的钱包
+	wallets.SaveWallets(nodeId)
+	if reloaded := core.NewWallets(nodeId); len(reloaded.Wallets) != 1 {
+		t.Fatalf("the saved wallet file has %d wallets, expected 1", len(reloaded.Wallets))
+	}
+}
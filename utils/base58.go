@@ -2,53 +2,100 @@ package utils
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
 	"math/big"
 )
 
 // base58 编码
 
-// base58 编码基数表
-var b58Alphabet = []byte("" +
-	"123456789" +
-	"abcdefghijkmnopqrstuvwxyz" +
-	"ABCDEFGHJKLMNPQRSTUVWXYZ")
+// base58 编码基数表（与比特币相同：去掉容易混淆的 0、O、I、l）
+var b58Alphabet = []byte("123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz")
 
-// Base58Encode 编码函数
+// b58Base 基数
+var b58Base = big.NewInt(int64(len(b58Alphabet)))
+
+// base58CheckSumLen Base58Check 校验和长度
+const base58CheckSumLen = 4
+
+// base58 解码错误
+var (
+	// ErrInvalidBase58 字符串包含基数表之外的字符
+	ErrInvalidBase58 = errors.New("invalid base58 character")
+	// ErrInvalidFormat Base58Check 字符串太短，没有版本前缀或者校验和
+	ErrInvalidFormat = errors.New("invalid base58check format")
+	// ErrChecksum Base58Check 校验和不一致
+	ErrChecksum = errors.New("base58check checksum mismatch")
+)
+
+// Base58Encode 编码函数，开头的每个 0 字节编码为一个 '1'
 func Base58Encode(input []byte) []byte {
 	var result []byte
-	x := big.NewInt(0).SetBytes(input)
-	// 求余的基本长度
-	base := big.NewInt(int64(len(b58Alphabet)))
-	// 求余数和商
-	zero := big.NewInt(0)
+	x := new(big.Int).SetBytes(input)
 	// 设置余数，代表 base58 基数表的索引位置
-	mod := &big.Int{}
-	for x.Cmp(zero) != 0 {
-		x.DivMod(x, base, mod)
+	mod := new(big.Int)
+	for x.Sign() != 0 {
+		x.DivMod(x, b58Base, mod)
 		result = append(result, b58Alphabet[mod.Int64()])
 	}
+	// 大数会丢掉开头的 0 字节，逐个补上
+	for _, b := range input {
+		if b != 0 {
+			break
+		}
+		result = append(result, b58Alphabet[0])
+	}
 	// 反转 result切片
 	Reverse(result)
-	// 添加一个前缀 1，代表是一个地址
-	result = append([]byte{b58Alphabet[0]}, result...)
 	return result
 }
 
-// Base58Decode 解码函数
-func Base58Decode(input []byte) []byte {
-	result := big.NewInt(0)
-	zeroBytes := 1
-	// 去掉前缀
-	data := input[zeroBytes:]
-	for _, b := range data {
+// Base58Decode 解码函数，开头的每个 '1' 解码为一个 0 字节，包含基数表之外的字符时返回 ErrInvalidBase58
+func Base58Decode(input []byte) ([]byte, error) {
+	result := new(big.Int)
+	for i, b := range input {
 		// 查找 input 中指定数字/字符在基数表中出现的索引
 		charIndex := bytes.IndexByte(b58Alphabet, b)
-		// 余数 * 58
-		result.Mul(result, big.NewInt(int64(len(b58Alphabet))))
-		// 乘积结果 + mod
+		if charIndex < 0 {
+			return nil, fmt.Errorf("%w %q at position %d", ErrInvalidBase58, b, i)
+		}
+		// 余数 * 58 + 索引
+		result.Mul(result, b58Base)
 		result.Add(result, big.NewInt(int64(charIndex)))
 	}
-	// 转换为 byte 字节数组
-	decoded := result.Bytes()
-	return decoded
-}
\ No newline at end of file
+	zeroBytes := 0
+	for zeroBytes < len(input) && input[zeroBytes] == b58Alphabet[0] {
+		zeroBytes++
+	}
+	return append(make([]byte, zeroBytes), result.Bytes()...), nil
+}
+
+// base58CheckSum Base58Check 校验和：两次 sha256 的前 4 个字节
+func base58CheckSum(input []byte) []byte {
+	first := sha256.Sum256(input)
+	second := sha256.Sum256(first[:])
+	return second[:base58CheckSumLen]
+}
+
+// Base58CheckEncode Base58Check 编码：base58(版本前缀 + payload + 校验和)
+func Base58CheckEncode(version byte, payload []byte) string {
+	data := append([]byte{version}, payload...)
+	return string(Base58Encode(append(data, base58CheckSum(data)...)))
+}
+
+// Base58CheckDecode Base58Check 解码，返回版本前缀与 payload
+func Base58CheckDecode(input string) (byte, []byte, error) {
+	decoded, err := Base58Decode([]byte(input))
+	if nil != err {
+		return 0, nil, err
+	}
+	if len(decoded) < 1+base58CheckSumLen {
+		return 0, nil, ErrInvalidFormat
+	}
+	data, checkSum := decoded[:len(decoded)-base58CheckSumLen], decoded[len(decoded)-base58CheckSumLen:]
+	if !bytes.Equal(base58CheckSum(data), checkSum) {
+		return 0, nil, ErrChecksum
+	}
+	return data[0], data[1:], nil
+}
@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// bech32 编码（BIP-173）
+
+// bech32 字符表，每个字符代表 5 位数据
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// bech32 字符串的最大长度
+const bech32MaxLen = 90
+
+// bech32 校验和生成多项式
+var bech32Generator = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+// bech32 解码错误
+var (
+	// ErrInvalidBech32 字符串格式错误：长度、大小写混用、分隔符或者字符表之外的字符
+	ErrInvalidBech32 = errors.New("invalid bech32 string")
+	// ErrBech32Checksum bech32 校验和不一致
+	ErrBech32Checksum = errors.New("bech32 checksum mismatch")
+)
+
+// bech32Polymod 计算校验和多项式
+func bech32Polymod(values []byte) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= bech32Generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+// bech32HrpExpand 展开可读前缀，参与校验和计算
+func bech32HrpExpand(hrp string) []byte {
+	result := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		result = append(result, hrp[i]>>5)
+	}
+	result = append(result, 0)
+	for i := 0; i < len(hrp); i++ {
+		result = append(result, hrp[i]&31)
+	}
+	return result
+}
+
+// bech32CheckSum 计算 6 个字符的校验和
+func bech32CheckSum(hrp string, data []byte) []byte {
+	values := append(bech32HrpExpand(hrp), data...)
+	values = append(values, 0, 0, 0, 0, 0, 0)
+	polymod := bech32Polymod(values) ^ 1
+	checkSum := make([]byte, 6)
+	for i := range checkSum {
+		checkSum[i] = byte(polymod>>uint(5*(5-i))) & 31
+	}
+	return checkSum
+}
+
+// Bech32Encode 编码函数，data 中每个元素为 5 位数据，结果为小写
+func Bech32Encode(hrp string, data []byte) (string, error) {
+	hrp = strings.ToLower(hrp)
+	if len(hrp) == 0 || len(hrp)+1+len(data)+6 > bech32MaxLen {
+		return "", fmt.Errorf("%w: the length is out of range", ErrInvalidBech32)
+	}
+	var result strings.Builder
+	result.WriteString(hrp)
+	result.WriteByte('1')
+	for _, v := range append(append([]byte{}, data...), bech32CheckSum(hrp, data)...) {
+		if v >= 32 {
+			return "", fmt.Errorf("%w: the value %d is not 5 bits", ErrInvalidBech32, v)
+		}
+		result.WriteByte(bech32Charset[v])
+	}
+	return result.String(), nil
+}
+
+// Bech32Decode 解码函数，返回小写的可读前缀与 5 位数据（不包含校验和）
+func Bech32Decode(input string) (string, []byte, error) {
+	if len(input) < 8 || len(input) > bech32MaxLen {
+		return "", nil, fmt.Errorf("%w: the length %d is out of range", ErrInvalidBech32, len(input))
+	}
+	// 只能全部小写或者全部大写
+	lower := strings.ToLower(input)
+	if input != lower && input != strings.ToUpper(input) {
+		return "", nil, fmt.Errorf("%w: mixed case", ErrInvalidBech32)
+	}
+	// 最后一个 '1' 是可读前缀与数据的分隔符
+	separator := strings.LastIndexByte(lower, '1')
+	if separator < 1 || separator+7 > len(lower) {
+		return "", nil, fmt.Errorf("%w: invalid separator position", ErrInvalidBech32)
+	}
+	hrp := lower[:separator]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, fmt.Errorf("%w: invalid prefix character %q", ErrInvalidBech32, hrp[i])
+		}
+	}
+	data := make([]byte, 0, len(lower)-separator-1)
+	for i := separator + 1; i < len(lower); i++ {
+		v := strings.IndexByte(bech32Charset, lower[i])
+		if v < 0 {
+			return "", nil, fmt.Errorf("%w: invalid character %q at position %d", ErrInvalidBech32, lower[i], i)
+		}
+		data = append(data, byte(v))
+	}
+	if bech32Polymod(append(bech32HrpExpand(hrp), data...)) != 1 {
+		return "", nil, ErrBech32Checksum
+	}
+	return hrp, data[:len(data)-6], nil
+}
+
+// ConvertBits 重新分组：把每个元素 fromBits 位的数据转换为每个元素 toBits 位，
+// pad 为 true 时用 0 补齐最后一组，否则剩余的位必须为 0 并且不足一组
+func ConvertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	var result []byte
+	acc, bits := uint32(0), uint(0)
+	maxValue := uint32(1)<<toBits - 1
+	for _, v := range data {
+		if uint32(v)>>fromBits != 0 {
+			return nil, fmt.Errorf("%w: the value %d is not %d bits", ErrInvalidBech32, v, fromBits)
+		}
+		acc = acc<<fromBits | uint32(v)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			result = append(result, byte(acc>>bits&maxValue))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			result = append(result, byte(acc<<(toBits-bits)&maxValue))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxValue != 0 {
+		return nil, fmt.Errorf("%w: invalid padding", ErrInvalidBech32)
+	}
+	return result, nil
+}